@@ -1,16 +1,31 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vfarcic/dot-ai-cli/internal/auth"
+	"github.com/vfarcic/dot-ai-cli/internal/client"
+	"github.com/vfarcic/dot-ai-cli/internal/config"
+	"github.com/vfarcic/dot-ai-cli/internal/terminal"
 )
 
 var authNoBrowser bool
 var authTokenTTL int
+var authDevice bool
+var authCallbackPort int
+var authClientCredentials bool
+var authClientCredentialsFile string
+var authWithToken bool
+var authStoreTo string
+var authTokenHeader bool
+var authStoreKeyFile string
 
 var authCmd = &cobra.Command{
 	Use:   "auth",
@@ -24,10 +39,32 @@ var authLoginCmd = &cobra.Command{
 	Long: `Starts an OAuth Authorization Code flow with PKCE.
 
 Opens your browser to the Dex login page. After authentication,
-the token is stored in ~/.config/dot-ai/credentials.json and used
-automatically for subsequent commands.`,
+the token is stored in ~/.config/dot-ai/credentials.json, bound to
+the server's origin, and used automatically for subsequent commands
+against that server only.
+
+On a remote host or in a container, where the browser cannot reach the
+CLI's loopback callback, either:
+  --device         use the device authorization grant: open the printed
+                   URL on any device and enter the code shown
+  --no-browser     print the login URL and paste back the URL the browser
+                   was redirected to (or forward --callback-port over SSH)
+
+In CI, --client-credentials authenticates a service account with the
+client-credentials grant, using DOT_AI_CLIENT_ID and DOT_AI_CLIENT_SECRET
+or --client-credentials-file. Only the short-lived access token is stored;
+it is re-acquired from the same source whenever it expires.
+
+--with-token reads a static token from stdin instead and stores it with
+the configured credential helper (see 'config set credential-helper'), or
+as auth_token in credentials.json when none is configured.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		serverURL := GetConfig().ServerURL
+		contextName := GetConfig().Context
+
+		if authWithToken {
+			return loginWithToken(cmd, serverURL, contextName)
+		}
 
 		// Resolve token TTL with precedence: flag > env > default (30 days)
 		tokenTTL := authTokenTTL
@@ -48,22 +85,82 @@ automatically for subsequent commands.`,
 			return fmt.Errorf("token TTL must be at least 1 second, got %d", tokenTTL)
 		}
 
-		return auth.Login(serverURL, authNoBrowser, tokenTTL)
+		if authCallbackPort < 0 || authCallbackPort > 65535 {
+			return fmt.Errorf("invalid --callback-port %d: must be between 0 and 65535", authCallbackPort)
+		}
+
+		opts := auth.LoginOptions{
+			ServerURL:    serverURL,
+			Context:      contextName,
+			NoBrowser:    authNoBrowser,
+			TokenTTL:     tokenTTL,
+			CallbackPort: authCallbackPort,
+			Out:          cmd.OutOrStdout(),
+			In:           cmd.InOrStdin(),
+		}
+		if authClientCredentials || authClientCredentialsFile != "" {
+			return auth.LoginClientCredentials(opts, authClientCredentialsFile)
+		}
+		if authDevice {
+			return auth.LoginDevice(opts)
+		}
+		return auth.Login(opts)
 	},
 }
 
+// loginWithToken stores the static token read from stdin for serverURL.
+func loginWithToken(cmd *cobra.Command, serverURL, contextName string) error {
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return fmt.Errorf("reading token from stdin: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("no token on stdin")
+	}
+	helper := GetConfig().CredentialHelper
+	if err := auth.StoreStaticToken(serverURL, contextName, helper, token); err != nil {
+		return err
+	}
+	if helper != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "Token stored with credential helper %s.\n", helper)
+	} else {
+		fmt.Fprintln(cmd.OutOrStdout(), "Token stored in credentials.json.")
+	}
+	return nil
+}
+
 var authLogoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Clear stored OAuth credentials",
-	Long: `Removes OAuth session tokens from credentials.json.
+	Long: `Removes the OAuth session tokens stored for the current server
+from credentials.json. When the server advertises a token revocation
+endpoint (RFC 7009), the tokens are revoked there first so they stop
+working server-side as well.
 
-Static tokens (auth_token) are preserved. Only the OAuth session
-fields (access_token, token_type, expires_at, client_id, client_secret)
-are cleared.`,
+Static tokens (auth_token) are preserved, as are credentials stored
+for other servers and the registered OAuth clients (see 'auth clients'). Only the OAuth session fields (access_token,
+refresh_token, token_type, expires_at, client_id, client_secret,
+grant_type, client_secret_file) are cleared, along with a cached exec
+provider token. When a credential helper is configured, the token it
+stores for the server is erased too.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := auth.Logout(); err != nil {
+		result, err := auth.Logout(GetConfig().ServerURL, GetConfig().Context)
+		if err != nil {
 			return err
 		}
+		if helper := GetConfig().CredentialHelper; helper != "" {
+			if err := auth.HelperErase(helper, GetConfig().ServerURL); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+			}
+		}
+		if result.RevokeErr != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: could not revoke the token on the server: %v\n", result.RevokeErr)
+		}
+		if result.Revoked {
+			fmt.Fprintln(cmd.OutOrStdout(), "Logged out. Tokens revoked on the server and stored OAuth credentials removed.")
+			return nil
+		}
 		fmt.Fprintln(cmd.OutOrStdout(), "Logged out. Stored OAuth credentials removed.")
 		return nil
 	},
@@ -74,6 +171,9 @@ var authStatusCmd = &cobra.Command{
 	Short: "Show current authentication status",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		out := cmd.OutOrStdout()
+		if name := GetConfig().Context; name != "" {
+			fmt.Fprintf(out, "Context: %s\n", name)
+		}
 
 		// Check for overrides first (flag/env take priority over stored credentials).
 		if envToken := os.Getenv("DOT_AI_AUTH_TOKEN"); envToken != "" {
@@ -85,29 +185,398 @@ var authStatusCmd = &cobra.Command{
 			return nil
 		}
 
-		info, err := auth.Status()
+		info, err := auth.Status(GetConfig().ServerURL, GetConfig().Context)
 		if err != nil {
 			return err
 		}
+		fmt.Fprintf(out, "Server: %s\n", info.Origin)
+		if store, err := auth.CredentialsStore(); err == nil && store.Encrypted {
+			fmt.Fprintf(out, "Credentials store: encrypted (%s)\n", store.KeySource)
+		}
+		switch c := GetConfig(); c.TokenSource {
+		case config.TokenSourceExec:
+			fmt.Fprintf(out, "Authenticated via: Exec provider (%s)\n", c.Exec.Command)
+			if !c.TokenExpiresAt.IsZero() {
+				fmt.Fprintf(out, "Token expires: %s (cached until then)\n", c.TokenExpiresAt.Format(time.RFC3339))
+			}
+			return nil
+		case config.TokenSourceHelper:
+			fmt.Fprintf(out, "Authenticated via: Credential helper (%s%s)\n", auth.CredentialHelperPrefix, c.CredentialHelper)
+			return nil
+		}
 		switch info.Mode {
 		case "oauth":
-			fmt.Fprintln(out, "Authenticated via: OAuth")
+			if info.GrantType == auth.GrantClientCredentials {
+				fmt.Fprintf(out, "Authenticated via: OAuth (client credentials, client %s)\n", info.ClientID)
+			} else {
+				fmt.Fprintln(out, "Authenticated via: OAuth")
+			}
 			fmt.Fprintf(out, "Token: %s\n", info.Token)
 			if info.ExpiresAt != "" {
 				fmt.Fprintf(out, "Token expires: %s\n", info.ExpiresAt)
-				if info.Expired {
+				switch {
+				case info.Expired && info.Refreshable:
+					fmt.Fprintln(out, "Status: EXPIRED — will be refreshed automatically on the next request")
+				case info.Expired:
 					fmt.Fprintln(out, "Status: EXPIRED — run 'dot-ai auth login' to re-authenticate")
-				} else {
+				default:
 					fmt.Fprintln(out, "Status: Valid")
 				}
 			}
+			switch {
+			case info.GrantType == auth.GrantClientCredentials:
+				fmt.Fprintln(out, "Renewal: re-acquired automatically with the client credentials")
+			case info.Refreshable:
+				fmt.Fprintln(out, "Refresh token: stored (renewed automatically)")
+			}
 		case "static-token":
 			fmt.Fprintln(out, "Authenticated via: Static token")
 			fmt.Fprintf(out, "Token: %s\n", info.Token)
 		default:
-			fmt.Fprintln(out, "Not authenticated.")
+			fmt.Fprintf(out, "Not authenticated: no credentials stored for %s.\n", info.Origin)
 			fmt.Fprintln(out, "Run 'dot-ai auth login' or set --token / DOT_AI_AUTH_TOKEN.")
 		}
+		if len(info.Origins) > 0 {
+			fmt.Fprintln(out, "Credentials stored for:")
+			for _, origin := range info.Origins {
+				marker := " "
+				if origin == info.Origin {
+					marker = "*"
+				}
+				fmt.Fprintf(out, "  %s %s\n", marker, origin)
+			}
+		}
+		return nil
+	},
+}
+
+var authWhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the identity behind the current token",
+	Long: `Shows who the current token authenticates: subject, username, email,
+groups, issuer and the token's real expiry.
+
+The claims of a JWT are decoded locally (the signature is not verified).
+When the server advertises an OIDC userinfo or an RFC 7662 token
+introspection endpoint, it is asked as well, which confirms the token is
+still active and fills in what an opaque token doesn't carry.
+
+Pass --output json (or yaml) for machine-readable output; a format set
+through DOT_AI_OUTPUT_FORMAT or output_format applies as well.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := GetConfig()
+		if c.Token == "" {
+			return fmt.Errorf("not authenticated: run 'dot-ai auth login' or set --token / DOT_AI_AUTH_TOKEN")
+		}
+		asDocument, err := documentOutput(cmd)
+		if err != nil {
+			return err
+		}
+		id, err := auth.Whoami(c.ServerURL, c.Context, c.Token)
+		if err != nil {
+			return err
+		}
+		if asDocument {
+			body, err := json.Marshal(id)
+			if err != nil {
+				return err
+			}
+			return printResponse(cmd, body)
+		}
+		printIdentity(cmd.OutOrStdout(), c.TokenSource, id)
+		return nil
+	},
+}
+
+// tokenInfo is the 'auth token --output json' document.
+type tokenInfo struct {
+	Token     string `json:"token"`
+	Type      string `json:"type"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Source    string `json:"source"`
+}
+
+var authTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Print the token the CLI would send",
+	Long: `Prints the bearer token the CLI would send to the current server, after
+applying the usual precedence (flag, environment, exec provider, credential
+helper, stored credentials) and refreshing an OAuth token that is about to
+expire. Use it to hand the CLI's credentials to other tools:
+
+  curl -H "$(dot-ai auth token --header)" "$DOT_AI_URL/api/v1/version"
+
+--header prints a ready-to-use "Authorization: Bearer ..." line.
+--output json (or yaml), or a format set through DOT_AI_OUTPUT_FORMAT or
+output_format, prints the token with its type, expiry and source (flag,
+env, exec, helper, static or oauth).
+
+The token is printed in clear; avoid running this where the output is
+logged.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := GetConfig()
+		// --header wins over a format chosen by DOT_AI_OUTPUT_FORMAT or
+		// the settings; only a typed --output conflicts with it.
+		if authTokenHeader && c.OutputFormatSource == config.SourceFlag {
+			return fmt.Errorf("--header and --output cannot be combined")
+		}
+		asDocument, err := documentOutput(cmd)
+		if err != nil && !authTokenHeader {
+			return err
+		}
+		client.RefreshIfExpiring(c)
+		if c.Token == "" {
+			return fmt.Errorf("not authenticated: run 'dot-ai auth login' or set --token / DOT_AI_AUTH_TOKEN")
+		}
+
+		out := cmd.OutOrStdout()
+		switch {
+		case authTokenHeader:
+			fmt.Fprintf(out, "Authorization: Bearer %s\n", c.Token)
+		case asDocument:
+			info := tokenInfo{Token: c.Token, Type: "Bearer", Source: tokenSource(cmd, c)}
+			if !c.TokenExpiresAt.IsZero() {
+				info.ExpiresAt = c.TokenExpiresAt.UTC().Format(time.RFC3339)
+			}
+			body, err := json.Marshal(info)
+			if err != nil {
+				return err
+			}
+			return printResponse(cmd, body)
+		default:
+			fmt.Fprintln(out, c.Token)
+		}
+		return nil
+	},
+}
+
+// tokenSource names where the token in effect came from, telling the
+// --token flag and DOT_AI_AUTH_TOKEN apart from a stored static token.
+func tokenSource(cmd *cobra.Command, c *config.Config) string {
+	if c.TokenSource != config.TokenSourceStatic {
+		return c.TokenSource
+	}
+	if f := cmd.Root().PersistentFlags().Lookup("token"); f != nil && f.Changed {
+		return "flag"
+	}
+	if os.Getenv("DOT_AI_AUTH_TOKEN") != "" {
+		return "env"
+	}
+	return config.TokenSourceStatic
+}
+
+// printIdentity writes the human-readable 'auth whoami' report.
+func printIdentity(out io.Writer, source string, id *auth.Identity) {
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(out, "%-15s %s\n", label+":", value)
+		}
+	}
+	field("Server", id.Server)
+	switch source {
+	case config.TokenSourceOAuth:
+		field("Authenticated", "OAuth ("+id.TokenType+" token)")
+	case config.TokenSourceExec:
+		field("Authenticated", "Exec provider ("+id.TokenType+" token)")
+	case config.TokenSourceHelper:
+		field("Authenticated", "Credential helper ("+id.TokenType+" token)")
+	default:
+		field("Authenticated", "Static token ("+id.TokenType+")")
+	}
+	field("Subject", id.Subject)
+	field("Username", id.Username)
+	field("Name", id.Name)
+	field("Email", id.Email)
+	field("Groups", strings.Join(id.Groups, ", "))
+	field("Issuer", id.Issuer)
+	field("Client", id.ClientID)
+	field("Scope", id.Scope)
+	if id.ExpiresAt != nil {
+		expires := id.ExpiresAt.Local().Format(time.RFC3339)
+		if id.Expired {
+			expires += " (EXPIRED)"
+		} else {
+			expires += fmt.Sprintf(" (in %s)", time.Until(*id.ExpiresAt).Round(time.Minute))
+		}
+		field("Expires", expires)
+	}
+	switch {
+	case id.ServerError != "":
+		field("Server check", "failed: "+id.ServerError)
+	case id.Active == nil:
+		field("Server check", "not available (the server advertises no userinfo or introspection endpoint)")
+	case *id.Active:
+		field("Server check", "token active ("+id.VerifiedBy+")")
+	default:
+		field("Server check", "token REJECTED ("+id.VerifiedBy+")")
+	}
+}
+
+var authClientsCmd = &cobra.Command{
+	Use:   "clients",
+	Short: "Manage the OAuth clients registered with the server",
+	Long: `auth login registers an OAuth client with the server once and reuses it
+for later logins. These commands show and clean up the registrations stored
+for the current server.`,
+}
+
+var authClientsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the OAuth clients registered for the current server",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info, err := auth.Clients(GetConfig().ServerURL, GetConfig().Context)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if len(info.Clients) == 0 {
+			fmt.Fprintf(out, "No OAuth clients registered for %s.\n", info.Origin)
+			return nil
+		}
+		fmt.Fprintf(out, "%-8s %-40s %-30s %-22s %s\n", "CURRENT", "CLIENT ID", "GRANTS", "LAST USED", "MANAGEABLE")
+		for _, rc := range info.Clients {
+			marker := ""
+			if rc.ClientID == info.Active {
+				marker = "*"
+			}
+			manageable := "no"
+			if rc.Manageable() {
+				manageable = "yes"
+			}
+			fmt.Fprintf(out, "%-8s %-40s %-30s %-22s %s\n", marker, rc.ClientID, strings.Join(rc.GrantTypes, ","), rc.LastUsedAt, manageable)
+		}
+		return nil
+	},
+}
+
+var authClientsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete OAuth client registrations the current session does not use",
+	Long: `Deletes the stored OAuth client registrations for the current server that
+the current session does not use, both on the server (RFC 7592 client
+management) and locally.
+
+Registrations the server cannot delete are kept: later logins keep reusing
+them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := auth.PruneClients(GetConfig().ServerURL, GetConfig().Context)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if len(results) == 0 {
+			fmt.Fprintln(out, "No OAuth clients registered for the current server.")
+			return nil
+		}
+		var failed int
+		for _, r := range results {
+			switch {
+			case r.Deleted:
+				fmt.Fprintf(out, "Deleted %s\n", r.ClientID)
+			case r.Err != nil:
+				failed++
+				fmt.Fprintf(out, "Failed %s: %v\n", r.ClientID, r.Err)
+			default:
+				fmt.Fprintf(out, "Kept %s: %s\n", r.ClientID, r.Skipped)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d client registration(s) could not be deleted", failed)
+		}
+		return nil
+	},
+}
+
+var authMigrateStoreCmd = &cobra.Command{
+	Use:   "migrate-store",
+	Short: "Convert credentials.json between plain and encrypted storage",
+	Long: `Rewrites credentials.json as an encrypted store (--to encrypted) or as
+plain JSON (--to plain).
+
+The encrypted store seals the whole file with AES-256-GCM. The key is
+derived from a passphrase (prompted for, or DOT_AI_CREDENTIALS_PASSPHRASE)
+or, with --key-file, from a file of at least 32 random bytes, e.g.:
+
+  head -c 32 /dev/urandom > ~/.config/dot-ai/credentials.key
+
+Once unlocked with the passphrase, the store stays unlocked for
+DOT_AI_UNLOCK_TTL (default 15m, 0 disables) so repeated commands are not
+prompted; 'dot-ai auth lock' locks it again. Running migrate-store on an
+encrypted store with a new passphrase or key file re-keys it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := auth.StoreOptions{KeyFile: authStoreKeyFile}
+		switch authStoreTo {
+		case "encrypted":
+			opts.Encrypt = true
+		case "plain":
+			if authStoreKeyFile != "" {
+				return fmt.Errorf("--key-file only applies to --to encrypted")
+			}
+		default:
+			return fmt.Errorf("invalid --to %q: must be \"encrypted\" or \"plain\"", authStoreTo)
+		}
+		// Unlock the current store before asking for a new passphrase.
+		if _, err := auth.LoadCredentials(); err != nil {
+			return fmt.Errorf("loading credentials: %w", err)
+		}
+		if opts.Encrypt && opts.KeyFile == "" {
+			passphrase, err := newPassphrase()
+			if err != nil {
+				return err
+			}
+			opts.Passphrase = passphrase
+		}
+		if err := auth.MigrateStore(opts); err != nil {
+			return err
+		}
+		if opts.Encrypt {
+			fmt.Fprintf(cmd.OutOrStdout(), "Credentials in %s are now encrypted.\n", auth.CredentialsPath())
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "Credentials in %s are now stored as plain JSON.\n", auth.CredentialsPath())
+		}
+		return nil
+	},
+}
+
+// newPassphrase reads the passphrase for a new encrypted store from
+// DOT_AI_CREDENTIALS_PASSPHRASE, or prompts for it twice.
+func newPassphrase() (string, error) {
+	if v := os.Getenv("DOT_AI_CREDENTIALS_PASSPHRASE"); v != "" {
+		return v, nil
+	}
+	passphrase, err := terminal.ReadPassword("New passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w (set DOT_AI_CREDENTIALS_PASSPHRASE or use --key-file)", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	confirm, err := terminal.ReadPassword("Repeat passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	if confirm != passphrase {
+		return "", fmt.Errorf("the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+var authLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock the encrypted credentials store",
+	Long: `Forgets the cached key of an encrypted credentials store, so the next
+command prompts for the passphrase again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := auth.LockStore(); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Credentials store locked.")
 		return nil
 	},
 }
@@ -115,8 +584,34 @@ var authStatusCmd = &cobra.Command{
 func init() {
 	authLoginCmd.Flags().BoolVar(&authNoBrowser, "no-browser", false, "Don't open browser; print the login URL instead")
 	authLoginCmd.Flags().IntVar(&authTokenTTL, "token-ttl", 0, "Token lifetime in seconds (default: 30 days) (env: DOT_AI_TOKEN_TTL_SECONDS)")
+	markOwnEnv(authLoginCmd.Flags(), "token-ttl", "DOT_AI_TOKEN_TTL_SECONDS")
+	authLoginCmd.Flags().BoolVar(&authDevice, "device", false, "Use the OAuth device flow: approve the login on another device with the code shown")
+	authLoginCmd.Flags().IntVar(&authCallbackPort, "callback-port", 0, "Fixed port for the local OAuth callback, e.g. to forward it over SSH (default: random)")
+	authLoginCmd.MarkFlagsMutuallyExclusive("device", "callback-port")
+	authLoginCmd.MarkFlagsMutuallyExclusive("device", "no-browser")
+	authLoginCmd.Flags().BoolVar(&authClientCredentials, "client-credentials", false, "Authenticate a service account with the client-credentials grant, using DOT_AI_CLIENT_ID and DOT_AI_CLIENT_SECRET")
+	authLoginCmd.Flags().StringVar(&authClientCredentialsFile, "client-credentials-file", "", "JSON file with client_id and client_secret for --client-credentials")
+	for _, f := range []string{"device", "no-browser", "callback-port"} {
+		authLoginCmd.MarkFlagsMutuallyExclusive("client-credentials", f)
+		authLoginCmd.MarkFlagsMutuallyExclusive("client-credentials-file", f)
+	}
+	authLoginCmd.Flags().BoolVar(&authWithToken, "with-token", false, "Read a static token from stdin and store it (with the credential helper, if configured)")
+	for _, f := range []string{"device", "no-browser", "callback-port", "client-credentials", "client-credentials-file"} {
+		authLoginCmd.MarkFlagsMutuallyExclusive("with-token", f)
+	}
 	authCmd.AddCommand(authLoginCmd)
 	authCmd.AddCommand(authLogoutCmd)
 	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authWhoamiCmd)
+	authTokenCmd.Flags().BoolVar(&authTokenHeader, "header", false, "Print an \"Authorization: Bearer <token>\" header line")
+	authCmd.AddCommand(authTokenCmd)
+	authMigrateStoreCmd.Flags().StringVar(&authStoreTo, "to", "", "Target storage: encrypted or plain")
+	authMigrateStoreCmd.Flags().StringVar(&authStoreKeyFile, "key-file", "", "Derive the encryption key from this file instead of a passphrase; DOT_AI_CREDENTIALS_KEY_FILE overrides its recorded path when unlocking")
+	authMigrateStoreCmd.MarkFlagRequired("to")
+	authCmd.AddCommand(authMigrateStoreCmd)
+	authCmd.AddCommand(authLockCmd)
+	authClientsCmd.AddCommand(authClientsListCmd)
+	authClientsCmd.AddCommand(authClientsPruneCmd)
+	authCmd.AddCommand(authClientsCmd)
 	rootCmd.AddCommand(authCmd)
 }
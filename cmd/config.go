@@ -1,26 +1,46 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
 	"github.com/vfarcic/dot-ai-cli/internal/auth"
+	"github.com/vfarcic/dot-ai-cli/internal/formatter"
 )
 
 // configKey maps a CLI key name to its Settings field.
 type configKey struct {
-	CLI         string
+	CLI string
+	// Setting is the field's settings.json key, as named in the system
+	// settings file's locked list.
+	Setting     string
 	Description string
 	Default     string
 	Get         func(*auth.Settings) string
 	Set         func(*auth.Settings, string)
+	// UserOnly keys name programs to run or widen what may be read, so
+	// they are never taken from a project file and cannot be written with
+	// --local.
+	UserOnly bool
+	// Values lists the accepted values of an enumerated key, for shell
+	// completion.
+	Values []string
 }
 
 var knownKeys = []configKey{
 	{
 		CLI:         "server-url",
+		Setting:     "server_url",
 		Description: "Server URL",
 		Default:     "",
 		Get:         func(s *auth.Settings) string { return s.ServerURL },
@@ -28,13 +48,16 @@ var knownKeys = []configKey{
 	},
 	{
 		CLI:         "output-format",
-		Description: "Output format (json, yaml)",
+		Setting:     "output_format",
+		Description: "Output format (json, yaml, ndjson, csv)",
 		Default:     "yaml",
 		Get:         func(s *auth.Settings) string { return s.OutputFormat },
 		Set:         func(s *auth.Settings, v string) { s.OutputFormat = v },
+		Values:      formatter.Formats,
 	},
 	{
 		CLI:         "skills.include",
+		Setting:     "skills_include",
 		Description: "Regex for skills to include",
 		Default:     "",
 		Get:         func(s *auth.Settings) string { return s.SkillsInclude },
@@ -42,6 +65,7 @@ var knownKeys = []configKey{
 	},
 	{
 		CLI:         "skills.exclude",
+		Setting:     "skills_exclude",
 		Description: "Regex for skills to exclude",
 		Default:     "",
 		Get:         func(s *auth.Settings) string { return s.SkillsExclude },
@@ -49,10 +73,30 @@ var knownKeys = []configKey{
 	},
 	{
 		CLI:         "skills.custom_only",
+		Setting:     "skills_custom_only",
 		Description: "Only generate custom skills, skip MCP tools (true/false)",
 		Default:     "",
 		Get:         func(s *auth.Settings) string { return s.SkillsCustomOnly },
 		Set:         func(s *auth.Settings, v string) { s.SkillsCustomOnly = v },
+		Values:      []string{"true", "false"},
+	},
+	{
+		CLI:         "skills.repo_dir_allow",
+		Setting:     "skills_repo_dir_allow",
+		Description: "Base directories 'skills generate --repo-dir' may read from (colon-separated)",
+		Default:     "",
+		Get:         func(s *auth.Settings) string { return s.SkillsRepoDirAllow },
+		Set:         func(s *auth.Settings, v string) { s.SkillsRepoDirAllow = v },
+		UserOnly:    true,
+	},
+	{
+		CLI:         "credential-helper",
+		Setting:     "credential_helper",
+		Description: "Credential helper that stores tokens (runs dot-ai-credential-<name>)",
+		Default:     "",
+		Get:         func(s *auth.Settings) string { return s.CredentialHelper },
+		Set:         func(s *auth.Settings, v string) { s.CredentialHelper = v },
+		UserOnly:    true,
 	},
 }
 
@@ -62,7 +106,7 @@ func findKey(name string) *configKey {
 			return &knownKeys[i]
 		}
 	}
-	return nil
+	return defaultsKey(name)
 }
 
 func validKeyNames() string {
@@ -70,7 +114,7 @@ func validKeyNames() string {
 	for i, k := range knownKeys {
 		names[i] = k.CLI
 	}
-	return strings.Join(names, ", ")
+	return strings.Join(append(names, defaultsPrefix+"<command path>.<flag>"), ", ")
 }
 
 func unknownKeyError(name string) error {
@@ -80,8 +124,8 @@ func unknownKeyError(name string) error {
 func validateConfigValue(key, value string) error {
 	switch key {
 	case "output-format":
-		if value != "" && value != "json" && value != "yaml" {
-			return fmt.Errorf("invalid value %q for %q: must be \"json\" or \"yaml\"", value, key)
+		if value != "" && !slices.Contains(formatter.Formats, value) {
+			return fmt.Errorf("invalid value %q for %q: must be one of [%s]", value, key, strings.Join(formatter.Formats, ", "))
 		}
 	case "skills.include", "skills.exclude":
 		if value != "" {
@@ -93,26 +137,195 @@ func validateConfigValue(key, value string) error {
 		if value != "" && value != "true" && value != "false" {
 			return fmt.Errorf("invalid value %q for %q: must be \"true\" or \"false\"", value, key)
 		}
+	case "credential-helper":
+		if strings.ContainsAny(value, `/\`) {
+			return fmt.Errorf("invalid value %q for %q: give the helper name, not a path (dot-ai runs %s<name> from PATH)", value, key, auth.CredentialHelperPrefix)
+		}
+	default:
+		if strings.HasPrefix(key, defaultsPrefix) && value != "" {
+			return validateFlagDefault(key, value)
+		}
+	}
+	return nil
+}
+
+// checkLocal rejects --local for keys that only settings.json may hold.
+func checkLocal(key *configKey) error {
+	if configLocal && key.UserOnly {
+		return fmt.Errorf("%s cannot be set in a project file; omit --local to use settings.json", key.CLI)
 	}
 	return nil
 }
 
+// checkLocked rejects changes to keys the system settings file locks.
+func checkLocked(key *configKey) error {
+	sys, err := auth.LoadSystemSettings()
+	if err != nil {
+		return err
+	}
+	if key.Setting != "" && sys.IsLocked(key.Setting) {
+		return fmt.Errorf("%s is locked by the administrator in %s and cannot be changed; its value there applies to every user", key.CLI, auth.SystemSettingsPath())
+	}
+	return nil
+}
+
+// checkLockedValue rejects value for a key the system settings file locks,
+// unless it is the locked value itself.
+func checkLockedValue(key *configKey, value string) error {
+	sys, err := auth.LoadSystemSettings()
+	if err != nil {
+		return err
+	}
+	if value == "" || !sys.IsLocked(key.Setting) || value == key.Get(&sys) {
+		return nil
+	}
+	return fmt.Errorf("%s is locked to %q by the administrator in %s and cannot be changed; its value there applies to every user", key.CLI, key.Get(&sys), auth.SystemSettingsPath())
+}
+
+// settingsLayer is one settings file contributing to the effective values.
+type settingsLayer struct {
+	// Origin describes the file for 'config list --show-origin'.
+	Origin   string
+	Settings *auth.Settings
+}
+
+// loadSettingsLayers returns the settings files in precedence order: the
+// project file (.dot-ai.yaml) when one applies, then settings.json (the
+// active context's preferences when contexts are configured), then the
+// system settings file when one exists. The system layer's Locked lists
+// the keys only it may set.
+func loadSettingsLayers() ([]settingsLayer, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	project, projectPath, err := auth.LoadProjectSettings(wd)
+	if err != nil {
+		return nil, err
+	}
+	all, err := auth.LoadUserSettings()
+	if err != nil {
+		return nil, err
+	}
+	sys, err := auth.LoadSystemSettings()
+	if err != nil {
+		return nil, err
+	}
+	name := GetConfig().Context
+	user, err := all.Active(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var layers []settingsLayer
+	if projectPath != "" {
+		// A project file comes with the repository, so its defaults are
+		// held to what 'config set --local' accepts.
+		dropInvalidDefaults(project, "file:"+projectPath)
+		layers = append(layers, settingsLayer{Origin: "file:" + projectPath, Settings: project})
+	}
+	userOrigin := "file:" + auth.SettingsPath()
+	if name = all.ActiveName(name); name != "" {
+		userOrigin += " (context " + name + ")"
+	}
+	layers = append(layers, settingsLayer{Origin: userOrigin, Settings: user})
+	if _, err := os.Stat(auth.SystemSettingsPath()); err == nil {
+		layers = append(layers, settingsLayer{Origin: "file:" + auth.SystemSettingsPath(), Settings: &sys})
+	}
+	return layers, nil
+}
+
+// effectiveValue returns the value of key from the first layer that sets it,
+// with that layer's origin, or from the layer that locks it. Unset keys
+// report an empty value and origin.
+func effectiveValue(layers []settingsLayer, key *configKey) (value, origin string) {
+	for _, l := range layers {
+		if key.Setting != "" && l.Settings.IsLocked(key.Setting) {
+			return key.Get(l.Settings), l.Origin + " (locked)"
+		}
+	}
+	for _, l := range layers {
+		if v := key.Get(l.Settings); v != "" {
+			return v, l.Origin
+		}
+	}
+	return "", ""
+}
+
+// loadEffectiveSettings merges the settings layers into the preferences in
+// effect: .dot-ai.yaml values win over settings.json ones.
+func loadEffectiveSettings() (*auth.Settings, error) {
+	layers, err := loadSettingsLayers()
+	if err != nil {
+		return nil, err
+	}
+	merged := &auth.Settings{}
+	for i := range knownKeys {
+		v, _ := effectiveValue(layers, &knownKeys[i])
+		knownKeys[i].Set(merged, v)
+	}
+	for _, l := range layers {
+		merged.Locked = append(merged.Locked, l.Settings.Locked...)
+	}
+	return merged, nil
+}
+
+// updateSettings applies update to the settings in scope for 'config set'
+// and 'config reset': the project file with --local, otherwise the active
+// context's entry in settings.json. It returns the file written.
+func updateSettings(local bool, update func(*auth.Settings)) (string, error) {
+	if local {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path, err := auth.ProjectFilePath(wd)
+		if err != nil {
+			return "", err
+		}
+		return path, auth.UpdateProjectFile(path, update)
+	}
+	err := auth.UpdateSettings(func(s *auth.Settings) error {
+		target, err := s.Active(GetConfig().Context)
+		if err != nil {
+			return err
+		}
+		update(target)
+		return nil
+	})
+	return auth.SettingsPath(), err
+}
+
+var configLocal bool
+var configShowOrigin bool
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage persistent settings",
-	Long:  "Read and write settings in ~/.config/dot-ai/settings.json.",
+	Long: `Read and write settings in ~/.config/dot-ai/settings.json.
+
+A project file (.dot-ai.yaml), discovered by walking up from the working
+directory to the git root, overrides settings.json; write to it with
+'config set --local'. When server contexts are configured (see
+'dot-ai context'), settings.json values are read from and written to the
+active context.`,
 }
 
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set a configuration value",
-	Long: fmt.Sprintf(`Set a persistent configuration value in settings.json.
+	Long: fmt.Sprintf(`Set a persistent configuration value in settings.json, or with --local
+in the project's .dot-ai.yaml.
 
 Supported keys:
-  %s`, func() string {
+  %s
+
+Default flag values for server commands are set with
+defaults.<command path>.<flag>, e.g. 'config set defaults.knowledge.ask.limit 20';
+flags given on the command line still win.`, func() string {
 		var lines []string
 		for _, k := range knownKeys {
-			lines = append(lines, fmt.Sprintf("%-16s %s", k.CLI, k.Description))
+			lines = append(lines, fmt.Sprintf("%-18s %s", k.CLI, k.Description))
 		}
 		return strings.Join(lines, "\n  ")
 	}()),
@@ -125,15 +338,20 @@ Supported keys:
 		if err := validateConfigValue(key.CLI, args[1]); err != nil {
 			return err
 		}
-		s, err := auth.LoadSettings()
-		if err != nil {
+		if err := checkLocal(key); err != nil {
+			return err
+		}
+		if err := checkLocked(key); err != nil {
 			return err
 		}
-		key.Set(&s, args[1])
-		if err := s.Save(); err != nil {
+		path, err := updateSettings(configLocal, func(s *auth.Settings) { key.Set(s, args[1]) })
+		if err != nil {
 			return err
 		}
 		fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", key.CLI, args[1])
+		if configLocal {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", path)
+		}
 		return nil
 	},
 }
@@ -147,11 +365,11 @@ var configGetCmd = &cobra.Command{
 		if key == nil {
 			return unknownKeyError(args[0])
 		}
-		s, err := auth.LoadSettings()
+		layers, err := loadSettingsLayers()
 		if err != nil {
 			return err
 		}
-		val := key.Get(&s)
+		val, _ := effectiveValue(layers, key)
 		if val == "" {
 			if key.Default != "" {
 				fmt.Fprintf(cmd.OutOrStdout(), "%s (default)\n", key.Default)
@@ -170,20 +388,31 @@ var configListCmd = &cobra.Command{
 	Short: "List all configuration keys and values",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		s, err := auth.LoadSettings()
+		layers, err := loadSettingsLayers()
 		if err != nil {
 			return err
 		}
-		for _, key := range knownKeys {
-			val := key.Get(&s)
+		out := cmd.OutOrStdout()
+		keys := make([]*configKey, len(knownKeys))
+		for i := range knownKeys {
+			keys[i] = &knownKeys[i]
+		}
+		for _, key := range append(keys, defaultsKeys(layers)...) {
+			val, origin := effectiveValue(layers, key)
+			if configShowOrigin {
+				if origin == "" {
+					origin = "default"
+				}
+				fmt.Fprintf(out, "%s\t", origin)
+			}
 			if val == "" {
 				if key.Default != "" {
-					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s (default)\n", key.CLI, key.Default)
+					fmt.Fprintf(out, "%s: %s (default)\n", key.CLI, key.Default)
 				} else {
-					fmt.Fprintf(cmd.OutOrStdout(), "%s: (not set)\n", key.CLI)
+					fmt.Fprintf(out, "%s: (not set)\n", key.CLI)
 				}
 			} else {
-				fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", key.CLI, val)
+				fmt.Fprintf(out, "%s: %s\n", key.CLI, val)
 			}
 		}
 		return nil
@@ -199,12 +428,13 @@ var configResetCmd = &cobra.Command{
 		if key == nil {
 			return unknownKeyError(args[0])
 		}
-		s, err := auth.LoadSettings()
-		if err != nil {
+		if err := checkLocal(key); err != nil {
+			return err
+		}
+		if err := checkLocked(key); err != nil {
 			return err
 		}
-		key.Set(&s, "")
-		if err := s.Save(); err != nil {
+		if _, err := updateSettings(configLocal, func(s *auth.Settings) { key.Set(s, "") }); err != nil {
 			return err
 		}
 		if key.Default != "" {
@@ -216,7 +446,291 @@ var configResetCmd = &cobra.Command{
 	},
 }
 
+// validateSettings checks every known key of a settings document, at the top
+// level and in each context.
+func validateSettings(s *auth.Settings) error {
+	check := func(profile *auth.Settings, where string) error {
+		for _, k := range knownKeys {
+			if err := validateConfigValue(k.CLI, k.Get(profile)); err != nil {
+				return fmt.Errorf("%s%w", where, err)
+			}
+		}
+		for path, flags := range profile.Defaults {
+			for flag, value := range flags {
+				if err := validateConfigValue(defaultsKeyName(path, flag), value); err != nil {
+					return fmt.Errorf("%s%w", where, err)
+				}
+			}
+		}
+		return nil
+	}
+	if err := check(s, ""); err != nil {
+		return err
+	}
+	for _, name := range s.ContextNames() {
+		if err := check(s.Contexts[name], "context "+name+": "); err != nil {
+			return err
+		}
+	}
+	if s.CurrentContext != "" {
+		if _, ok := s.Contexts[s.CurrentContext]; !ok {
+			return fmt.Errorf("current_context %q names no context", s.CurrentContext)
+		}
+	}
+	return nil
+}
+
+// configEntry is one key of 'config view'.
+type configEntry struct {
+	Value   string `json:"value"`
+	Origin  string `json:"origin"`
+	Default string `json:"default,omitempty"`
+}
+
+var configViewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Show the effective configuration as structured data",
+	Long: `Shows every configuration key with its effective value and the file it
+comes from ("default" when unset), as YAML or, with --output json, JSON.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		layers, err := loadSettingsLayers()
+		if err != nil {
+			return err
+		}
+		entries := map[string]configEntry{}
+		for i := range knownKeys {
+			key := &knownKeys[i]
+			val, origin := effectiveValue(layers, key)
+			if val == "" {
+				val, origin = key.Default, "default"
+			}
+			entries[key.CLI] = configEntry{Value: val, Origin: origin, Default: key.Default}
+		}
+		for _, key := range defaultsKeys(layers) {
+			val, origin := effectiveValue(layers, key)
+			entries[key.CLI] = configEntry{Value: val, Origin: origin}
+		}
+		view := struct {
+			SchemaVersion int                    `json:"schema_version"`
+			Context       string                 `json:"context,omitempty"`
+			Settings      map[string]configEntry `json:"settings"`
+		}{auth.SettingsSchemaVersion, GetConfig().Context, entries}
+		body, err := json.Marshal(view)
+		if err != nil {
+			return err
+		}
+		return printResponse(cmd, body)
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit settings.json in $EDITOR",
+	Long: `Opens settings.json in $VISUAL, $EDITOR or vi. The edited file is validated
+before it replaces settings.json: invalid JSON or values leave settings.json
+untouched and keep your edits in a temporary file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		original, err := auth.LoadUserSettings()
+		if err != nil {
+			return err
+		}
+		original.SchemaVersion = max(original.SchemaVersion, auth.SettingsSchemaVersion)
+		data, err := json.MarshalIndent(&original, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		tmp, err := os.CreateTemp("", "dot-ai-settings-*.json")
+		if err != nil {
+			return err
+		}
+		path := tmp.Name()
+		_, err = tmp.Write(append(data, '\n'))
+		tmp.Close()
+		if err != nil {
+			os.Remove(path)
+			return err
+		}
+		if err := runEditor(cmd, path); err != nil {
+			os.Remove(path)
+			return err
+		}
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(bytes.TrimSpace(edited), bytes.TrimSpace(data)) {
+			os.Remove(path)
+			fmt.Fprintln(cmd.OutOrStdout(), "Edit cancelled, no changes made.")
+			return nil
+		}
+
+		var updated auth.Settings
+		err = json.Unmarshal(edited, &updated)
+		if err == nil {
+			err = validateSettings(&updated)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid settings, %s was not changed (your edits are in %s): %w", auth.SettingsPath(), path, err)
+		}
+		err = auth.UpdateSettings(func(s *auth.Settings) error {
+			s.SchemaVersion = original.SchemaVersion
+			if !reflect.DeepEqual(*s, original) {
+				return fmt.Errorf("%s was changed by another process while you were editing (your edits are in %s)", auth.SettingsPath(), path)
+			}
+			*s = updated
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		os.Remove(path)
+		fmt.Fprintf(cmd.OutOrStdout(), "Saved %s\n", auth.SettingsPath())
+		return nil
+	},
+}
+
+// runEditor opens path in the user's editor, attached to the terminal.
+func runEditor(cmd *cobra.Command, path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	c := exec.Command(fields[0], append(fields[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, cmd.OutOrStdout(), cmd.ErrOrStderr()
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("running editor %s: %w", editor, err)
+	}
+	return nil
+}
+
+var configExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the current settings to share them",
+	Long: `Writes the settings of the active profile in settings.json (the active
+context's, when contexts are configured) as YAML in the .dot-ai.yaml format,
+to the file given or stdout. Credentials, credential helpers and exec
+providers are never exported. Load the file with 'config import', or commit
+it as a project's .dot-ai.yaml.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := auth.LoadUserSettings()
+		if err != nil {
+			return err
+		}
+		profile, err := s.Active(GetConfig().Context)
+		if err != nil {
+			return err
+		}
+		data, err := auth.ExportSettings(profile)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			_, err := cmd.OutOrStdout().Write(data)
+			return err
+		}
+		if err := atomicfile.WriteFile(args[0], data, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", args[0])
+		return nil
+	},
+}
+
+var configImportCmd = &cobra.Command{
+	Use:   "import <file|->",
+	Short: "Import settings exported with 'config export'",
+	Long: `Reads settings in the .dot-ai.yaml format (YAML or JSON, "-" for stdin),
+validates them, and sets each key present in the file in settings.json (the
+active context's entry when contexts are configured), or with --local in the
+project's .dot-ai.yaml. Keys absent from the file keep their values.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		imported, err := auth.ParseProjectSettings(data)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", args[0], err)
+		}
+		if imported.SchemaVersion > auth.SettingsSchemaVersion {
+			return fmt.Errorf("%s uses settings schema version %d; this version of dot-ai supports up to %d", args[0], imported.SchemaVersion, auth.SettingsSchemaVersion)
+		}
+		if err := validateSettings(imported); err != nil {
+			return err
+		}
+		var applied []*configKey
+		for i := range knownKeys {
+			if knownKeys[i].Get(imported) != "" {
+				applied = append(applied, &knownKeys[i])
+			}
+		}
+		applied = append(applied, defaultsKeys([]settingsLayer{{Settings: imported}})...)
+		if len(applied) == 0 {
+			return fmt.Errorf("%s sets no settings", args[0])
+		}
+		for _, key := range applied {
+			if err := checkLocked(key); err != nil {
+				return err
+			}
+		}
+		path, err := updateSettings(configLocal, func(s *auth.Settings) {
+			for _, key := range applied {
+				key.Set(s, key.Get(imported))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range applied {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", key.CLI, key.Get(imported))
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", path)
+		return nil
+	},
+}
+
+// completeConfigKeys completes key names, and for 'config set' the values
+// of enumerated keys.
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch {
+	case len(args) == 0:
+		var names []string
+		for _, k := range knownKeys {
+			names = append(names, k.CLI+"\t"+k.Description)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	case len(args) == 1 && cmd == configSetCmd:
+		if key := findKey(args[0]); key != nil {
+			return key.Values, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
 func init() {
+	configSetCmd.Flags().BoolVar(&configLocal, "local", false, "Write to the project's .dot-ai.yaml instead of settings.json")
+	configResetCmd.Flags().BoolVar(&configLocal, "local", false, "Reset the value in the project's .dot-ai.yaml instead of settings.json")
+	configListCmd.Flags().BoolVar(&configShowOrigin, "show-origin", false, "Show the file each value comes from")
+	configImportCmd.Flags().BoolVar(&configLocal, "local", false, "Import into the project's .dot-ai.yaml instead of settings.json")
+	for _, c := range []*cobra.Command{configSetCmd, configGetCmd, configResetCmd} {
+		c.ValidArgsFunction = completeConfigKeys
+	}
 	configCmd.AddCommand(configSetCmd, configGetCmd, configListCmd, configResetCmd)
+	configCmd.AddCommand(configViewCmd, configEditCmd, configExportCmd, configImportCmd)
 	rootCmd.AddCommand(configCmd)
 }
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/auth"
+)
+
+var contextAddServer string
+var contextAddOutputFormat string
+var contextAddSkillsInclude string
+var contextAddSkillsExclude string
+var contextAddSkillsCustomOnly string
+var contextAddStaticToken string
+var contextAddUse bool
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage named server contexts",
+	Long: `Manage named server contexts (profiles), kubectl-style.
+
+Each context carries its own server URL, output format, skills filters and
+credentials (OAuth session or static token). Select one with --context or
+DOT_AI_CONTEXT, or make it current with 'dot-ai context use'.
+
+Creating the first context migrates existing flat settings and credentials
+into a context named "default".`,
+}
+
+var contextAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a context",
+	Long: `Create a context. Log in to it with 'dot-ai --context <name> auth login',
+or store a static token with --static-token (use "-" to read it from stdin).
+
+The first context becomes current automatically; use --use to switch to
+later ones.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := validateContextName(name); err != nil {
+			return err
+		}
+		for _, kv := range [][2]string{
+			{"server-url", contextAddServer},
+			{"output-format", contextAddOutputFormat},
+			{"skills.include", contextAddSkillsInclude},
+			{"skills.exclude", contextAddSkillsExclude},
+			{"skills.custom_only", contextAddSkillsCustomOnly},
+		} {
+			if err := validateConfigValue(kv[0], kv[1]); err != nil {
+				return err
+			}
+			if err := checkLockedValue(findKey(kv[0]), kv[1]); err != nil {
+				return err
+			}
+		}
+		if _, err := auth.Origin(contextAddServer); err != nil {
+			return err
+		}
+		token := contextAddStaticToken
+		if token == "-" {
+			read, err := readToken(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			token = read
+		}
+
+		var migrated, current bool
+		err := auth.UpdateState(func(s *auth.Settings, c *auth.Credentials) error {
+			migrated = auth.MigrateToContexts(s, c)
+			if _, ok := s.Contexts[name]; ok {
+				return fmt.Errorf("context %q already exists", name)
+			}
+			if s.Contexts == nil {
+				s.Contexts = map[string]*auth.Settings{}
+			}
+			s.Contexts[name] = &auth.Settings{
+				ServerURL:        contextAddServer,
+				OutputFormat:     contextAddOutputFormat,
+				SkillsInclude:    contextAddSkillsInclude,
+				SkillsExclude:    contextAddSkillsExclude,
+				SkillsCustomOnly: contextAddSkillsCustomOnly,
+			}
+			if token != "" {
+				cred, err := c.For(name).ForServer(contextAddServer)
+				if err != nil {
+					return err
+				}
+				cred.AuthToken = token
+			}
+			if contextAddUse || s.CurrentContext == "" {
+				s.CurrentContext = name
+			}
+			current = s.CurrentContext == name
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if migrated {
+			fmt.Fprintf(cmd.OutOrStdout(), "Migrated existing settings and credentials to context %q\n", auth.DefaultContextName)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Context %q added\n", name)
+		if current {
+			fmt.Fprintf(cmd.OutOrStdout(), "Switched to context %q\n", name)
+		}
+		return nil
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the current context",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := auth.UpdateSettings(func(s *auth.Settings) error {
+			if _, ok := s.Contexts[args[0]]; !ok {
+				return &auth.ContextNotFoundError{Name: args[0]}
+			}
+			s.CurrentContext = args[0]
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Switched to context %q\n", args[0])
+		return nil
+	},
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List contexts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, c, err := loadContextState()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		names := s.ContextNames()
+		if len(names) == 0 {
+			fmt.Fprintln(out, "No contexts configured. Create one with 'dot-ai context add <name> --server <url>'.")
+			return nil
+		}
+		active := GetConfig().Context
+		fmt.Fprintf(out, "%-8s %-20s %-40s %s\n", "CURRENT", "NAME", "SERVER", "AUTH")
+		for _, name := range names {
+			marker := ""
+			if name == active {
+				marker = "*"
+			}
+			server := s.Contexts[name].ServerURL
+			if server == "" {
+				server = "(default)"
+			}
+			fmt.Fprintf(out, "%-8s %-20s %-40s %s\n", marker, name, server, credentialKind(c.Contexts[name], s.Contexts[name].ServerURL))
+		}
+		return nil
+	},
+}
+
+var contextDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a context and its credentials",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var wasCurrent bool
+		err := auth.UpdateState(func(s *auth.Settings, c *auth.Credentials) error {
+			wasCurrent = s.CurrentContext == args[0]
+			return auth.DeleteContext(s, c, args[0])
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Context %q deleted\n", args[0])
+		if wasCurrent {
+			fmt.Fprintln(cmd.OutOrStdout(), "No current context is set; run 'dot-ai context use <name>' to select one.")
+		}
+		return nil
+	},
+}
+
+var contextRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a context",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateContextName(args[1]); err != nil {
+			return err
+		}
+		err := auth.UpdateState(func(s *auth.Settings, c *auth.Credentials) error {
+			return auth.RenameContext(s, c, args[0], args[1])
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Context %q renamed to %q\n", args[0], args[1])
+		return nil
+	},
+}
+
+// loadContextState loads settings and credentials together for reading;
+// changes go through auth.UpdateState, which keeps both files in step.
+func loadContextState() (auth.Settings, auth.Credentials, error) {
+	s, err := auth.LoadSettings()
+	if err != nil {
+		return s, auth.Credentials{}, err
+	}
+	c, err := auth.LoadCredentials()
+	if err != nil {
+		return s, c, fmt.Errorf("loading credentials: %w", err)
+	}
+	return s, c, nil
+}
+
+// validateContextName rejects names that would be awkward in flags, env vars
+// and listings.
+func validateContextName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n/\\") {
+		return fmt.Errorf("invalid context name %q: must be non-empty and contain no whitespace or slashes", name)
+	}
+	return nil
+}
+
+// credentialKind summarises which kind of credential a context holds for its
+// server.
+func credentialKind(c *auth.Credentials, serverURL string) string {
+	if c != nil {
+		c = c.Server(serverURL)
+	}
+	switch {
+	case c == nil:
+		return "none"
+	case c.AuthToken != "":
+		return "static-token"
+	case c.AccessToken != "":
+		return "oauth"
+	default:
+		return "none"
+	}
+}
+
+// readToken reads a single token line from r.
+func readToken(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("reading token from stdin: %w", err)
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", fmt.Errorf("no token provided on stdin")
+	}
+	return token, nil
+}
+
+func init() {
+	contextAddCmd.Flags().StringVar(&contextAddServer, "server", "", "Server URL for this context")
+	contextAddCmd.Flags().StringVar(&contextAddOutputFormat, "output-format", "", "Default output format for this context")
+	contextAddCmd.Flags().StringVar(&contextAddSkillsInclude, "skills-include", "", "Regex for skills to include")
+	contextAddCmd.Flags().StringVar(&contextAddSkillsExclude, "skills-exclude", "", "Regex for skills to exclude")
+	contextAddCmd.Flags().StringVar(&contextAddSkillsCustomOnly, "skills-custom-only", "", "Only generate custom skills, skip MCP tools (true/false)")
+	contextAddCmd.Flags().StringVar(&contextAddStaticToken, "static-token", "", "Static auth token for this context (\"-\" reads it from stdin)")
+	contextAddCmd.Flags().BoolVar(&contextAddUse, "use", false, "Make the new context current")
+	contextAddCmd.MarkFlagRequired("server")
+
+	completeContexts := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		s, err := auth.LoadSettings()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return s.ContextNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+	contextUseCmd.ValidArgsFunction = completeContexts
+	contextDeleteCmd.ValidArgsFunction = completeContexts
+	contextRenameCmd.ValidArgsFunction = completeContexts
+
+	contextCmd.AddCommand(contextAddCmd, contextUseCmd, contextListCmd, contextDeleteCmd, contextRenameCmd)
+	rootCmd.AddCommand(contextCmd)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/auth"
+)
+
+// defaultsPrefix starts the config keys of per-command flag defaults:
+// defaults.<command path>.<flag>, e.g. defaults.knowledge.ask.limit.
+const defaultsPrefix = "defaults."
+
+// noDefaultFlags are the flags a default may never set: they choose which
+// file a response overwrites and what counts as success, which a settings
+// file (least of all a cloned repository's .dot-ai.yaml) must not decide.
+var noDefaultFlags = []string{"output-file", "overwrite", "expect"}
+
+// defaultsKey returns the config key for a per-command flag default, or nil
+// when name is not of the form defaults.<command path>.<flag>. The command
+// and flag are only checked when a value is set (see validateFlagDefault), so
+// a default left behind by a removed flag can still be read and reset.
+func defaultsKey(name string) *configKey {
+	rest, ok := strings.CutPrefix(name, defaultsPrefix)
+	i := strings.LastIndex(rest, ".")
+	if !ok || i <= 0 || i == len(rest)-1 {
+		return nil
+	}
+	path, flag := strings.ReplaceAll(rest[:i], ".", " "), rest[i+1:]
+	return &configKey{
+		CLI:         name,
+		Description: fmt.Sprintf("Default --%s for '%s'", flag, path),
+		Get:         func(s *auth.Settings) string { return s.Defaults[path][flag] },
+		Set:         func(s *auth.Settings, v string) { setFlagDefault(s, path, flag, v) },
+	}
+}
+
+// defaultsKeyName is the config key of the default for flag on the command
+// at path ("knowledge ask").
+func defaultsKeyName(path, flag string) string {
+	return defaultsPrefix + strings.ReplaceAll(path, " ", ".") + "." + flag
+}
+
+// setFlagDefault stores value as the default for flag on the command at
+// path, removing it (and an emptied command entry) when value is empty.
+func setFlagDefault(s *auth.Settings, path, flag, value string) {
+	if value == "" {
+		delete(s.Defaults[path], flag)
+		if len(s.Defaults[path]) == 0 {
+			delete(s.Defaults, path)
+		}
+		if len(s.Defaults) == 0 {
+			s.Defaults = nil
+		}
+		return
+	}
+	if s.Defaults == nil {
+		s.Defaults = map[string]map[string]string{}
+	}
+	if s.Defaults[path] == nil {
+		s.Defaults[path] = map[string]string{}
+	}
+	s.Defaults[path][flag] = value
+}
+
+// defaultsKeys returns the config keys of every flag default set in any
+// layer, sorted.
+func defaultsKeys(layers []settingsLayer) []*configKey {
+	var names []string
+	for _, l := range layers {
+		for path, flags := range l.Settings.Defaults {
+			for flag := range flags {
+				names = append(names, defaultsKeyName(path, flag))
+			}
+		}
+	}
+	slices.Sort(names)
+	var keys []*configKey
+	for _, name := range slices.Compact(names) {
+		keys = append(keys, defaultsKey(name))
+	}
+	return keys
+}
+
+// commandPath is the path of cmd below the root, as used in Defaults keys.
+func commandPath(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// validateFlagDefault checks that the default in key names a flag of a
+// server command, defined by the command itself (not a global flag such as
+// --server-url) and not one of noDefaultFlags, and that value parses as
+// that flag's type.
+func validateFlagDefault(key, value string) error {
+	rest := strings.TrimPrefix(key, defaultsPrefix)
+	i := strings.LastIndex(rest, ".")
+	path, flag := rest[:i], rest[i+1:]
+
+	cmd, remaining, err := rootCmd.Find(strings.Split(path, "."))
+	if err != nil || len(remaining) > 0 || cmd == rootCmd {
+		return fmt.Errorf("invalid key %q: no command '%s'", key, strings.ReplaceAll(path, ".", " "))
+	}
+	if cmd.Annotations["method"] == "" {
+		return fmt.Errorf("invalid key %q: defaults apply only to server commands, not '%s'", key, commandPath(cmd))
+	}
+	f := cmd.LocalFlags().Lookup(flag)
+	if f == nil || flag == "help" {
+		return fmt.Errorf("invalid key %q: '%s' has no flag --%s", key, commandPath(cmd), flag)
+	}
+	if slices.Contains(noDefaultFlags, flag) {
+		return fmt.Errorf("invalid key %q: --%s cannot have a default; pass it on the command line", key, flag)
+	}
+
+	var parseErr error
+	switch f.Value.Type() {
+	case "int", "int32", "int64":
+		_, parseErr = strconv.ParseInt(value, 10, 64)
+	case "float32", "float64":
+		_, parseErr = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, parseErr = strconv.ParseBool(value)
+	case "duration":
+		_, parseErr = time.ParseDuration(value)
+	}
+	if parseErr != nil {
+		return fmt.Errorf("invalid value %q for %q: --%s must be of type %s", value, key, flag, f.Value.Type())
+	}
+	return nil
+}
+
+// dropInvalidDefaults removes, with a warning, the defaults in s that
+// validateFlagDefault rejects, so a project file can only ever default the
+// flags 'config set' would accept.
+func dropInvalidDefaults(s *auth.Settings, origin string) {
+	for path, flags := range s.Defaults {
+		for flag, value := range flags {
+			key := defaultsKeyName(path, flag)
+			if err := validateFlagDefault(key, value); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring %s from %s: %v\n", key, origin, err)
+				setFlagDefault(s, path, flag, "")
+			}
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/client"
+	"github.com/vfarcic/dot-ai-cli/internal/compat"
+	"github.com/vfarcic/dot-ai-cli/internal/config"
+	"github.com/vfarcic/dot-ai-cli/internal/rbac"
+	"github.com/vfarcic/dot-ai-cli/internal/skills"
+)
+
+// Doctor check results.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCertWarning is how close to expiry a server certificate is reported.
+const doctorCertWarning = 14 * 24 * time.Hour
+
+// doctorCheck is the result of one 'doctor' check.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	// Hint says how to fix a warning or failure.
+	Hint string `json:"hint,omitempty"`
+}
+
+// resolveErr is the configuration error 'doctor' reports instead of exiting
+// (see initConfig).
+var resolveErr error
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose configuration, connectivity and skills setup",
+	Long: `Runs end-to-end checks and reports each as pass, warn or fail, with a hint
+for anything that needs fixing:
+
+  configuration    the server URL and token source in effect, and where they come from
+  authentication   the token's presence and expiry, and whether the server accepts it
+  server           whether the server is reachable
+  tls              the server certificate's validity and expiry
+  server version   the server version against the API this CLI was built from
+  rbac             the tools the server allows you to use
+  skills           generated skills directories
+  skills hook      the Claude Code SessionStart hook and the dot-ai binary it runs
+  git              git availability, needed by 'skills generate --repo-fetch'
+
+Pass --output json (or yaml) for machine-readable output; a format set
+through DOT_AI_OUTPUT_FORMAT or output_format applies as well. Exits
+non-zero when any check fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asDocument, err := documentOutput(cmd)
+		if err != nil {
+			return err
+		}
+		checks := runDoctorChecks()
+		if asDocument {
+			summary := map[string]int{checkPass: 0, checkWarn: 0, checkFail: 0}
+			for _, c := range checks {
+				summary[c.Status]++
+			}
+			body, err := json.Marshal(map[string]any{"checks": checks, "summary": summary})
+			if err != nil {
+				return err
+			}
+			if err := printResponse(cmd, body); err != nil {
+				return err
+			}
+		} else {
+			printDoctorChecks(cmd.OutOrStdout(), checks)
+		}
+
+		failed := 0
+		for _, c := range checks {
+			if c.Status == checkFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return &client.RequestError{
+				Message:  fmt.Sprintf("%d of %d checks failed", failed, len(checks)),
+				ExitCode: client.ExitToolError,
+			}
+		}
+		return nil
+	},
+}
+
+// runDoctorChecks runs every check in order. Checks that need the server are
+// skipped (as warnings) when the configuration could not be resolved or the
+// server is unreachable.
+func runDoctorChecks() []doctorCheck {
+	c := GetConfig()
+	var checks []doctorCheck
+	if resolveErr != nil {
+		checks = append(checks,
+			doctorCheck{Name: "configuration", Status: checkFail, Detail: errorDetail(resolveErr),
+				Hint: "fix the file named above, or inspect it with 'dot-ai config view'"},
+			skippedCheck("authentication", "the configuration could not be resolved"),
+			skippedCheck("server", "the configuration could not be resolved"),
+		)
+	} else {
+		checks = append(checks, checkConfiguration(c))
+		serverVersion, versionErr := compat.ServerVersion(c)
+		checks = append(checks, checkAuthentication(c, versionErr), checkServer(c, versionErr), checkTLS(c.ServerURL))
+		if isUnreachable(versionErr) {
+			checks = append(checks,
+				skippedCheck("server version", "the server is unreachable"),
+				skippedCheck("rbac", "the server is unreachable"))
+		} else {
+			checks = append(checks, checkServerVersion(serverVersion, versionErr), checkRBAC(c))
+		}
+	}
+	return append(checks, checkSkillsDirs(), checkSkillsHook(), checkGit())
+}
+
+// errorDetail renders err on one line for a check's detail.
+func errorDetail(err error) string {
+	msg := strings.TrimSpace(err.Error())
+	if len(msg) >= 6 && strings.EqualFold(msg[:6], "Error:") {
+		msg = strings.TrimSpace(msg[6:])
+	}
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+func skippedCheck(name, reason string) doctorCheck {
+	return doctorCheck{Name: name, Status: checkWarn, Detail: "not checked: " + reason}
+}
+
+// isUnreachable reports whether err is a connection failure rather than an
+// HTTP error response.
+func isUnreachable(err error) bool {
+	var reqErr *client.RequestError
+	return errors.As(err, &reqErr) && reqErr.ExitCode == client.ExitConnError
+}
+
+// isRejected reports whether err is the server refusing the credentials.
+func isRejected(err error) bool {
+	var reqErr *client.RequestError
+	return errors.As(err, &reqErr) && (reqErr.Status == 401 || reqErr.Status == 403)
+}
+
+func checkConfiguration(c *config.Config) doctorCheck {
+	detail := fmt.Sprintf("server %s (from %s)", c.ServerURL, c.ServerURLSource)
+	if c.TokenSource != config.TokenSourceNone {
+		detail += ", token from " + c.TokenSource
+	} else {
+		detail += ", no token"
+	}
+	if c.Context != "" {
+		detail += ", context " + c.Context
+	}
+	if c.ProjectFile != "" {
+		detail += ", project file " + c.ProjectFile
+	}
+	return doctorCheck{Name: "configuration", Status: checkPass, Detail: detail}
+}
+
+func checkAuthentication(c *config.Config, versionErr error) doctorCheck {
+	check := doctorCheck{Name: "authentication"}
+	switch {
+	case isRejected(versionErr):
+		check.Status, check.Detail = checkFail, "the server rejected the "+c.TokenSource+" token"
+		check.Hint = "run 'dot-ai auth login', or check the token passed with --token / DOT_AI_AUTH_TOKEN"
+	case c.Token == "":
+		check.Status, check.Detail = checkWarn, "no token: commands the server protects will fail"
+		check.Hint = "run 'dot-ai auth login', or pass --token / DOT_AI_AUTH_TOKEN"
+	case !c.TokenExpiresAt.IsZero() && time.Now().After(c.TokenExpiresAt) && !c.TokenRefreshable:
+		check.Status, check.Detail = checkFail, "the "+c.TokenSource+" token expired at "+c.TokenExpiresAt.Local().Format(time.RFC3339)
+		check.Hint = "run 'dot-ai auth login'"
+	default:
+		check.Status, check.Detail = checkPass, c.TokenSource+" token"
+		if !c.TokenExpiresAt.IsZero() {
+			check.Detail += ", expires " + c.TokenExpiresAt.Local().Format(time.RFC3339)
+			if c.TokenRefreshable {
+				check.Detail += " (renewed automatically)"
+			} else if time.Until(c.TokenExpiresAt) < 24*time.Hour {
+				check.Status = checkWarn
+				check.Hint = "run 'dot-ai auth login' before it expires"
+			}
+		}
+	}
+	return check
+}
+
+func checkServer(c *config.Config, versionErr error) doctorCheck {
+	check := doctorCheck{Name: "server"}
+	switch {
+	case isUnreachable(versionErr):
+		check.Status, check.Detail = checkFail, client.RedactCredentials(errorDetail(versionErr))
+		check.Hint = "check the server URL ('dot-ai config get server-url'), the network or VPN, and that the server is running"
+	case versionErr != nil && !isRejected(versionErr):
+		check.Status, check.Detail = checkWarn, "reachable, but "+compat.VersionPath+" failed: "+errorDetail(versionErr)
+		check.Hint = "check that " + c.ServerURL + " is a dot-ai server"
+	default:
+		check.Status, check.Detail = checkPass, "reachable at "+c.ServerURL
+	}
+	return check
+}
+
+// checkTLS verifies the server certificate with the system roots, as every
+// request does.
+func checkTLS(serverURL string) doctorCheck {
+	check := doctorCheck{Name: "tls"}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		check.Status, check.Detail = checkFail, err.Error()
+		return check
+	}
+	if u.Scheme != "https" {
+		if ip := net.ParseIP(u.Hostname()); u.Hostname() == "localhost" || (ip != nil && ip.IsLoopback()) {
+			check.Status, check.Detail = checkPass, "plain HTTP to a local server"
+		} else {
+			check.Status, check.Detail = checkWarn, "plain HTTP: tokens are sent unencrypted"
+			check.Hint = "use an https:// server URL"
+		}
+		return check
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	if err != nil {
+		check.Status, check.Detail = checkFail, errorDetail(err)
+		check.Hint = "the certificate must be valid for " + u.Hostname() + " and issued by a CA this machine trusts"
+		return check
+	}
+	defer conn.Close()
+	cert := conn.ConnectionState().PeerCertificates[0]
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("certificate valid until %s, issued by %s", cert.NotAfter.Local().Format(time.RFC3339), cert.Issuer.CommonName)
+	if time.Until(cert.NotAfter) < doctorCertWarning {
+		check.Status = checkWarn
+		check.Hint = "renew the server certificate before it expires"
+	}
+	return check
+}
+
+func checkServerVersion(serverVersion string, versionErr error) doctorCheck {
+	check := doctorCheck{Name: "server version"}
+	if versionErr != nil {
+		check.Status, check.Detail = checkWarn, "unknown: "+errorDetail(versionErr)
+		return check
+	}
+	server, err := compat.ParseVersion(serverVersion)
+	supported, rerr := compat.RangeFor(specVersion)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = checkWarn, "the server reports an unparseable version "+serverVersion
+	case rerr != nil:
+		check.Status, check.Detail = checkPass, "server "+server.String()+" ("+errorDetail(rerr)+")"
+	case !supported.Contains(server):
+		mismatch := &compat.MismatchError{Server: server, Supported: supported}
+		check.Status = checkWarn
+		check.Detail, check.Hint, _ = strings.Cut(mismatch.Error(), ": ")
+	default:
+		check.Status, check.Detail = checkPass, fmt.Sprintf("server %s, within the supported range %s", server, supported)
+	}
+	return check
+}
+
+// checkRBAC lists the tools the server allows. The server filters them for
+// OAuth sessions only; other tokens see every tool.
+func checkRBAC(c *config.Config) doctorCheck {
+	check := doctorCheck{Name: "rbac"}
+	tools, err := rbac.AllowedTools(c)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = checkWarn, "could not list tools: "+errorDetail(err)
+		check.Hint = "every command stays visible; the server enforces permissions when one is run"
+	case len(tools) == 0:
+		check.Status, check.Detail = checkWarn, "the server allows no tools"
+		check.Hint = "ask your administrator for access"
+	default:
+		check.Status = checkPass
+		check.Detail = fmt.Sprintf("%d tools allowed: %s", len(tools), strings.Join(tools, ", "))
+	}
+	return check
+}
+
+func checkSkillsDirs() doctorCheck {
+	var dirs []string
+	agents := make([]string, 0, len(skills.AgentDirs))
+	for agent := range skills.AgentDirs {
+		agents = append(agents, agent)
+	}
+	slices.Sort(agents)
+	for _, agent := range agents {
+		dirs = append(dirs, skills.AgentDirs[agent])
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".claude", "skills"))
+	}
+
+	var found []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return doctorCheck{Name: "skills", Status: checkWarn, Detail: err.Error(), Hint: "check the permissions of " + dir}
+		}
+		n := 0
+		for _, e := range entries {
+			if e.IsDir() {
+				n++
+			}
+		}
+		found = append(found, fmt.Sprintf("%s (%d skills)", dir, n))
+	}
+	if len(found) == 0 {
+		return doctorCheck{Name: "skills", Status: checkPass, Detail: "no generated skills in this project or ~/.claude/skills"}
+	}
+	return doctorCheck{Name: "skills", Status: checkPass, Detail: strings.Join(found, ", ")}
+}
+
+// checkSkillsHook finds the SessionStart hooks in the project and user
+// Claude Code settings, and checks that the dot-ai binary they run is on
+// PATH.
+func checkSkillsHook() doctorCheck {
+	check := doctorCheck{Name: "skills hook"}
+	var installed []string
+	for _, global := range []bool{false, true} {
+		path, err := skills.ResolveSettingsPath(global)
+		if err != nil {
+			continue
+		}
+		hooks, err := skills.HookCommands(path)
+		if err != nil {
+			check.Status, check.Detail = checkWarn, errorDetail(err)
+			check.Hint = "fix the JSON in " + path
+			return check
+		}
+		if len(hooks) > 0 {
+			installed = append(installed, path)
+		}
+	}
+	if len(installed) == 0 {
+		check.Status, check.Detail = checkPass, "no SessionStart hook installed"
+		return check
+	}
+	check.Detail = "SessionStart hook in " + strings.Join(installed, ", ")
+	if _, err := exec.LookPath("dot-ai"); err != nil {
+		check.Status = checkFail
+		check.Detail += ", but dot-ai is not on PATH"
+		check.Hint = "install dot-ai on PATH so the hook can run it at session start"
+		return check
+	}
+	check.Status = checkPass
+	return check
+}
+
+func checkGit() doctorCheck {
+	check := doctorCheck{Name: "git"}
+	path, err := exec.LookPath("git")
+	if err != nil {
+		check.Status, check.Detail = checkWarn, "git not found"
+		check.Hint = "install git to use 'skills generate --repo-fetch'"
+		return check
+	}
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		check.Status, check.Detail = checkWarn, fmt.Sprintf("%s --version failed: %v", path, err)
+		return check
+	}
+	check.Status, check.Detail = checkPass, strings.TrimSpace(string(out))
+	return check
+}
+
+// printDoctorChecks writes one line per check, its hint indented below.
+func printDoctorChecks(out io.Writer, checks []doctorCheck) {
+	counts := map[string]int{}
+	for _, c := range checks {
+		counts[c.Status]++
+		fmt.Fprintf(out, "%-5s %-15s %s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+		if c.Hint != "" {
+			fmt.Fprintf(out, "%-21s hint: %s\n", "", c.Hint)
+		}
+	}
+	fmt.Fprintf(out, "\n%d passed, %d warned, %d failed\n", counts[checkPass], counts[checkWarn], counts[checkFail])
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/vfarcic/dot-ai-cli/internal/client"
-	"github.com/vfarcic/dot-ai-cli/internal/formatter"
 	"github.com/vfarcic/dot-ai-cli/internal/openapi"
 )
 
@@ -168,21 +167,21 @@ func buildCobraCommand(def openapi.CommandDef) *cobra.Command {
 				return fmt.Errorf("internal error: failed to parse param metadata: %w", err)
 			}
 
+			exps, err := parseExpectations(cmd)
+			if err != nil {
+				return err
+			}
+
 			resolved := resolveParams(cmd, args, params)
 
-			body, err := client.Do(GetConfig(), cmd.Annotations["method"], cmd.Annotations["path"], resolved)
+			resp, err := client.DoResponse(GetConfig(), cmd.Annotations["method"], cmd.Annotations["path"], resolved)
 			if err != nil {
 				return err
 			}
-
-			if len(body) > 0 {
-				formatted, fmtErr := formatter.Format(body, GetConfig().OutputFormat)
-				if fmtErr != nil {
-					return fmtErr
-				}
-				fmt.Fprintln(cmd.OutOrStdout(), formatted)
+			if err := writeResponse(cmd, resp); err != nil {
+				return err
 			}
-			return nil
+			return checkExpectations(cmd, resp, exps)
 		},
 	}
 
@@ -190,8 +189,11 @@ func buildCobraCommand(def openapi.CommandDef) *cobra.Command {
 	for _, p := range flags {
 		registerFlag(cmd, p)
 	}
+	registerOutputFlags(cmd)
+	registerExpectFlags(cmd)
 
-	// Add enum validation.
+	// Add enum validation. It runs after resolveFlags, so values from the
+	// environment and settings are validated too.
 	enums := collectEnumFlags(flags)
 	if len(enums) > 0 {
 		cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/client"
+	"github.com/vfarcic/dot-ai-cli/internal/expect"
+	"github.com/vfarcic/dot-ai-cli/internal/formatter"
+)
+
+// registerExpectFlags adds the CI assertion flags to a dynamic command.
+func registerExpectFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("expect", nil, "Assert on the JSON response: '<path> <op> <value>' with op one of ==, !=, >, <, exists, empty, matches (repeatable); exits 4 when any fails")
+	cmd.Flags().Bool("fail-on-empty", false, "Exit 4 when the response's list payload is empty")
+}
+
+// parseExpectations parses the --expect flags up front, so a malformed
+// assertion is a usage error reported before any request is sent.
+func parseExpectations(cmd *cobra.Command) ([]expect.Expectation, error) {
+	raw, _ := cmd.Flags().GetStringArray("expect")
+	exps := make([]expect.Expectation, 0, len(raw))
+	for _, r := range raw {
+		e, err := expect.Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		exps = append(exps, e)
+	}
+	return exps, nil
+}
+
+// checkExpectations evaluates --expect and --fail-on-empty against a response
+// that has already been written. Failures return a RequestError carrying
+// ExitExpectationFailed and a listing of each failed expectation with the
+// value actually found.
+func checkExpectations(cmd *cobra.Command, resp *client.Response, exps []expect.Expectation) error {
+	failOnEmpty, _ := cmd.Flags().GetBool("fail-on-empty")
+	if len(exps) == 0 && !failOnEmpty {
+		return nil
+	}
+	if !resp.IsJSON() {
+		return fmt.Errorf("--expect and --fail-on-empty need a JSON response, got %s", resp.ContentType)
+	}
+
+	if failOnEmpty {
+		recs, err := formatter.FindRecords(resp.Body)
+		if err != nil {
+			return &client.RequestError{
+				Message:  "--fail-on-empty: " + err.Error(),
+				ExitCode: client.ExitExpectationFailed,
+			}
+		}
+		if len(recs) == 0 {
+			return &client.RequestError{
+				Message:  "--fail-on-empty: the response list is empty",
+				ExitCode: client.ExitExpectationFailed,
+			}
+		}
+	}
+
+	failures, err := expect.Evaluate(resp.Body, exps)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return &client.RequestError{
+			Message:  expect.Report(failures, len(exps)),
+			ExitCode: client.ExitExpectationFailed,
+		}
+	}
+	return nil
+}
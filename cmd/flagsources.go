@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envAnnotation holds, on a flag, the environment variable bound to it by
+// bindFlagEnv.
+const envAnnotation = "dot_ai_env"
+
+// ownEnvAnnotation marks a flag that reads its own environment variable
+// where it is used (see markOwnEnv); bindFlagEnv leaves it alone.
+const ownEnvAnnotation = "dot_ai_own_env"
+
+// markOwnEnv records that flag in fs is resolved against env by the code
+// that uses it, so no DOT_AI_<COMMAND>_<FLAG> variable is bound to it.
+func markOwnEnv(fs *pflag.FlagSet, flag, env string) {
+	fs.SetAnnotation(flag, ownEnvAnnotation, []string{env})
+}
+
+// flagEnvName is the environment variable for flag on the command at path
+// below the root: DOT_AI_<COMMAND>_<FLAG>, e.g. DOT_AI_KNOWLEDGE_ASK_LIMIT.
+// Flags of the root command itself use DOT_AI_<FLAG>.
+func flagEnvName(path, flag string) string {
+	name := "DOT_AI_"
+	if path != "" {
+		name += path + "_"
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", ".", "_").Replace(name + flag))
+}
+
+// bindFlagEnv binds an environment variable to every flag of cmd and its
+// subcommands, and names it in the flag's help. Flags marked with markOwnEnv
+// keep their own variable: they are resolved where they are used.
+func bindFlagEnv(cmd *cobra.Command) {
+	path := ""
+	if cmd.HasParent() {
+		path = commandPath(cmd)
+	}
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" || f.Name == "version" || len(f.Annotations[ownEnvAnnotation]) > 0 {
+			return
+		}
+		name := flagEnvName(path, f.Name)
+		if f.Annotations == nil {
+			f.Annotations = map[string][]string{}
+		}
+		f.Annotations[envAnnotation] = []string{name}
+		f.Usage += " (env: " + name + ")"
+	})
+	for _, sub := range cmd.Commands() {
+		bindFlagEnv(sub)
+	}
+}
+
+// resolveFlags fills in each flag of cmd not given on the command line, with
+// the precedence flag > environment (bindFlagEnv) > settings (the defaults of
+// server commands, .dot-ai.yaml over settings.json) > the flag's default.
+// Resolved flags count as changed, so server commands send them like typed
+// ones.
+func resolveFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || len(f.Annotations[envAnnotation]) == 0 {
+			return
+		}
+		name := f.Annotations[envAnnotation][0]
+		if v := os.Getenv(name); v != "" {
+			if setErr := cmd.Flags().Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("invalid value %q in %s: %w", v, name, setErr)
+			}
+		}
+	})
+	if err != nil || cmd.Annotations["method"] == "" {
+		return err
+	}
+	return applyFlagDefaults(cmd)
+}
+
+// applyFlagDefaults sets each flag of cmd still unset to its default from
+// the settings layers. Layers are read in precedence order and a flag set by
+// one is changed for the next, so .dot-ai.yaml wins over settings.json.
+//
+// Only defaults validateFlagDefault accepts are applied: never a global flag
+// such as --server-url or --token (by now the configuration is resolved and
+// a token chosen for the server's origin) nor one of noDefaultFlags.
+func applyFlagDefaults(cmd *cobra.Command) error {
+	layers, err := loadSettingsLayers()
+	if err != nil {
+		return err
+	}
+	path := commandPath(cmd)
+	for _, l := range layers {
+		for flag, value := range l.Settings.Defaults[path] {
+			key := defaultsKeyName(path, flag)
+			if err := validateFlagDefault(key, value); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: ignoring %s from %s: %v\n", key, l.Origin, err)
+				continue
+			}
+			if cmd.LocalFlags().Lookup(flag).Changed {
+				continue
+			}
+			if err := cmd.Flags().Set(flag, value); err != nil {
+				return fmt.Errorf("invalid default for --%s (%s in %s): %w", flag, key, l.Origin, err)
+			}
+		}
+	}
+	return nil
+}
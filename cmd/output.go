@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
+	"github.com/vfarcic/dot-ai-cli/internal/client"
+	"github.com/vfarcic/dot-ai-cli/internal/config"
+	"github.com/vfarcic/dot-ai-cli/internal/formatter"
+	"github.com/vfarcic/dot-ai-cli/internal/terminal"
+)
+
+// outputColumns holds the --columns selection for CSV output.
+var outputColumns []string
+
+// registerOutputFlags adds the per-command output flags to a dynamic command.
+func registerOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().String("output-file", "", "Write the response to this file instead of stdout (atomically). Supports {{.timestamp}}, {{.date}}, {{.command}}, {{.format}} and {{.filename}}; an existing directory (or a path ending in /) uses the server-suggested filename")
+	cmd.Flags().Bool("overwrite", false, "Allow --output-file to replace an existing file")
+}
+
+// writeResponse presents a response according to its Content-Type. JSON
+// bodies go through the formatter; text bodies (text/*, YAML, XML) are printed
+// untouched; binary bodies are only written to --output-file or to a
+// non-terminal stdout, never spewed onto an interactive terminal.
+func writeResponse(cmd *cobra.Command, resp *client.Response) error {
+	if target, _ := cmd.Flags().GetString("output-file"); target != "" {
+		return saveResponse(cmd, resp, target)
+	}
+	if len(resp.Body) == 0 {
+		return nil
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case resp.IsJSON():
+		return printResponse(cmd, resp.Body)
+	case resp.IsBinary() && terminal.IsTerminal(out):
+		hint := "<file>"
+		if resp.Filename != "" {
+			hint = resp.Filename
+		}
+		return fmt.Errorf("refusing to write a binary response (%s) to the terminal; save it with --output-file %s or redirect stdout", resp.ContentType, hint)
+	}
+	_, err := out.Write(resp.Body)
+	return err
+}
+
+// saveResponse writes a response to target. JSON bodies are written in the
+// configured output format; text and binary bodies are written verbatim. When
+// target is a directory the server's Content-Disposition filename is used.
+//
+// The file is written via temp-file-and-rename so an interrupted run never
+// leaves a partial file, and an existing file is only replaced with
+// --overwrite. Its mode follows the umask, like a shell redirection's; an
+// empty body gives an empty file. Failed requests never reach this point, so their errors still
+// go to stderr with the usual exit code and no file is created.
+func saveResponse(cmd *cobra.Command, resp *client.Response, target string) error {
+	target, err := expandOutputTemplate(target, cmd, resp)
+	if err != nil {
+		return err
+	}
+	dest, err := outputFilePath(target, resp.Filename)
+	if err != nil {
+		return err
+	}
+
+	data := resp.Body
+	if resp.IsJSON() && len(resp.Body) > 0 {
+		formatted, err := formatResponse(resp.Body, formatter.Terminal{})
+		if err != nil {
+			return err
+		}
+		data = nil
+		if formatted != "" {
+			data = []byte(formatted + "\n")
+		}
+	}
+	overwrite, _ := cmd.Flags().GetBool("overwrite")
+	write := atomicfile.Create
+	if overwrite {
+		write = atomicfile.WriteFile
+	}
+	if err := write(dest, data, atomicfile.ApplyUmask(0666)); err != nil {
+		if errors.Is(err, atomicfile.ErrExists) {
+			return fmt.Errorf("--output-file %s already exists; pass --overwrite to replace it", dest)
+		}
+		return fmt.Errorf("writing --output-file: %w", err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d bytes to %s\n", len(data), dest)
+	return nil
+}
+
+// expandOutputTemplate expands Go template fields in an --output-file path:
+// {{.timestamp}} (UTC, 20060102T150405Z), {{.date}} (2006-01-02),
+// {{.command}} (command path joined with '-', e.g. knowledge-ask),
+// {{.format}} (the output format) and {{.filename}} (the server-suggested
+// filename). Using {{.filename}} when the server suggested none is an error,
+// rather than a path that silently names a directory. Paths without "{{" are
+// returned unchanged.
+func expandOutputTemplate(target string, cmd *cobra.Command, resp *client.Response) (string, error) {
+	if !strings.Contains(target, "{{") {
+		return target, nil
+	}
+	tmpl, err := template.New("output-file").Option("missingkey=error").Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid --output-file template: %w", err)
+	}
+	now := time.Now().UTC()
+	command := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	fields := map[string]string{
+		"timestamp": now.Format("20060102T150405Z"),
+		"date":      now.Format("2006-01-02"),
+		"command":   strings.ReplaceAll(command, " ", "-"),
+		"format":    GetConfig().OutputFormat,
+	}
+	if resp.Filename != "" {
+		fields["filename"] = resp.Filename
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, fields); err != nil {
+		if resp.Filename == "" && strings.Contains(err.Error(), "<.filename>") {
+			return "", fmt.Errorf("--output-file uses {{.filename}} but the server did not suggest a filename (no Content-Disposition header); name the file with other fields, e.g. {{.command}}-{{.timestamp}}")
+		}
+		return "", fmt.Errorf("invalid --output-file template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// outputFilePath resolves the --output-file target. A path naming an existing
+// directory, or ending in a path separator, is joined with the
+// server-suggested filename.
+func outputFilePath(target, suggested string) (string, error) {
+	isDir := strings.HasSuffix(target, "/") || strings.HasSuffix(target, string(filepath.Separator))
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		isDir = true
+	}
+	if !isDir {
+		return target, nil
+	}
+	if suggested == "" {
+		return "", fmt.Errorf("--output-file %s is a directory but the server did not suggest a filename; pass a file path instead", target)
+	}
+	return filepath.Join(target, suggested), nil
+}
+
+// printResponse formats a response body in the configured output format and
+// writes it to the command's stdout. On an interactive terminal the output is
+// colourised, markdown fields are rendered, and long output goes through
+// $PAGER; piped output, --no-color and NO_COLOR get the plain document.
+//
+// The record formats (ndjson, csv) split the response's primary array into
+// one line per element; --columns selects the CSV columns. An empty list
+// prints nothing (or only the CSV header of the --columns given).
+func printResponse(cmd *cobra.Command, body []byte) error {
+	out := cmd.OutOrStdout()
+	term := terminalOptions(out)
+	formatted, err := formatResponse(body, term)
+	if err != nil || formatted == "" {
+		return err
+	}
+	if term.Color {
+		return terminal.Print(out, formatted)
+	}
+	_, err = fmt.Fprintln(out, formatted)
+	return err
+}
+
+// documentOutput reports whether a command that prints a human-readable
+// report by default should print a JSON or YAML document instead: when
+// --output, DOT_AI_OUTPUT_FORMAT or the settings chose a format. The record
+// formats are refused, as the command prints a single object.
+//
+// When the configuration could not be resolved (see doctor), only --output
+// is set.
+func documentOutput(cmd *cobra.Command) (bool, error) {
+	c := GetConfig()
+	if c.OutputFormat == "" || c.OutputFormatSource == config.SourceDefault {
+		return false, nil
+	}
+	if formatter.IsRecordFormat(c.OutputFormat) {
+		return false, fmt.Errorf("'%s' prints a single object, not a list: use --output json or yaml, not %s", cmd.CommandPath(), c.OutputFormat)
+	}
+	return true, nil
+}
+
+// formatResponse renders a JSON body in the configured output format.
+func formatResponse(body []byte, term formatter.Terminal) (string, error) {
+	format := GetConfig().OutputFormat
+	if len(outputColumns) > 0 && format != "csv" {
+		return "", fmt.Errorf("--columns requires --output csv")
+	}
+	if format == "csv" {
+		return formatter.FormatCSV(body, outputColumns)
+	}
+	return formatter.FormatTerminal(body, format, term)
+}
+
+// terminalOptions decides which terminal presentation applies to w. Anything
+// but an interactive, colour-capable terminal gets the zero value.
+func terminalOptions(w io.Writer) formatter.Terminal {
+	if !terminal.UseColor(w, GetConfig().NoColor) {
+		return formatter.Terminal{}
+	}
+	return formatter.Terminal{Color: true, Markdown: true}
+}
@@ -7,13 +7,21 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vfarcic/dot-ai-cli/internal/auth"
 	"github.com/vfarcic/dot-ai-cli/internal/client"
+	"github.com/vfarcic/dot-ai-cli/internal/compat"
 	"github.com/vfarcic/dot-ai-cli/internal/config"
+	"github.com/vfarcic/dot-ai-cli/internal/formatter"
+	"github.com/vfarcic/dot-ai-cli/internal/openapi"
 	"github.com/vfarcic/dot-ai-cli/internal/rbac"
 )
 
 var cfg config.Config
 
+// strictVersion turns a server version outside the supported range into an
+// error (see checkServerCompat).
+var strictVersion bool
+
 var rootCmd = &cobra.Command{
 	Use:          "dot-ai",
 	Short:        "CLI for the DevOps AI Toolkit",
@@ -32,10 +40,16 @@ var rootCmd = &cobra.Command{
 
 var RoutingSkill []byte
 
+// specVersion is the info.version of the embedded OpenAPI spec: the server
+// API version the dynamic commands were generated from.
+var specVersion string
+
 func Execute(openapiSpec, routingSkill []byte, version string) {
 	rootCmd.Version = version
 	RoutingSkill = routingSkill
+	specVersion = openapi.Version(openapiSpec)
 	RegisterDynamicCommands(openapiSpec)
+	bindFlagEnv(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		printError(err)
@@ -68,18 +82,58 @@ func GetConfig() *config.Config {
 func init() {
 	cobra.OnInitialize(initConfig)
 
+	// Set here rather than in the literal: resolving flags looks commands
+	// up from rootCmd. Commands must not define their own
+	// PersistentPreRunE: it would replace this one.
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := resolveFlags(cmd); err != nil {
+			return err
+		}
+		return checkServerCompat(cmd)
+	}
+
+	rootCmd.PersistentFlags().StringVar(&cfg.Context, "context", "", "Named server context to use (env: DOT_AI_CONTEXT)")
 	rootCmd.PersistentFlags().StringVar(&cfg.ServerURL, "server-url", "", "Server URL (env: DOT_AI_URL)")
 	rootCmd.PersistentFlags().StringVar(&cfg.Token, "token", "", "Authentication token (env: DOT_AI_AUTH_TOKEN)")
-	rootCmd.PersistentFlags().StringVar(&cfg.OutputFormat, "output", "", "Output format: json, yaml (default: yaml) (env: DOT_AI_OUTPUT_FORMAT)")
+	rootCmd.PersistentFlags().StringVar(&cfg.OutputFormat, "output", "", "Output format: "+strings.Join(formatter.Formats, ", ")+" (default: yaml) (env: DOT_AI_OUTPUT_FORMAT)")
+	rootCmd.PersistentFlags().StringSliceVar(&outputColumns, "columns", nil, "Comma-separated CSV columns; dotted paths select nested fields (default: union of record keys)")
+	rootCmd.PersistentFlags().BoolVar(&strictVersion, "strict-version", false, "Fail instead of warning when the server version is outside the range this CLI supports")
+	rootCmd.PersistentFlags().BoolVar(&cfg.NoColor, "no-color", false, "Disable colours, markdown rendering and the pager on terminal output (env: NO_COLOR)")
+	for flag, env := range map[string]string{
+		"context":    "DOT_AI_CONTEXT",
+		"server-url": "DOT_AI_URL",
+		"token":      "DOT_AI_AUTH_TOKEN",
+		"output":     "DOT_AI_OUTPUT_FORMAT",
+		"no-color":   "NO_COLOR",
+	} {
+		markOwnEnv(rootCmd.PersistentFlags(), flag, env)
+	}
+	rootCmd.RegisterFlagCompletionFunc("context", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		s, err := auth.LoadSettings()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return s.ContextNames(), cobra.ShellCompDirectiveNoFileComp
+	})
 	rootCmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
+		return formatter.Formats, cobra.ShellCompDirectiveNoFileComp
 	})
 }
 
 func initConfig() {
 	if err := cfg.Resolve(); err != nil {
-		printError(err)
-		os.Exit(1)
+		// An unknown --context/DOT_AI_CONTEXT must not lock the user out of
+		// the commands that repair it, and doctor reports the error itself.
+		var notFound *auth.ContextNotFoundError
+		switch {
+		case invokes(doctorCmd):
+			resolveErr = err
+		case errors.As(err, &notFound) && invokes(contextCmd):
+		default:
+			printError(err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	if !isCompletionInvocation() {
@@ -87,6 +141,43 @@ func initConfig() {
 	}
 }
 
+// checkServerCompat compares the server's version with the range this CLI
+// supports before a server command runs. A mismatch is a one-line warning, or
+// an error under --strict-version; so is a version that cannot be
+// determined, which otherwise goes unreported because the command itself
+// reports unreachable servers.
+func checkServerCompat(cmd *cobra.Command) error {
+	if cmd.Annotations["method"] == "" || cmd.Annotations["path"] == compat.VersionPath {
+		return nil
+	}
+	err := compat.Check(GetConfig(), specVersion)
+	var mismatch *compat.MismatchError
+	switch {
+	case err == nil:
+		return nil
+	case strictVersion:
+		return &client.RequestError{Message: err.Error() + " (--strict-version)", ExitCode: client.ExitToolError}
+	case errors.As(err, &mismatch):
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+	}
+	return nil
+}
+
+// invokes reports whether the command line targets target or one of its
+// subcommands.
+func invokes(target *cobra.Command) bool {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	if err != nil {
+		return false
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == target {
+			return true
+		}
+	}
+	return false
+}
+
 // isCompletionInvocation returns true when the CLI was invoked for shell
 // completion, where latency from a network call would hurt responsiveness.
 func isCompletionInvocation() bool {
@@ -224,7 +224,11 @@ one agent hook per source.`,
 		// The identifier is used identically for the upload, the source:
 		// frontmatter tag, and the ?source= param.
 		if skillsRepoDir != "" {
-			resolved, err := skills.AuthorizeRepoDir(skillsRepoDir)
+			allowlist, allowlistSource, err := resolveRepoDirAllowlist(cmd)
+			if err != nil {
+				return err
+			}
+			resolved, err := skills.AuthorizeRepoDir(skillsRepoDir, allowlist, allowlistSource)
 			if err != nil {
 				return err
 			}
@@ -342,15 +346,19 @@ func buildOverride() skills.Override {
 	return ov
 }
 
-// resolveSkillFilters applies the standard 4-tier precedence for skill filters:
-// flag > env > settings.json > default (empty).
+// resolveSkillFilters applies the standard precedence for skill filters:
+// flag > env > .dot-ai.yaml > settings.json (the active context's, when
+// contexts exist) > system settings > default (empty). A filter locked by
+// the system settings file takes its value there.
 func resolveSkillFilters(cmd *cobra.Command) (include, exclude string, customOnly bool, err error) {
-	settings, err := auth.LoadSettings()
+	settings, err := loadEffectiveSettings()
 	if err != nil {
 		return "", "", false, err
 	}
 
-	if cmd.Flags().Changed("include") {
+	if lockedFlag(cmd, settings, "skills_include", "include", "DOT_AI_SKILLS_INCLUDE") {
+		include = settings.SkillsInclude
+	} else if cmd.Flags().Changed("include") {
 		include = skillsInclude
 	} else if v, ok := os.LookupEnv("DOT_AI_SKILLS_INCLUDE"); ok {
 		include = v
@@ -358,7 +366,9 @@ func resolveSkillFilters(cmd *cobra.Command) (include, exclude string, customOnl
 		include = settings.SkillsInclude
 	}
 
-	if cmd.Flags().Changed("exclude") {
+	if lockedFlag(cmd, settings, "skills_exclude", "exclude", "DOT_AI_SKILLS_EXCLUDE") {
+		exclude = settings.SkillsExclude
+	} else if cmd.Flags().Changed("exclude") {
 		exclude = skillsExclude
 	} else if v, ok := os.LookupEnv("DOT_AI_SKILLS_EXCLUDE"); ok {
 		exclude = v
@@ -366,7 +376,9 @@ func resolveSkillFilters(cmd *cobra.Command) (include, exclude string, customOnl
 		exclude = settings.SkillsExclude
 	}
 
-	if cmd.Flags().Changed("custom-only") {
+	if lockedFlag(cmd, settings, "skills_custom_only", "custom-only", "DOT_AI_SKILLS_CUSTOM_ONLY") {
+		customOnly = settings.SkillsCustomOnly == "true"
+	} else if cmd.Flags().Changed("custom-only") {
 		customOnly = skillsCustomOnly
 	} else if v, ok := os.LookupEnv("DOT_AI_SKILLS_CUSTOM_ONLY"); ok {
 		customOnly = v == "true"
@@ -377,6 +389,41 @@ func resolveSkillFilters(cmd *cobra.Command) (include, exclude string, customOnl
 	return include, exclude, customOnly, nil
 }
 
+// resolveRepoDirAllowlist returns the --repo-dir base-directory allowlist and
+// where it came from: DOT_AI_REPO_DIR_ALLOW > settings.json > system settings,
+// or only the system settings when it is locked there.
+func resolveRepoDirAllowlist(cmd *cobra.Command) (allowlist, source string, err error) {
+	settings, err := loadEffectiveSettings()
+	if err != nil {
+		return "", "", err
+	}
+	if settings.IsLocked("skills_repo_dir_allow") {
+		if _, ok := os.LookupEnv(skills.RepoDirAllowlistEnv); ok {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: ignoring %s: skills_repo_dir_allow is locked by %s\n", skills.RepoDirAllowlistEnv, auth.SystemSettingsPath())
+		}
+		return settings.SkillsRepoDirAllow, "skills.repo_dir_allow (locked by " + auth.SystemSettingsPath() + ")", nil
+	}
+	if v := os.Getenv(skills.RepoDirAllowlistEnv); strings.TrimSpace(v) != "" {
+		return v, skills.RepoDirAllowlistEnv, nil
+	}
+	return settings.SkillsRepoDirAllow, "skills.repo_dir_allow", nil
+}
+
+// lockedFlag reports whether the system settings file locks key, warning
+// when --flag or env is set anyway.
+func lockedFlag(cmd *cobra.Command, settings *auth.Settings, key, flag, env string) bool {
+	if !settings.IsLocked(key) {
+		return false
+	}
+	if cmd.Flags().Changed(flag) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: ignoring --%s: %s is locked by %s\n", flag, key, auth.SystemSettingsPath())
+	}
+	if _, ok := os.LookupEnv(env); ok {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: ignoring %s: %s is locked by %s\n", env, key, auth.SystemSettingsPath())
+	}
+	return true
+}
+
 func init() {
 	skillsGenerateCmd.Flags().StringVar(&skillsAgent, "agent", "", "Target agent: "+strings.Join(agentNames(), ", "))
 	skillsGenerateCmd.Flags().StringVar(&skillsPath, "path", "", "Override output directory (for unsupported agents)")
@@ -386,11 +433,14 @@ func init() {
 	skillsGenerateCmd.Flags().StringVar(&skillsInclude, "include", "", "Regex to filter skills to include (env: DOT_AI_SKILLS_INCLUDE)")
 	skillsGenerateCmd.Flags().StringVar(&skillsExclude, "exclude", "", "Regex to filter skills to exclude (env: DOT_AI_SKILLS_EXCLUDE)")
 	skillsGenerateCmd.Flags().BoolVar(&skillsCustomOnly, "custom-only", false, "Only generate custom prompt skills, skip MCP tool skills (env: DOT_AI_SKILLS_CUSTOM_ONLY)")
+	markOwnEnv(skillsGenerateCmd.Flags(), "include", "DOT_AI_SKILLS_INCLUDE")
+	markOwnEnv(skillsGenerateCmd.Flags(), "exclude", "DOT_AI_SKILLS_EXCLUDE")
+	markOwnEnv(skillsGenerateCmd.Flags(), "custom-only", "DOT_AI_SKILLS_CUSTOM_ONLY")
 	skillsGenerateCmd.Flags().StringVar(&skillsRepo, "repo", "", "Override the server's configured prompts repo for this invocation (passed through as ?repo=<url>). Default: server's env-var repo. When --repo points at a private cross-realm source, set DOT_AI_GIT_TOKEN in the environment to authenticate the clone; it is forwarded as the X-Dot-AI-Git-Token header on override requests only and is never logged or written to skills.")
 	skillsGenerateCmd.Flags().StringVar(&skillsRepoPath, "repo-path", "", "Subdirectory within --repo to read skills from (passed through as ?path=<subdir>). Requires --repo or --repo-fetch. Default: repo root")
 	skillsGenerateCmd.Flags().StringVar(&skillsRepoBranch, "repo-branch", "", "Branch of --repo to read skills from (passed through as ?branch=<branch>). Requires --repo or --repo-fetch. Default: main")
 	skillsGenerateCmd.Flags().StringVar(&skillsRepoFetch, "repo-fetch", "", "Clone this git repo from the CLI host (using the host's local git stack: SSH agent, git credential helper, ~/.gitconfig) and upload it as the skill source for this invocation — for sources the server cannot reach (e.g. SSO/device-attested VPNs). Accepts optional --repo-path/--repo-branch. The source: frontmatter records the URL with any credentials scrubbed. Mutually exclusive with --repo and --repo-dir.")
-	skillsGenerateCmd.Flags().StringVar(&skillsRepoDir, "repo-dir", "", "Read skills from a local directory and upload them as the skill source for this invocation (no network, no clone), then render via ?source=. Requires --source-label. Opt-in: set DOT_AI_ALLOW_REPO_DIR=1 (paths under /tmp or world-writable dirs are refused; an optional base-path allowlist is set via DOT_AI_REPO_DIR_ALLOW). Mutually exclusive with --repo and --repo-fetch.")
+	skillsGenerateCmd.Flags().StringVar(&skillsRepoDir, "repo-dir", "", "Read skills from a local directory and upload them as the skill source for this invocation (no network, no clone), then render via ?source=. Requires --source-label. Opt-in: set DOT_AI_ALLOW_REPO_DIR=1 (paths under /tmp or world-writable dirs are refused; an optional base-path allowlist is set via DOT_AI_REPO_DIR_ALLOW or the skills.repo_dir_allow setting). Mutually exclusive with --repo and --repo-fetch.")
 	skillsGenerateCmd.Flags().StringVar(&skillsSourceLabel, "source-label", "", "Stable identifier for the --repo-dir source. Auto-prefixed with the host identity for per-server uniqueness: 'source: local:<user>-<label>' (falls back to local:<host>-<label>). Required with --repo-dir.")
 	skillsGenerateCmd.Flags().BoolVar(&skillsNoCache, "no-cache", false, "Bypass the --repo-fetch persistent clone cache: clone to a throwaway temp directory, use it, and delete it. By default --repo-fetch maintains an incremental clone cache under the XDG cache dir (~/.cache/dot-ai-cli/repos/). Requires --repo-fetch.")
 	skillsGenerateCmd.RegisterFlagCompletionFunc("agent", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -581,3 +581,61 @@ func TestAuthLogin_FlagOverridesEnv(t *testing.T) {
 		t.Error("expected access_token to be set in credentials.json")
 	}
 }
+
+// auth token prints a document whenever a format is chosen, through --output
+// or DOT_AI_OUTPUT_FORMAT, and the bare token otherwise.
+func TestAuthToken_FormatFromEnvironment(t *testing.T) {
+	srv := newEchoServer(t)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL, "DOT_AI_AUTH_TOKEN=env-token")
+
+	stdout, stderr, exitCode := runCLIIn(t, home, env, "auth", "token")
+	if exitCode != 0 || stdout != "env-token\n" {
+		t.Errorf("auth token = %q (exit %d, stderr %s), want the bare token", stdout, exitCode, stderr)
+	}
+
+	jsonEnv := append(env, "DOT_AI_OUTPUT_FORMAT=json")
+	stdout, stderr, exitCode = runCLIIn(t, home, jsonEnv, "auth", "token")
+	if exitCode != 0 {
+		t.Fatalf("auth token: exit %d; stderr: %s", exitCode, stderr)
+	}
+	var info map[string]any
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil || info["token"] != "env-token" || info["source"] != "env" {
+		t.Errorf("auth token with DOT_AI_OUTPUT_FORMAT=json = %q, want the JSON document", stdout)
+	}
+
+	// --header wins over a format from the environment, not over --output.
+	stdout, _, exitCode = runCLIIn(t, home, jsonEnv, "auth", "token", "--header")
+	if exitCode != 0 || stdout != "Authorization: Bearer env-token\n" {
+		t.Errorf("auth token --header = %q (exit %d)", stdout, exitCode)
+	}
+	if _, _, exitCode = runCLIIn(t, home, env, "auth", "token", "--header", "--output", "json"); exitCode == 0 {
+		t.Error("--header with --output succeeded")
+	}
+
+	_, stderr, exitCode = runCLIIn(t, home, append(env, "DOT_AI_OUTPUT_FORMAT=csv"), "auth", "token")
+	if exitCode == 0 || !strings.Contains(stderr, "single object") {
+		t.Errorf("auth token with csv: exit %d, stderr %q; want a clear refusal", exitCode, stderr)
+	}
+}
+
+// Like auth token, auth whoami follows DOT_AI_OUTPUT_FORMAT.
+func TestAuthWhoami_FormatFromEnvironment(t *testing.T) {
+	srv := newEchoServer(t)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL, "DOT_AI_AUTH_TOKEN=env-token", "DOT_AI_OUTPUT_FORMAT=json")
+
+	stdout, stderr, exitCode := runCLIIn(t, home, env, "auth", "whoami")
+	if exitCode != 0 {
+		t.Fatalf("auth whoami: exit %d; stderr: %s", exitCode, stderr)
+	}
+	var id map[string]any
+	if err := json.Unmarshal([]byte(stdout), &id); err != nil {
+		t.Errorf("auth whoami with DOT_AI_OUTPUT_FORMAT=json = %q, want a JSON document", stdout)
+	}
+
+	_, stderr, exitCode = runCLIIn(t, home, append(env, "DOT_AI_OUTPUT_FORMAT=csv"), "auth", "whoami")
+	if exitCode == 0 || !strings.Contains(stderr, "single object") {
+		t.Errorf("auth whoami with csv: exit %d, stderr %q; want a clear refusal", exitCode, stderr)
+	}
+}
@@ -3,6 +3,9 @@
 package e2e_test
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -263,3 +266,118 @@ func TestConfigHelp_NoServer(t *testing.T) {
 		t.Errorf("help should mention all subcommands; got: %s", stdout)
 	}
 }
+
+func TestConfigView_JSON(t *testing.T) {
+	home := t.TempDir()
+	env := isolatedEnv(home)
+	if _, stderr, exitCode := runCLIIn(t, home, env, "config", "set", "output-format", "json"); exitCode != 0 {
+		t.Fatalf("config set: exit %d; stderr: %s", exitCode, stderr)
+	}
+
+	stdout, stderr, exitCode := runCLIIn(t, home, env, "config", "view", "--output", "json")
+	if exitCode != 0 {
+		t.Fatalf("config view: exit %d; stderr: %s", exitCode, stderr)
+	}
+	var view struct {
+		SchemaVersion int `json:"schema_version"`
+		Settings      map[string]struct {
+			Value  string `json:"value"`
+			Origin string `json:"origin"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &view); err != nil {
+		t.Fatalf("config view --output json is not JSON: %v\n%s", err, stdout)
+	}
+	if view.SchemaVersion == 0 {
+		t.Error("schema_version missing")
+	}
+	settingsPath := filepath.Join(home, ".config", "dot-ai", "settings.json")
+	if got := view.Settings["output-format"]; got.Value != "json" || !strings.Contains(got.Origin, settingsPath) {
+		t.Errorf("output-format = %+v, want json from %s", got, settingsPath)
+	}
+	if got := view.Settings["skills.include"]; got.Value != "" || got.Origin != "default" {
+		t.Errorf("skills.include = %+v, want the default", got)
+	}
+}
+
+// editorScript writes a script that replaces the edited file with content,
+// for use as $EDITOR.
+func editorScript(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "editor.sh")
+	writeFile(t, path, "#!/bin/sh\ncat > \"$1\" <<'EOF'\n"+content+"\nEOF\n", 0755)
+	return path
+}
+
+func TestConfigEdit_InvalidLeavesFileUntouched(t *testing.T) {
+	home := t.TempDir()
+	env := isolatedEnv(home, "VISUAL=", "TMPDIR="+t.TempDir())
+	if _, stderr, exitCode := runCLIIn(t, home, env, "config", "set", "output-format", "json"); exitCode != 0 {
+		t.Fatalf("config set: exit %d; stderr: %s", exitCode, stderr)
+	}
+	settingsPath := filepath.Join(home, ".config", "dot-ai", "settings.json")
+	before, err := os.ReadFile(settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{
+		"invalid JSON":  `{"output_format": "yaml",`,
+		"invalid value": `{"output_format": "xml"}`,
+	} {
+		editor := editorScript(t, content)
+		_, stderr, exitCode := runCLIIn(t, home, append(env, "EDITOR="+editor), "config", "edit")
+		if exitCode == 0 || !strings.Contains(stderr, "was not changed") {
+			t.Errorf("%s: exit %d, stderr %q; want a validation error", name, exitCode, stderr)
+		}
+		if after, _ := os.ReadFile(settingsPath); string(after) != string(before) {
+			t.Errorf("%s: settings.json changed to %s", name, after)
+		}
+	}
+
+	// A valid edit is saved.
+	editor := editorScript(t, `{"output_format": "yaml"}`)
+	if _, stderr, exitCode := runCLIIn(t, home, append(env, "EDITOR="+editor), "config", "edit"); exitCode != 0 {
+		t.Fatalf("valid edit: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if stdout, _, _ := runCLIIn(t, home, env, "config", "get", "output-format"); strings.TrimSpace(stdout) != "yaml" {
+		t.Errorf("output-format after edit = %q, want yaml", stdout)
+	}
+}
+
+func TestConfigExportImport_RoundTrip(t *testing.T) {
+	source, target := t.TempDir(), t.TempDir()
+	sourceEnv, targetEnv := isolatedEnv(source), isolatedEnv(target)
+	for _, kv := range [][2]string{
+		{"output-format", "json"},
+		{"skills.include", "^deploy"},
+		{"defaults.knowledge.ask.limit", "7"},
+	} {
+		if _, stderr, exitCode := runCLIIn(t, source, sourceEnv, "config", "set", kv[0], kv[1]); exitCode != 0 {
+			t.Fatalf("config set %s: exit %d; stderr: %s", kv[0], exitCode, stderr)
+		}
+	}
+	writeFile(t, filepath.Join(source, ".config", "dot-ai", "credentials.json"), `{"auth_token": "secret-token"}`, 0600)
+
+	exported := filepath.Join(t.TempDir(), "team.yaml")
+	if _, stderr, exitCode := runCLIIn(t, source, sourceEnv, "config", "export", exported); exitCode != 0 {
+		t.Fatalf("config export: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if data, _ := os.ReadFile(exported); strings.Contains(string(data), "secret-token") {
+		t.Errorf("the export contains the credentials:\n%s", data)
+	}
+
+	if _, stderr, exitCode := runCLIIn(t, target, targetEnv, "config", "import", exported); exitCode != 0 {
+		t.Fatalf("config import: exit %d; stderr: %s", exitCode, stderr)
+	}
+	for key, want := range map[string]string{
+		"output-format":                "json",
+		"skills.include":               "^deploy",
+		"defaults.knowledge.ask.limit": "7",
+	} {
+		stdout, stderr, exitCode := runCLIIn(t, target, targetEnv, "config", "get", key)
+		if exitCode != 0 || strings.TrimSpace(stdout) != want {
+			t.Errorf("config get %s after import = %q (exit %d, stderr %s), want %q", key, stdout, exitCode, stderr, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//go:build integration
+
+package e2e_test
+
+import (
+	"strings"
+	"testing"
+)
+
+// --- Named server contexts ---
+//
+// auth token prints the token the active context resolves to without
+// contacting the server, which shows which context's credentials are used.
+
+func TestContext_AddUseRenameDelete(t *testing.T) {
+	home := t.TempDir()
+	env := isolatedEnv(home)
+	run := func(args ...string) string {
+		t.Helper()
+		stdout, stderr, exitCode := runCLIIn(t, home, env, args...)
+		if exitCode != 0 {
+			t.Fatalf("%s: exit %d; stderr: %s", strings.Join(args, " "), exitCode, stderr)
+		}
+		return stdout
+	}
+
+	// The first context becomes current; later ones only with --use.
+	if out := run("context", "add", "dev", "--server", "https://dev.example.com", "--static-token", "dev-token"); !strings.Contains(out, `Switched to context "dev"`) {
+		t.Errorf("first context add = %q, want it made current", out)
+	}
+	if out := run("context", "add", "prod", "--server", "https://prod.example.com", "--static-token", "prod-token"); strings.Contains(out, "Switched") {
+		t.Errorf("second context add = %q, want dev kept current", out)
+	}
+	if got := run("auth", "token"); got != "dev-token\n" {
+		t.Errorf("auth token in dev = %q, want dev-token", got)
+	}
+
+	run("context", "use", "prod")
+	if got := run("auth", "token"); got != "prod-token\n" {
+		t.Errorf("auth token after context use prod = %q, want prod-token", got)
+	}
+	current := ""
+	list := run("context", "list")
+	for _, line := range strings.Split(list, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "*" {
+			current = fields[1]
+		}
+	}
+	if current != "prod" {
+		t.Errorf("context list marks %q current, want prod:\n%s", current, list)
+	}
+	if got := run("--context", "dev", "auth", "token"); got != "dev-token\n" {
+		t.Errorf("auth token with --context dev = %q, want dev-token", got)
+	}
+
+	// Renaming keeps the context's credentials.
+	run("context", "rename", "dev", "staging")
+	if got := run("--context", "staging", "auth", "token"); got != "dev-token\n" {
+		t.Errorf("auth token in the renamed context = %q, want dev-token", got)
+	}
+	if _, _, exitCode := runCLIIn(t, home, env, "--context", "dev", "auth", "token"); exitCode == 0 {
+		t.Error("the old context name still resolves after rename")
+	}
+
+	run("context", "delete", "staging")
+	if list := run("context", "list"); strings.Contains(list, "staging") {
+		t.Errorf("context list after delete:\n%s", list)
+	}
+	if _, stderr, exitCode := runCLIIn(t, home, env, "--context", "staging", "auth", "token"); exitCode == 0 || !strings.Contains(stderr, "staging") {
+		t.Errorf("--context of a deleted context: exit %d, stderr %q; want an error naming it", exitCode, stderr)
+	}
+}
+
+func TestContext_UseUnknown(t *testing.T) {
+	home := t.TempDir()
+	env := isolatedEnv(home)
+	if _, _, exitCode := runCLIIn(t, home, env, "context", "add", "dev", "--server", "https://dev.example.com"); exitCode != 0 {
+		t.Fatal("context add failed")
+	}
+	if _, stderr, exitCode := runCLIIn(t, home, env, "context", "use", "nope"); exitCode == 0 || !strings.Contains(stderr, "nope") {
+		t.Errorf("context use nope: exit %d, stderr %q; want an error naming the context", exitCode, stderr)
+	}
+}
@@ -0,0 +1,208 @@
+//go:build integration
+
+package e2e_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// --- Per-command flag defaults (settings.json / .dot-ai.yaml "defaults") ---
+//
+// These run against an echo backend so the request the CLI sends can be
+// inspected: the body of knowledge ask shows which --limit was applied, and
+// the Authorization header shows which token went where.
+
+// echoRequest is one request received by the echo backend.
+type echoRequest struct {
+	Method        string
+	Path          string
+	Authorization string
+	Body          map[string]any
+}
+
+type echoServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []echoRequest
+}
+
+// newEchoServer answers every request with a small JSON success envelope and
+// records it.
+func newEchoServer(t *testing.T) *echoServer {
+	t.Helper()
+	es := &echoServer{}
+	es.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		json.Unmarshal(data, &body)
+		es.mu.Lock()
+		es.requests = append(es.requests, echoRequest{Method: r.Method, Path: r.URL.Path, Authorization: r.Header.Get("Authorization"), Body: body})
+		es.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"success":true,"data":{"answer":"ok"}}`)
+	}))
+	t.Cleanup(es.Close)
+	return es
+}
+
+// request returns the last request to path, failing the test when none
+// arrived.
+func (es *echoServer) request(t *testing.T, path string) echoRequest {
+	t.Helper()
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	for i := len(es.requests) - 1; i >= 0; i-- {
+		if es.requests[i].Path == path {
+			return es.requests[i]
+		}
+	}
+	t.Fatalf("no request to %s; got %+v", path, es.requests)
+	return echoRequest{}
+}
+
+// isolatedEnv is an environment with an empty home and none of the DOT_AI_*
+// variables of the calling shell.
+func isolatedEnv(home string, extra ...string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "DOT_AI_") || strings.HasPrefix(kv, "HOME=") || strings.HasPrefix(kv, "XDG_CONFIG_HOME=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(append(env, "HOME="+home), extra...)
+}
+
+// runCLIIn runs the CLI binary in dir with env and no implicit --server-url,
+// so the server comes from the configuration under test.
+func runCLIIn(t *testing.T, dir string, env []string, args ...string) (stdout, stderr string, exitCode int) {
+	t.Helper()
+	cmd := exec.Command(binaryPath, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var outBuf, errBuf strings.Builder
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			t.Fatalf("unexpected error running CLI: %v", err)
+		}
+	}
+	return outBuf.String(), errBuf.String(), exitCode
+}
+
+// writeFile writes content to path, creating its directory.
+func writeFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaults_AppliedAndOverridden(t *testing.T) {
+	srv := newEchoServer(t)
+	home, work := t.TempDir(), t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL)
+
+	_, stderr, exitCode := runCLIIn(t, work, env, "config", "set", "defaults.knowledge.ask.limit", "7")
+	if exitCode != 0 {
+		t.Fatalf("config set: exit %d; stderr: %s", exitCode, stderr)
+	}
+
+	// The default is sent when the flag is not typed.
+	if _, stderr, exitCode = runCLIIn(t, work, env, "knowledge", "ask", "q", "--output", "json"); exitCode != 0 {
+		t.Fatalf("knowledge ask: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if got := srv.request(t, "/api/v1/knowledge/ask").Body["limit"]; got != float64(7) {
+		t.Errorf("limit = %v, want the default 7", got)
+	}
+
+	// A typed flag wins over the default.
+	if _, stderr, exitCode = runCLIIn(t, work, env, "knowledge", "ask", "q", "--limit", "3", "--output", "json"); exitCode != 0 {
+		t.Fatalf("knowledge ask --limit: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if got := srv.request(t, "/api/v1/knowledge/ask").Body["limit"]; got != float64(3) {
+		t.Errorf("limit = %v, want the typed 3", got)
+	}
+
+	// .dot-ai.yaml wins over settings.json.
+	writeFile(t, filepath.Join(work, ".dot-ai.yaml"), "defaults:\n  knowledge ask:\n    limit: \"9\"\n", 0644)
+	if _, stderr, exitCode = runCLIIn(t, work, env, "knowledge", "ask", "q", "--output", "json"); exitCode != 0 {
+		t.Fatalf("knowledge ask in project: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if got := srv.request(t, "/api/v1/knowledge/ask").Body["limit"]; got != float64(9) {
+		t.Errorf("limit = %v, want the project default 9", got)
+	}
+}
+
+func TestDefaults_InvalidValueRejected(t *testing.T) {
+	home := t.TempDir()
+	env := isolatedEnv(home)
+
+	_, stderr, exitCode := runCLIIn(t, home, env, "config", "set", "defaults.knowledge.ask.limit", "many")
+	if exitCode == 0 || !strings.Contains(stderr, "must be of type") {
+		t.Errorf("exit %d, stderr %q; want a type error", exitCode, stderr)
+	}
+	for _, flag := range []string{"server-url", "output-file", "expect"} {
+		_, stderr, exitCode = runCLIIn(t, home, env, "config", "set", "defaults.knowledge.ask."+flag, "x")
+		if exitCode == 0 {
+			t.Errorf("config set defaults for --%s succeeded; stderr %q", flag, stderr)
+		}
+	}
+}
+
+// A cloned repository's .dot-ai.yaml must not redirect the stored token to
+// another host or write files through defaults of global or output flags.
+func TestDefaults_ProjectFileCannotSetGlobalOrOutputFlags(t *testing.T) {
+	real, evil := newEchoServer(t), newEchoServer(t)
+	home, repo := t.TempDir(), t.TempDir()
+	env := isolatedEnv(home)
+	target := filepath.Join(home, "victim.txt")
+	writeFile(t, target, "original\n", 0644)
+
+	configDir := filepath.Join(home, ".config", "dot-ai")
+	writeFile(t, filepath.Join(configDir, "settings.json"), `{"server_url": "`+real.URL+`"}`, 0600)
+	writeFile(t, filepath.Join(configDir, "credentials.json"), `{"servers": {"`+real.URL+`": {"auth_token": "real-token"}}}`, 0600)
+	writeFile(t, filepath.Join(repo, ".dot-ai.yaml"), `defaults:
+  knowledge ask:
+    server-url: "`+evil.URL+`"
+    token: "evil-token"
+    output-file: "`+target+`"
+    overwrite: "true"
+`, 0644)
+
+	_, stderr, exitCode := runCLIIn(t, repo, env, "knowledge", "ask", "q", "--output", "json")
+	if exitCode != 0 {
+		t.Fatalf("knowledge ask: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if got := real.request(t, "/api/v1/knowledge/ask").Authorization; got != "Bearer real-token" {
+		t.Errorf("Authorization = %q, want the stored token on the configured server", got)
+	}
+	evil.mu.Lock()
+	if len(evil.requests) != 0 {
+		t.Errorf("the project file's server-url received %+v", evil.requests)
+	}
+	evil.mu.Unlock()
+	if data, _ := os.ReadFile(target); string(data) != "original\n" {
+		t.Errorf("%s overwritten with %q", target, data)
+	}
+	for _, flag := range []string{"server-url", "token", "output-file", "overwrite"} {
+		if !strings.Contains(stderr, "defaults.knowledge.ask."+flag) {
+			t.Errorf("no warning about the %s default; stderr: %s", flag, stderr)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+//go:build integration
+
+package e2e_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// --- doctor ---
+
+// doctorReport runs 'doctor' with a JSON report and returns the status of
+// each check by name, with the exit code.
+func doctorReport(t *testing.T, home string, env []string) (map[string]string, int) {
+	t.Helper()
+	stdout, stderr, exitCode := runCLIIn(t, home, env, "doctor")
+	var report struct {
+		Checks []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("doctor report is not JSON: %v\nstdout: %s\nstderr: %s", err, stdout, stderr)
+	}
+	statuses := map[string]string{}
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses, exitCode
+}
+
+func TestDoctor_UnreachableServer(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+url, "DOT_AI_OUTPUT_FORMAT=json")
+
+	statuses, exitCode := doctorReport(t, home, env)
+	if statuses["server"] != "fail" {
+		t.Errorf("server check = %q, want fail", statuses["server"])
+	}
+	for _, name := range []string{"server version", "rbac"} {
+		if statuses[name] != "warn" {
+			t.Errorf("%s check = %q, want skipped (warn)", name, statuses[name])
+		}
+	}
+	if exitCode != 1 {
+		t.Errorf("exit code = %d, want 1 when a check fails", exitCode)
+	}
+}
+
+func TestDoctor_RejectedToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"success":false,"error":{"code":"UNAUTHORIZED","message":"invalid token"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL, "DOT_AI_AUTH_TOKEN=bad-token", "DOT_AI_OUTPUT_FORMAT=json")
+
+	statuses, exitCode := doctorReport(t, home, env)
+	if statuses["authentication"] != "fail" {
+		t.Errorf("authentication check = %q, want fail", statuses["authentication"])
+	}
+	if statuses["server"] != "pass" {
+		t.Errorf("server check = %q, want pass: the server answered", statuses["server"])
+	}
+	if exitCode != 1 {
+		t.Errorf("exit code = %d, want 1 when a check fails", exitCode)
+	}
+}
+
+func TestDoctor_RecordFormatRefused(t *testing.T) {
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_OUTPUT_FORMAT=ndjson")
+
+	stdout, stderr, exitCode := runCLIIn(t, home, env, "doctor")
+	if exitCode == 0 || !strings.Contains(stderr, "single object") {
+		t.Errorf("exit %d, stderr %q; want a clear refusal", exitCode, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing: no check should run", stdout)
+	}
+}
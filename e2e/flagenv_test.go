@@ -0,0 +1,93 @@
+//go:build integration
+
+package e2e_test
+
+import (
+	"strings"
+	"testing"
+)
+
+// --- DOT_AI_<COMMAND>_<FLAG> environment variables ---
+
+func TestFlagEnv_Precedence(t *testing.T) {
+	srv := newEchoServer(t)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL)
+
+	if _, stderr, exitCode := runCLIIn(t, home, env, "config", "set", "defaults.knowledge.ask.limit", "7"); exitCode != 0 {
+		t.Fatalf("config set: exit %d; stderr: %s", exitCode, stderr)
+	}
+	withEnv := append(env, "DOT_AI_KNOWLEDGE_ASK_LIMIT=5")
+
+	// The variable wins over the stored default.
+	if _, stderr, exitCode := runCLIIn(t, home, withEnv, "knowledge", "ask", "q", "--output", "json"); exitCode != 0 {
+		t.Fatalf("knowledge ask: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if got := srv.request(t, "/api/v1/knowledge/ask").Body["limit"]; got != float64(5) {
+		t.Errorf("limit = %v, want 5 from DOT_AI_KNOWLEDGE_ASK_LIMIT", got)
+	}
+
+	// The flag wins over the variable.
+	if _, stderr, exitCode := runCLIIn(t, home, withEnv, "knowledge", "ask", "q", "--limit", "3", "--output", "json"); exitCode != 0 {
+		t.Fatalf("knowledge ask --limit: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if got := srv.request(t, "/api/v1/knowledge/ask").Body["limit"]; got != float64(3) {
+		t.Errorf("limit = %v, want 3 from --limit", got)
+	}
+}
+
+func TestFlagEnv_InvalidValue(t *testing.T) {
+	srv := newEchoServer(t)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL, "DOT_AI_KNOWLEDGE_ASK_LIMIT=many")
+
+	_, stderr, exitCode := runCLIIn(t, home, env, "knowledge", "ask", "q")
+	if exitCode == 0 {
+		t.Fatal("expected a non-zero exit for an unparseable variable")
+	}
+	if !strings.Contains(stderr, "DOT_AI_KNOWLEDGE_ASK_LIMIT") {
+		t.Errorf("stderr = %q, want the variable named", stderr)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, r := range srv.requests {
+		if r.Path == "/api/v1/knowledge/ask" {
+			t.Error("the request was sent despite the invalid variable")
+		}
+	}
+}
+
+// Flags that read their own variable get no DOT_AI_<COMMAND>_<FLAG> one.
+func TestFlagEnv_OwnVariablesExempt(t *testing.T) {
+	real, other := newEchoServer(t), newEchoServer(t)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+real.URL, "DOT_AI_SERVER_URL="+other.URL, "DOT_AI_SKILLS_GENERATE_INCLUDE=x")
+
+	if _, stderr, exitCode := runCLIIn(t, home, env, "namespaces", "--output", "json"); exitCode != 0 {
+		t.Fatalf("namespaces: exit %d; stderr: %s", exitCode, stderr)
+	}
+	real.request(t, "/api/v1/namespaces")
+	other.mu.Lock()
+	if len(other.requests) != 0 {
+		t.Errorf("DOT_AI_SERVER_URL was bound to --server-url: %+v", other.requests)
+	}
+	other.mu.Unlock()
+
+	stdout, _, _ := runCLIIn(t, home, env, "--help")
+	if !strings.Contains(stdout, "(env: DOT_AI_URL)") || strings.Contains(stdout, "DOT_AI_SERVER_URL") {
+		t.Errorf("root help should name only DOT_AI_URL for --server-url:\n%s", stdout)
+	}
+	stdout, _, _ = runCLIIn(t, home, env, "skills", "generate", "--help")
+	if !strings.Contains(stdout, "(env: DOT_AI_SKILLS_INCLUDE)") || strings.Contains(stdout, "DOT_AI_SKILLS_GENERATE_INCLUDE") {
+		t.Errorf("skills generate help should name only DOT_AI_SKILLS_INCLUDE for --include:\n%s", stdout)
+	}
+
+	// A flag whose help mentions other variables is still bound.
+	stdout, _, _ = runCLIIn(t, home, env, "auth", "login", "--help")
+	if !strings.Contains(stdout, "DOT_AI_AUTH_LOGIN_CLIENT_CREDENTIALS") {
+		t.Errorf("auth login help lacks DOT_AI_AUTH_LOGIN_CLIENT_CREDENTIALS:\n%s", stdout)
+	}
+	if strings.Contains(stdout, "DOT_AI_AUTH_LOGIN_TOKEN_TTL") {
+		t.Errorf("--token-ttl reads DOT_AI_TOKEN_TTL_SECONDS only:\n%s", stdout)
+	}
+}
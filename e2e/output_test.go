@@ -0,0 +1,69 @@
+//go:build integration
+
+package e2e_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- Response output ---
+
+// newJSONServer answers every request with body.
+func newJSONServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOutput_EmptyListRecordFormats(t *testing.T) {
+	srv := newJSONServer(t, `{"success":true,"data":{"namespaces":[]}}`)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL)
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--output", "ndjson"}, ""},
+		{[]string{"--output", "csv"}, ""},
+		{[]string{"--output", "csv", "--columns", "name,status"}, "name,status\n"},
+	} {
+		stdout, stderr, exitCode := runCLIIn(t, home, env, append([]string{"namespaces"}, tc.args...)...)
+		if exitCode != 0 {
+			t.Fatalf("namespaces %v: exit %d; stderr: %s", tc.args, exitCode, stderr)
+		}
+		if stdout != tc.want {
+			t.Errorf("namespaces %v = %q, want %q", tc.args, stdout, tc.want)
+		}
+	}
+}
+
+func TestOutputFile_EmptyBodyReported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL)
+	dest := filepath.Join(home, "out.json")
+
+	_, stderr, exitCode := runCLIIn(t, home, env, "namespaces", "--output-file", dest)
+	if exitCode != 0 {
+		t.Fatalf("namespaces: exit %d; stderr: %s", exitCode, stderr)
+	}
+	if data, err := os.ReadFile(dest); err != nil || len(data) != 0 {
+		t.Errorf("%s = %q (%v), want an empty file", dest, data, err)
+	}
+	if !strings.Contains(stderr, "Wrote 0 bytes to "+dest) {
+		t.Errorf("stderr = %q, want the save reported", stderr)
+	}
+}
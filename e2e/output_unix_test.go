@@ -0,0 +1,32 @@
+//go:build integration && unix
+
+package e2e_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOutputFile_FollowsUmask(t *testing.T) {
+	srv := newJSONServer(t, `{"success":true,"data":{"namespaces":["default"]}}`)
+	home := t.TempDir()
+	env := isolatedEnv(home, "DOT_AI_URL="+srv.URL)
+	dest := filepath.Join(home, "out.json")
+
+	// The binary inherits the umask of the test process.
+	old := syscall.Umask(0027)
+	defer syscall.Umask(old)
+	_, stderr, exitCode := runCLIIn(t, home, env, "namespaces", "--output", "json", "--output-file", dest)
+	if exitCode != 0 {
+		t.Fatalf("namespaces: exit %d; stderr: %s", exitCode, stderr)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0640 {
+		t.Errorf("permissions = %o under umask 027, want 0640", perm)
+	}
+}
@@ -0,0 +1,61 @@
+package atomicfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+	if err := WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := WriteFile(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new" {
+		t.Errorf("content = %q, want %q", got, "new")
+	}
+	info, _ := os.Stat(path)
+	if perm := info.Mode().Perm(); perm != 0644 {
+		t.Errorf("permissions = %o, want 0644", perm)
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+func TestCreateRefusesExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := Create(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	err := Create(path, []byte("second"), 0644)
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("Create over existing file: err = %v, want ErrExists", err)
+	}
+	got, _ := os.ReadFile(path)
+	if string(got) != "first" {
+		t.Errorf("existing file was modified: %q", got)
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only the target file, found %v", names)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package atomicfile
+
+import "os"
+
+// ApplyUmask returns perm unchanged: platforms without Unix file modes have
+// no umask.
+func ApplyUmask(perm os.FileMode) os.FileMode { return perm }
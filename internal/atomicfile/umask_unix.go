@@ -0,0 +1,21 @@
+//go:build unix
+
+package atomicfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// ApplyUmask returns perm without the bits masked by the process umask: the
+// mode os.WriteFile would give a new file. WriteFile and Create set perm
+// exactly, so callers writing user documents (rather than private state)
+// pass ApplyUmask(0666).
+//
+// Reading the umask means setting it, so it is restored at once; the CLI
+// creates no files concurrently with this.
+func ApplyUmask(perm os.FileMode) os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return perm &^ os.FileMode(mask)
+}
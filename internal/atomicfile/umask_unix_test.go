@@ -0,0 +1,27 @@
+//go:build unix
+
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestApplyUmask(t *testing.T) {
+	old := syscall.Umask(0027)
+	defer syscall.Umask(old)
+
+	if got := ApplyUmask(0666); got != 0640 {
+		t.Errorf("ApplyUmask(0666) = %o with umask 027, want 0640", got)
+	}
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := Create(path, []byte("x"), ApplyUmask(0666)); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if perm := info.Mode().Perm(); perm != 0640 {
+		t.Errorf("permissions = %o, want 0640", perm)
+	}
+}
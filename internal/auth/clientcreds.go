@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// GrantClientCredentials marks a stored session obtained with the OAuth
+// client-credentials grant. Such sessions hold no refresh token: a new access
+// token is requested with the client's credentials whenever it expires.
+const GrantClientCredentials = "client_credentials"
+
+// ClientCredentials identifies a confidential OAuth client (a CI service
+// account).
+type ClientCredentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// LoadClientCredentials reads a client's credentials from file, a JSON
+// document with client_id and client_secret, or, when file is empty, from the
+// DOT_AI_CLIENT_ID and DOT_AI_CLIENT_SECRET environment variables.
+func LoadClientCredentials(file string) (*ClientCredentials, error) {
+	var cc ClientCredentials
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading client credentials: %w", err)
+		}
+		if err := json.Unmarshal(data, &cc); err != nil {
+			return nil, fmt.Errorf("parsing client credentials file %s: %w", file, err)
+		}
+		if cc.ClientID == "" || cc.ClientSecret == "" {
+			return nil, fmt.Errorf("client credentials file %s must set client_id and client_secret", file)
+		}
+		return &cc, nil
+	}
+	cc.ClientID = os.Getenv("DOT_AI_CLIENT_ID")
+	cc.ClientSecret = os.Getenv("DOT_AI_CLIENT_SECRET")
+	if cc.ClientID == "" || cc.ClientSecret == "" {
+		return nil, fmt.Errorf("client credentials not found: set DOT_AI_CLIENT_ID and DOT_AI_CLIENT_SECRET, or pass --client-credentials-file")
+	}
+	return &cc, nil
+}
+
+// ClientCredentialsToken requests an access token with the client-credentials
+// grant.
+func ClientCredentialsToken(meta *ServerMetadata, cc *ClientCredentials, requestedExpiry int) (*tokenResponse, error) {
+	data := url.Values{
+		"grant_type":    {GrantClientCredentials},
+		"client_id":     {cc.ClientID},
+		"client_secret": {cc.ClientSecret},
+	}
+	if requestedExpiry > 0 {
+		data.Set("requested_expiry", strconv.Itoa(requestedExpiry))
+	}
+	return requestToken(meta.TokenEndpoint, data, "client-credentials token request")
+}
+
+// LoginClientCredentials obtains an access token with the client-credentials
+// grant and caches it, with its expiry, for the origin of the server URL.
+//
+// The client secret itself is never written to credentials.json: only the
+// client ID and, when the credentials came from a file, that file's path are
+// recorded, so an expired token is re-acquired from the same source (see
+// Refresh) and no permanent secret is left behind.
+func LoginClientCredentials(opts LoginOptions, file string) error {
+	if _, err := Origin(opts.ServerURL); err != nil {
+		return err
+	}
+	cc, err := LoadClientCredentials(file)
+	if err != nil {
+		return err
+	}
+	if file != "" {
+		if file, err = filepath.Abs(file); err != nil {
+			return err
+		}
+	}
+	meta, err := discover(opts.ServerURL, true)
+	if err != nil {
+		return err
+	}
+	if err := meta.requireGrant(GrantClientCredentials, "the client-credentials grant"); err != nil {
+		return err
+	}
+	tok, err := ClientCredentialsToken(meta, cc, opts.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	err = updateServerEntry(opts.ServerURL, opts.Context, func(cred *Credentials) {
+		cred.ClearOAuth()
+		cred.GrantType = GrantClientCredentials
+		cred.ClientID = cc.ClientID
+		cred.ClientSecretFile = file
+		cred.setToken(tok)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(opts.out(), "Authenticated as client %s.\n", cc.ClientID)
+	return nil
+}
+
+// reacquireClientCredentials requests a new access token for a stored
+// client-credentials session, reading the secret from the recorded file or
+// the environment.
+func reacquireClientCredentials(serverURL string, cred *Credentials) (*tokenResponse, error) {
+	cc, err := LoadClientCredentials(cred.ClientSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	if cc.ClientID != cred.ClientID {
+		return nil, fmt.Errorf("client credentials are for client %q but the session was created for %q; run 'dot-ai auth login --client-credentials' again", cc.ClientID, cred.ClientID)
+	}
+	meta, err := Discover(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	return ClientCredentialsToken(meta, cc, 0)
+}
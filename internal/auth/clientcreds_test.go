@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newClientCredentialsServer serves /token for the client-credentials grant,
+// accepting only the given client and numbering the tokens it issues.
+func newClientCredentialsServer(t *testing.T, clientID, secret string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/.well-known/") {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		if r.URL.Path != "/token" || r.Form.Get("grant_type") != "client_credentials" {
+			t.Errorf("unexpected token request %s %v", r.URL.Path, r.Form)
+		}
+		if r.Form.Get("client_id") != clientID || r.Form.Get("client_secret") != secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		n := calls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "cc-token-" + string(rune('0'+n)),
+			"token_type":   "Bearer",
+			"expires_in":   600,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestLoginClientCredentialsFromEnv(t *testing.T) {
+	srv, _ := newClientCredentialsServer(t, "ci-bot", "s3cret")
+	saveTestSession(t, srv.URL, Credentials{})
+	t.Setenv("DOT_AI_CLIENT_ID", "ci-bot")
+	t.Setenv("DOT_AI_CLIENT_SECRET", "s3cret")
+
+	var out strings.Builder
+	if err := LoginClientCredentials(LoginOptions{ServerURL: srv.URL, Out: &out}, ""); err != nil {
+		t.Fatalf("LoginClientCredentials: %v", err)
+	}
+	if !strings.Contains(out.String(), "ci-bot") {
+		t.Errorf("output = %q, want the client ID", out.String())
+	}
+
+	creds, err := LoadCredentials()
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	got := creds.Server(srv.URL)
+	if got == nil || got.AccessToken != "cc-token-1" || got.GrantType != GrantClientCredentials || got.ClientID != "ci-bot" {
+		t.Fatalf("stored entry = %+v", got)
+	}
+	if expiresWithin(got.ExpiresAt, 5*time.Minute) || expiresWithin(got.ExpiresAt, 0) {
+		t.Errorf("ExpiresAt = %q, want about 10 minutes ahead", got.ExpiresAt)
+	}
+
+	data, err := os.ReadFile(filepath.Join(ConfigDir(), "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("credentials.json contains the client secret:\n%s", data)
+	}
+}
+
+func TestRefreshReacquiresClientCredentialsFromFile(t *testing.T) {
+	srv, calls := newClientCredentialsServer(t, "ci-bot", "s3cret")
+	saveTestSession(t, srv.URL, Credentials{})
+
+	file := filepath.Join(t.TempDir(), "client.json")
+	os.WriteFile(file, []byte(`{"client_id":"ci-bot","client_secret":"s3cret"}`), 0600)
+	if err := LoginClientCredentials(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}}, file); err != nil {
+		t.Fatalf("LoginClientCredentials: %v", err)
+	}
+
+	// Expire the cached token; Refresh must request a new one with the
+	// credentials from the recorded file.
+	creds, _ := LoadCredentials()
+	creds.Server(srv.URL).ExpiresAt = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	creds.Save()
+
+	cred, err := Refresh(srv.URL, "", "")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if cred.AccessToken != "cc-token-2" || calls.Load() != 2 {
+		t.Errorf("AccessToken = %q after %d calls, want cc-token-2 after 2", cred.AccessToken, calls.Load())
+	}
+}
+
+func TestRefreshClientCredentialsMissingSecret(t *testing.T) {
+	srv, calls := newClientCredentialsServer(t, "ci-bot", "s3cret")
+	saveTestSession(t, srv.URL, Credentials{
+		AccessToken: "cc-token-old",
+		ExpiresAt:   time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+		ClientID:    "ci-bot",
+		GrantType:   GrantClientCredentials,
+	})
+	t.Setenv("DOT_AI_CLIENT_ID", "")
+	t.Setenv("DOT_AI_CLIENT_SECRET", "")
+
+	if _, err := Refresh(srv.URL, "", ""); err == nil || !strings.Contains(err.Error(), "DOT_AI_CLIENT_SECRET") {
+		t.Fatalf("Refresh error = %v, want missing client credentials", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("token endpoint called %d times, want 0", calls.Load())
+	}
+}
+
+func TestLoadClientCredentialsFileRequiresBothFields(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "client.json")
+	os.WriteFile(file, []byte(`{"client_id":"ci-bot"}`), 0600)
+	if _, err := LoadClientCredentials(file); err == nil {
+		t.Fatal("expected an error for a file without client_secret")
+	}
+}
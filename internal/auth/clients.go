@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RegisteredClient is an OAuth client the CLI registered dynamically
+// (RFC 7591). Registrations are kept per server, apart from the session, and
+// reused by later logins instead of leaving one orphaned client on the
+// server per login.
+type RegisteredClient struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	GrantTypes   []string `json:"grant_types,omitempty"`
+	// RegistrationAccessToken and RegistrationClientURI let the CLI read and
+	// delete the registration (RFC 7592) when the server supports it.
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+	// LastUsedAt is the RFC 3339 time of the last login with the client.
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+// Manageable reports whether the server supports deleting the registration
+// (RFC 7592).
+func (rc *RegisteredClient) Manageable() bool {
+	return rc.RegistrationClientURI != "" && rc.RegistrationAccessToken != ""
+}
+
+// callbackPort returns the loopback port of the client's redirect URI, or 0
+// when it has none.
+func (rc *RegisteredClient) callbackPort() int {
+	for _, raw := range rc.RedirectURIs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() != "127.0.0.1" {
+			continue
+		}
+		if port, err := strconv.Atoi(u.Port()); err == nil {
+			return port
+		}
+	}
+	return 0
+}
+
+// registeredClients returns the stored registrations that allow grant, most
+// recently used first.
+func (c *Credentials) registeredClients(grant string) []*RegisteredClient {
+	var clients []*RegisteredClient
+	for _, rc := range c.Clients {
+		if rc != nil && slices.Contains(rc.GrantTypes, grant) {
+			clients = append(clients, rc)
+		}
+	}
+	slices.SortStableFunc(clients, func(a, b *RegisteredClient) int {
+		switch {
+		case a.LastUsedAt > b.LastUsedAt:
+			return -1
+		case a.LastUsedAt < b.LastUsedAt:
+			return 1
+		}
+		return 0
+	})
+	return clients
+}
+
+// rememberClient stores rc, replacing an earlier copy, and marks it used.
+func (c *Credentials) rememberClient(rc *RegisteredClient) {
+	c.forgetClient(rc.ClientID)
+	stored := *rc
+	stored.LastUsedAt = time.Now().UTC().Format(time.RFC3339)
+	c.Clients = append(c.Clients, &stored)
+}
+
+// forgetClient drops the registration of clientID.
+func (c *Credentials) forgetClient(clientID string) {
+	c.Clients = slices.DeleteFunc(c.Clients, func(rc *RegisteredClient) bool {
+		return rc == nil || rc.ClientID == clientID
+	})
+}
+
+// loadServerEntry returns the credentials stored for the origin of serverURL
+// in the named context, or an empty entry.
+func loadServerEntry(serverURL, contextName string) (*Credentials, error) {
+	creds, err := LoadCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("loading credentials: %w", err)
+	}
+	if entry := creds.For(contextName).Server(serverURL); entry != nil {
+		return entry, nil
+	}
+	return &Credentials{}, nil
+}
+
+// updateServerEntry applies update to the credentials stored for the origin
+// of serverURL in the named context and saves them, holding the credentials
+// lock so concurrent refreshes are not lost.
+func updateServerEntry(serverURL, contextName string, update func(cred *Credentials)) error {
+	return UpdateCredentials(func(creds *Credentials) error {
+		profile := creds.For(contextName)
+		cred, err := profile.ForServer(serverURL)
+		if err != nil {
+			return err
+		}
+		update(cred)
+		profile.Prune()
+		return nil
+	})
+}
+
+// obtainClient returns a stored registration to reuse, falling back to a new
+// one from register, which is stored straight away so it is reused even if
+// the login fails. A stored registration the server reports as deleted
+// (RFC 7592) is dropped. reused reports whether candidate was kept.
+func obtainClient(serverURL, contextName string, candidate *RegisteredClient, register func() (*RegisteredClient, error)) (rc *RegisteredClient, reused bool, err error) {
+	if candidate != nil {
+		if clientExists(candidate) {
+			return candidate, true, nil
+		}
+		if err := dropClient(serverURL, contextName, candidate.ClientID); err != nil {
+			return nil, false, err
+		}
+	}
+	rc, err = register()
+	if err != nil {
+		return nil, false, err
+	}
+	err = updateServerEntry(serverURL, contextName, func(cred *Credentials) { cred.rememberClient(rc) })
+	return rc, false, err
+}
+
+// dropClient forgets a stored registration.
+func dropClient(serverURL, contextName, clientID string) error {
+	return updateServerEntry(serverURL, contextName, func(cred *Credentials) { cred.forgetClient(clientID) })
+}
+
+// rejectedClientError explains a login that failed because the server no
+// longer accepts a reused registration, after it has been dropped.
+func rejectedClientError(err error) error {
+	return fmt.Errorf("%w\nThe server no longer accepts the stored client registration, so it was discarded. Run 'dot-ai auth login' again", err)
+}
+
+// callbackListener listens for the OAuth callback on 127.0.0.1. Without a
+// fixed port it first tries the ports of the stored registrations, most
+// recently used first, so their redirect URI matches again; it returns the
+// registration whose redirect URI the listener serves, or nil.
+func callbackListener(clients []*RegisteredClient, port int) (net.Listener, *RegisteredClient, error) {
+	if port == 0 {
+		for _, rc := range clients {
+			if p := rc.callbackPort(); p != 0 {
+				if listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", p)); err == nil {
+					return listener, rc, nil
+				}
+			}
+		}
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rc := range clients {
+		if port != 0 && rc.callbackPort() == port {
+			return listener, rc, nil
+		}
+	}
+	return listener, nil, nil
+}
+
+// clientExists asks the server whether a registration still exists
+// (RFC 7592 §2.1). Without client management, or when the server cannot be
+// asked, the registration is assumed to exist; the login reports it if not.
+func clientExists(rc *RegisteredClient) bool {
+	if !rc.Manageable() {
+		return true
+	}
+	status, err := manageClient(http.MethodGet, rc)
+	if err != nil {
+		return true
+	}
+	return status != http.StatusUnauthorized && status != http.StatusForbidden && status != http.StatusNotFound
+}
+
+// deleteClient deletes a registration on the server (RFC 7592 §2.3). A
+// registration the server no longer knows counts as deleted.
+func deleteClient(rc *RegisteredClient) error {
+	status, err := manageClient(http.MethodDelete, rc)
+	if err != nil {
+		return fmt.Errorf("deleting client %s: %w", rc.ClientID, err)
+	}
+	switch status {
+	case http.StatusOK, http.StatusNoContent, http.StatusUnauthorized, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("deleting client %s failed (%d)", rc.ClientID, status)
+	}
+}
+
+// manageClient sends a client configuration request and returns its status.
+func manageClient(method string, rc *RegisteredClient) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, rc.RegistrationClientURI, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+rc.RegistrationAccessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClientFunc(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// ClientsInfo describes the registrations stored for a server.
+type ClientsInfo struct {
+	Origin string
+	// Active is the client ID of the stored session, if any.
+	Active  string
+	Clients []*RegisteredClient
+}
+
+// Clients returns the client registrations stored for the origin of
+// serverURL in the named context.
+func Clients(serverURL, contextName string) (*ClientsInfo, error) {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := loadServerEntry(serverURL, contextName)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientsInfo{Origin: origin, Active: entry.ClientID, Clients: entry.Clients}, nil
+}
+
+// PrunedClient is the outcome of pruning one registration.
+type PrunedClient struct {
+	ClientID string
+	// Deleted reports whether the registration was deleted on the server
+	// and forgotten; otherwise Err or Skipped says why not.
+	Deleted bool
+	Skipped string
+	Err     error
+}
+
+// PruneClients deletes the stored registrations for the origin of serverURL
+// that the current session does not use, on the server (RFC 7592) and
+// locally. Registrations the server cannot delete are kept, since later
+// logins still reuse them.
+func PruneClients(serverURL, contextName string) ([]PrunedClient, error) {
+	info, err := Clients(serverURL, contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PrunedClient
+	var deleted []string
+	for _, rc := range info.Clients {
+		result := PrunedClient{ClientID: rc.ClientID}
+		switch {
+		case rc.ClientID == info.Active:
+			result.Skipped = "used by the current session"
+		case !rc.Manageable():
+			result.Skipped = "the server does not support client management (RFC 7592)"
+		default:
+			if result.Err = deleteClient(rc); result.Err == nil {
+				result.Deleted = true
+				deleted = append(deleted, rc.ClientID)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if len(deleted) > 0 {
+		err = updateServerEntry(serverURL, contextName, func(cred *Credentials) {
+			for _, id := range deleted {
+				cred.forgetClient(id)
+			}
+		})
+	}
+	return results, err
+}
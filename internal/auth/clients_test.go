@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newDeviceServer serves a device login that approves immediately. Clients
+// whose ID is listed in deleted are rejected as invalid_client.
+func newDeviceServer(t *testing.T, deleted map[string]bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var registrations atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if deleted[r.Form.Get("client_id")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		switch r.URL.Path {
+		case "/register":
+			n := registrations.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"client_id": fmt.Sprintf("cid-%d", n), "client_secret": "secret"})
+		case "/device/code":
+			json.NewEncoder(w).Encode(map[string]any{"device_code": "dev", "user_code": "CODE", "verification_uri": "https://example.com/device"})
+		case "/token":
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "token-" + r.Form.Get("client_id"), "expires_in": 3600})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	origSleep := sleepFunc
+	sleepFunc = func(time.Duration) {}
+	t.Cleanup(func() { sleepFunc = origSleep })
+	return srv, &registrations
+}
+
+func TestLoginDeviceReusesRegisteredClient(t *testing.T) {
+	deleted := map[string]bool{}
+	srv, registrations := newDeviceServer(t, deleted)
+	saveTestSession(t, srv.URL, Credentials{})
+
+	for range 2 {
+		if err := LoginDevice(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}}); err != nil {
+			t.Fatalf("LoginDevice: %v", err)
+		}
+		if _, err := Logout(srv.URL, ""); err != nil {
+			t.Fatalf("Logout: %v", err)
+		}
+	}
+	if n := registrations.Load(); n != 1 {
+		t.Errorf("registered %d clients, want 1 reused across logins", n)
+	}
+
+	// Once the server forgets the client, the next login registers anew.
+	deleted["cid-1"] = true
+	if err := LoginDevice(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}}); err != nil {
+		t.Fatalf("LoginDevice: %v", err)
+	}
+	info, err := Clients(srv.URL, "")
+	if err != nil {
+		t.Fatalf("Clients: %v", err)
+	}
+	if info.Active != "cid-2" || len(info.Clients) != 1 || info.Clients[0].ClientID != "cid-2" {
+		t.Errorf("clients = %+v, want only the new registration cid-2 in use", info)
+	}
+}
+
+func TestObtainClientDropsDeletedRegistration(t *testing.T) {
+	mgmt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer reg-token" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		http.NotFound(w, r)
+	}))
+	defer mgmt.Close()
+	saveTestSession(t, mgmt.URL, Credentials{})
+
+	stale := &RegisteredClient{
+		ClientID:                "old",
+		GrantTypes:              []string{deviceCodeGrantType},
+		RegistrationAccessToken: "reg-token",
+		RegistrationClientURI:   mgmt.URL + "/register/old",
+	}
+	if err := updateServerEntry(mgmt.URL, "", func(cred *Credentials) { cred.rememberClient(stale) }); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, reused, err := obtainClient(mgmt.URL, "", stale, func() (*RegisteredClient, error) {
+		return &RegisteredClient{ClientID: "new", GrantTypes: []string{deviceCodeGrantType}}, nil
+	})
+	if err != nil || reused || rc.ClientID != "new" {
+		t.Fatalf("obtainClient = %+v, %v, %v; want a new registration", rc, reused, err)
+	}
+	info, _ := Clients(mgmt.URL, "")
+	if len(info.Clients) != 1 || info.Clients[0].ClientID != "new" {
+		t.Errorf("stored clients = %+v, want only the new one", info.Clients)
+	}
+}
+
+func TestCallbackListenerReusesStoredPort(t *testing.T) {
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := probe.Addr().(*net.TCPAddr).Port
+	probe.Close()
+
+	rc := &RegisteredClient{ClientID: "cid", RedirectURIs: []string{fmt.Sprintf("http://127.0.0.1:%d/callback", port)}}
+	listener, got, err := callbackListener([]*RegisteredClient{rc}, 0)
+	if err != nil {
+		t.Fatalf("callbackListener: %v", err)
+	}
+	if got != rc || listener.Addr().(*net.TCPAddr).Port != port {
+		t.Errorf("listening on %v for %+v, want port %d for the stored client", listener.Addr(), got, port)
+	}
+
+	// With the port taken, a random port is used and no client matches.
+	other, got, err := callbackListener([]*RegisteredClient{rc}, 0)
+	listener.Close()
+	if err != nil {
+		t.Fatalf("callbackListener: %v", err)
+	}
+	defer other.Close()
+	if got != nil {
+		t.Errorf("matched %+v on a different port", got)
+	}
+}
+
+func TestPruneClients(t *testing.T) {
+	var deletes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		deletes = append(deletes, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	saveTestSession(t, srv.URL, Credentials{
+		AccessToken: "token",
+		ClientID:    "active",
+		Clients: []*RegisteredClient{
+			{ClientID: "active", RegistrationAccessToken: "t", RegistrationClientURI: srv.URL + "/register/active"},
+			{ClientID: "stale", RegistrationAccessToken: "t", RegistrationClientURI: srv.URL + "/register/stale"},
+			{ClientID: "unmanaged"},
+		},
+	})
+
+	results, err := PruneClients(srv.URL, "")
+	if err != nil {
+		t.Fatalf("PruneClients: %v", err)
+	}
+	if len(deletes) != 1 || deletes[0] != "/register/stale" {
+		t.Errorf("deleted %v, want only /register/stale", deletes)
+	}
+	for _, r := range results {
+		if r.Deleted != (r.ClientID == "stale") || r.Err != nil {
+			t.Errorf("result %+v", r)
+		}
+	}
+
+	info, _ := Clients(srv.URL, "")
+	var kept []string
+	for _, rc := range info.Clients {
+		kept = append(kept, rc.ClientID)
+	}
+	if strings.Join(kept, ",") != "active,unmanaged" {
+		t.Errorf("kept %v, want active and unmanaged", kept)
+	}
+}
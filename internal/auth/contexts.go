@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultContextName names the context that the flat, pre-context settings and
+// credentials are migrated into.
+const DefaultContextName = "default"
+
+// ContextNotFoundError reports a context name that is not defined in
+// settings.json.
+type ContextNotFoundError struct {
+	Name string
+}
+
+func (e *ContextNotFoundError) Error() string {
+	return fmt.Sprintf("context %q not found. Run 'dot-ai context list' to see available contexts", e.Name)
+}
+
+// Active returns the preferences in effect for the named context. An empty
+// name falls back to CurrentContext; when that is empty too, the flat
+// top-level profile is returned. A name that matches no context is an error.
+func (s *Settings) Active(name string) (*Settings, error) {
+	if name == "" {
+		name = s.CurrentContext
+	}
+	if name == "" {
+		return s, nil
+	}
+	ctx, ok := s.Contexts[name]
+	if !ok || ctx == nil {
+		return nil, &ContextNotFoundError{Name: name}
+	}
+	return ctx, nil
+}
+
+// ActiveName returns the context name Active would select for name.
+func (s *Settings) ActiveName(name string) string {
+	if name != "" {
+		return name
+	}
+	return s.CurrentContext
+}
+
+// ContextNames returns the defined context names, sorted.
+func (s *Settings) ContextNames() []string {
+	names := make([]string, 0, len(s.Contexts))
+	for n := range s.Contexts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasFlatValues reports whether any flat-profile preference is set.
+func (s *Settings) hasFlatValues() bool {
+	return s.ServerURL != "" || s.OutputFormat != "" || s.SkillsInclude != "" ||
+		s.SkillsExclude != "" || s.SkillsCustomOnly != "" || len(s.Defaults) > 0 ||
+		s.CredentialHelper != "" || s.Exec != nil || s.SkillsRepoDirAllow != ""
+}
+
+// flatProfile returns a copy of the flat preferences without contexts.
+func (s *Settings) flatProfile() *Settings {
+	return &Settings{
+		ServerURL:        s.ServerURL,
+		OutputFormat:     s.OutputFormat,
+		SkillsInclude:    s.SkillsInclude,
+		SkillsExclude:    s.SkillsExclude,
+		SkillsCustomOnly: s.SkillsCustomOnly,
+		Defaults:         s.Defaults,
+		CredentialHelper: s.CredentialHelper,
+		Exec:             s.Exec,
+
+		SkillsRepoDirAllow: s.SkillsRepoDirAllow,
+	}
+}
+
+// For returns the credentials of the named context, creating an empty entry
+// when none exists yet. An empty name returns the flat top-level credentials.
+func (c *Credentials) For(name string) *Credentials {
+	if name == "" {
+		return c
+	}
+	if c.Contexts == nil {
+		c.Contexts = map[string]*Credentials{}
+	}
+	entry, ok := c.Contexts[name]
+	if !ok || entry == nil {
+		entry = &Credentials{}
+		c.Contexts[name] = entry
+	}
+	return entry
+}
+
+// hasFlatValues reports whether any flat credential field is set.
+func (c *Credentials) hasFlatValues() bool {
+	return c.hasUnbound() || len(c.Servers) > 0
+}
+
+// MigrateToContexts moves the flat, pre-context settings and credentials into
+// a context named DefaultContextName and makes it current. It runs when the
+// first context is created, so existing setups keep working unchanged until
+// the user opts into contexts. It reports whether anything was migrated and is
+// a no-op once contexts exist.
+func MigrateToContexts(s *Settings, c *Credentials) bool {
+	if len(s.Contexts) > 0 || (!s.hasFlatValues() && !c.hasFlatValues()) {
+		return false
+	}
+
+	s.Contexts = map[string]*Settings{DefaultContextName: s.flatProfile()}
+	s.ServerURL, s.OutputFormat, s.SkillsInclude, s.SkillsExclude, s.SkillsCustomOnly = "", "", "", "", ""
+	s.Defaults, s.CredentialHelper, s.Exec, s.SkillsRepoDirAllow = nil, "", nil, ""
+	if s.CurrentContext == "" {
+		s.CurrentContext = DefaultContextName
+	}
+
+	if c.hasFlatValues() {
+		flat := *c
+		flat.Contexts = nil
+		contexts := c.Contexts
+		*c = Credentials{Contexts: contexts}
+		c.For(DefaultContextName)
+		c.Contexts[DefaultContextName] = &flat
+	}
+	return true
+}
+
+// RenameContext renames a context in both settings and credentials, keeping
+// it current if it was.
+func RenameContext(s *Settings, c *Credentials, from, to string) error {
+	if _, ok := s.Contexts[from]; !ok {
+		return fmt.Errorf("context %q not found", from)
+	}
+	if _, ok := s.Contexts[to]; ok {
+		return fmt.Errorf("context %q already exists", to)
+	}
+	s.Contexts[to] = s.Contexts[from]
+	delete(s.Contexts, from)
+	if s.CurrentContext == from {
+		s.CurrentContext = to
+	}
+	if cr, ok := c.Contexts[from]; ok {
+		c.Contexts[to] = cr
+		delete(c.Contexts, from)
+	}
+	return nil
+}
+
+// DeleteContext removes a context and its credentials. Deleting the current
+// context clears CurrentContext.
+func DeleteContext(s *Settings, c *Credentials, name string) error {
+	if _, ok := s.Contexts[name]; !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+	delete(s.Contexts, name)
+	delete(c.Contexts, name)
+	if s.CurrentContext == name {
+		s.CurrentContext = ""
+	}
+	return nil
+}
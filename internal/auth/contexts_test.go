@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestActiveFallsBackToFlatProfile(t *testing.T) {
+	s := Settings{ServerURL: "https://flat.example.com"}
+	got, err := s.Active("")
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if got.ServerURL != "https://flat.example.com" {
+		t.Errorf("ServerURL = %q, want flat profile", got.ServerURL)
+	}
+}
+
+func TestActiveSelectsNamedAndCurrentContext(t *testing.T) {
+	s := Settings{
+		CurrentContext: "staging",
+		Contexts: map[string]*Settings{
+			"staging": {ServerURL: "https://staging.example.com"},
+			"prod":    {ServerURL: "https://prod.example.com"},
+		},
+	}
+
+	got, err := s.Active("")
+	if err != nil {
+		t.Fatalf("Active current: %v", err)
+	}
+	if got.ServerURL != "https://staging.example.com" {
+		t.Errorf("current ServerURL = %q, want staging", got.ServerURL)
+	}
+
+	got, err = s.Active("prod")
+	if err != nil {
+		t.Fatalf("Active prod: %v", err)
+	}
+	if got.ServerURL != "https://prod.example.com" {
+		t.Errorf("named ServerURL = %q, want prod", got.ServerURL)
+	}
+
+	_, err = s.Active("missing")
+	var notFound *ContextNotFoundError
+	if !errors.As(err, &notFound) || notFound.Name != "missing" {
+		t.Errorf("Active(missing) error = %v, want ContextNotFoundError", err)
+	}
+}
+
+func TestMigrateToContexts(t *testing.T) {
+	s := Settings{
+		ServerURL:    "https://flat.example.com",
+		OutputFormat: "json",
+		Defaults:     map[string]map[string]string{"knowledge ask": {"limit": "20"}},
+	}
+	c := Credentials{AuthToken: "flat-token"}
+
+	if !MigrateToContexts(&s, &c) {
+		t.Fatal("MigrateToContexts = false, want true")
+	}
+	if s.ServerURL != "" || s.OutputFormat != "" || s.Defaults != nil {
+		t.Errorf("flat settings not cleared: %+v", s)
+	}
+	if s.CurrentContext != DefaultContextName {
+		t.Errorf("CurrentContext = %q, want %q", s.CurrentContext, DefaultContextName)
+	}
+	def := s.Contexts[DefaultContextName]
+	if def == nil || def.ServerURL != "https://flat.example.com" || def.OutputFormat != "json" || def.Defaults["knowledge ask"]["limit"] != "20" {
+		t.Errorf("default context = %+v, want migrated flat settings", def)
+	}
+	if c.AuthToken != "" {
+		t.Errorf("flat AuthToken not cleared: %q", c.AuthToken)
+	}
+	if got := c.For(DefaultContextName).AuthToken; got != "flat-token" {
+		t.Errorf("default context AuthToken = %q, want %q", got, "flat-token")
+	}
+
+	if MigrateToContexts(&s, &c) {
+		t.Error("second MigrateToContexts = true, want no-op")
+	}
+}
+
+func TestMigrateToContextsNothingToMigrate(t *testing.T) {
+	var s Settings
+	var c Credentials
+	if MigrateToContexts(&s, &c) {
+		t.Error("MigrateToContexts on empty state = true, want false")
+	}
+	if s.Contexts != nil || s.CurrentContext != "" {
+		t.Errorf("empty state modified: %+v", s)
+	}
+}
+
+func TestRenameAndDeleteContext(t *testing.T) {
+	s := Settings{
+		CurrentContext: "old",
+		Contexts:       map[string]*Settings{"old": {ServerURL: "https://a"}, "other": {}},
+	}
+	c := Credentials{Contexts: map[string]*Credentials{"old": {AuthToken: "t"}}}
+
+	if err := RenameContext(&s, &c, "old", "other"); err == nil {
+		t.Error("RenameContext onto existing name: want error")
+	}
+	if err := RenameContext(&s, &c, "old", "new"); err != nil {
+		t.Fatalf("RenameContext: %v", err)
+	}
+	if s.CurrentContext != "new" || s.Contexts["new"].ServerURL != "https://a" {
+		t.Errorf("rename did not carry settings: %+v", s)
+	}
+	if c.Contexts["new"] == nil || c.Contexts["new"].AuthToken != "t" || c.Contexts["old"] != nil {
+		t.Errorf("rename did not carry credentials: %+v", c.Contexts)
+	}
+
+	if err := DeleteContext(&s, &c, "new"); err != nil {
+		t.Fatalf("DeleteContext: %v", err)
+	}
+	if s.CurrentContext != "" || s.Contexts["new"] != nil || c.Contexts["new"] != nil {
+		t.Errorf("delete left state behind: %+v %+v", s, c.Contexts)
+	}
+	if err := DeleteContext(&s, &c, "new"); err == nil {
+		t.Error("DeleteContext of missing context: want error")
+	}
+}
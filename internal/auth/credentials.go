@@ -2,21 +2,48 @@ package auth
 
 import (
 	"encoding/json"
-	"os"
 	"path/filepath"
 )
 
 // Credentials holds all authentication state stored in credentials.json.
+//
+// Tokens are bound to the server origin they were issued for: each profile
+// (the flat one, or a context) keeps them in Servers, keyed by Origin, and a
+// token is only ever sent to its own origin. The token fields at the top of a
+// profile are only read to migrate files written before origin binding (see
+// BindOrigins).
 type Credentials struct {
 	// Static bearer token (alternative to --token / DOT_AI_AUTH_TOKEN).
 	AuthToken string `json:"auth_token,omitempty"`
 
 	// OAuth session state (written by auth login, cleared by auth logout).
 	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	TokenType    string `json:"token_type,omitempty"`
 	ExpiresAt    string `json:"expires_at,omitempty"`
 	ClientID     string `json:"client_id,omitempty"`
 	ClientSecret string `json:"client_secret,omitempty"`
+
+	// GrantType is GrantClientCredentials for a service-account session,
+	// whose token is re-acquired with the client's credentials (read from
+	// ClientSecretFile, or the environment when empty) instead of refreshed.
+	GrantType        string `json:"grant_type,omitempty"`
+	ClientSecretFile string `json:"client_secret_file,omitempty"`
+
+	// Clients holds the OAuth clients registered with the server, reused by
+	// later logins. auth logout keeps them.
+	Clients []*RegisteredClient `json:"clients,omitempty"`
+
+	// ExecToken caches the last token printed by the exec provider until it
+	// expires.
+	ExecToken *CachedToken `json:"exec_token,omitempty"`
+
+	// Servers holds credentials keyed by normalised server origin.
+	Servers map[string]*Credentials `json:"servers,omitempty"`
+
+	// Contexts holds per-context credentials, keyed by context name. The
+	// top-level fields above belong to the flat (context-less) profile.
+	Contexts map[string]*Credentials `json:"contexts,omitempty"`
 }
 
 // CredentialsPath returns the path to the credentials file.
@@ -24,49 +51,80 @@ func CredentialsPath() string {
 	return filepath.Join(ConfigDir(), "credentials.json")
 }
 
-// LoadCredentials reads credentials from disk. Returns zero-value Credentials
+// LoadCredentials reads credentials from disk, decrypting them when the file
+// is an encrypted store (see MigrateStore). Returns zero-value Credentials
 // if the file does not exist.
 func LoadCredentials() (Credentials, error) {
 	var c Credentials
-	data, err := os.ReadFile(CredentialsPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return c, nil
-		}
+	data, err := readConfigFile(CredentialsPath())
+	if err != nil || data == nil {
 		return c, err
 	}
+	if es := parseEncryptedStore(data); es != nil {
+		if data, err = es.open(); err != nil {
+			return c, err
+		}
+	}
 	if err := json.Unmarshal(data, &c); err != nil {
 		return c, err
 	}
 	return c, nil
 }
 
-// Save writes credentials to disk with 0600 permissions, creating the config
-// directory if needed.
+// Save atomically writes credentials to disk with 0600 permissions, creating
+// the config directory if needed. An encrypted store stays encrypted.
+// Read-modify-write cycles go through UpdateCredentials (or hold
+// lockCredentials) so concurrent processes do not lose each other's changes.
 func (c *Credentials) Save() error {
-	dir := ConfigDir()
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return err
-	}
-	if err := os.Chmod(dir, 0700); err != nil {
+	s, err := currentSealer()
+	if err != nil {
 		return err
 	}
+	return c.write(s)
+}
+
+// write saves the credentials, sealed by s when it is not nil.
+func (c *Credentials) write(s *sealer) error {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return err
 	}
-	path := CredentialsPath()
-	if err := os.WriteFile(path, data, 0600); err != nil {
-		return err
+	if s != nil {
+		if data, err = s.store.seal(s.key, data); err != nil {
+			return err
+		}
 	}
-	return os.Chmod(path, 0600)
+	return writeConfigFile(CredentialsPath(), data)
 }
 
 // ClearOAuth removes only OAuth session fields, leaving auth_token intact.
 func (c *Credentials) ClearOAuth() {
 	c.AccessToken = ""
+	c.RefreshToken = ""
 	c.TokenType = ""
 	c.ExpiresAt = ""
 	c.ClientID = ""
 	c.ClientSecret = ""
+	c.GrantType = ""
+	c.ClientSecretFile = ""
+}
+
+// setToken stores an access token response, keeping the current refresh
+// token and token type when the response omits them.
+func (c *Credentials) setToken(tok *tokenResponse) {
+	c.AccessToken = tok.AccessToken
+	if tok.RefreshToken != "" {
+		c.RefreshToken = tok.RefreshToken
+	}
+	if tok.TokenType != "" {
+		c.TokenType = tok.TokenType
+	}
+	c.ExpiresAt = tok.expiresAt()
+}
+
+// Renewable reports whether an expired access token can be replaced without
+// user interaction: with a refresh token, or by re-running the
+// client-credentials grant.
+func (c *Credentials) Renewable() bool {
+	return c.RefreshToken != "" || c.GrantType == GrantClientCredentials
 }
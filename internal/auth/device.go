@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceCodeGrantType is the RFC 8628 token grant type.
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultDevicePollInterval applies when the server does not send one
+// (RFC 8628 §3.2).
+const defaultDevicePollInterval = 5 * time.Second
+
+// sleepFunc can be overridden in tests.
+var sleepFunc = time.Sleep
+
+// deviceAuthorization holds the device authorization response (RFC 8628 §3.2).
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// LoginDevice performs the OAuth device authorization grant (RFC 8628) for
+// hosts where no browser can reach a loopback callback. It registers a
+// client, shows the user code and verification URI on opts.Out, polls the
+// token endpoint until the user approves (slowing down when asked), and
+// stores the session like Login.
+func LoginDevice(opts LoginOptions) error {
+	serverURL := opts.ServerURL
+	out := opts.out()
+	if _, err := Origin(serverURL); err != nil {
+		return err
+	}
+
+	meta, err := discover(serverURL, true)
+	if err != nil {
+		return err
+	}
+	if meta.DeviceAuthorizationEndpoint == "" {
+		return fmt.Errorf("the server does not advertise a device authorization endpoint; use 'dot-ai auth login --no-browser' instead")
+	}
+	if err := meta.requireGrant(deviceCodeGrantType, "the device authorization grant"); err != nil {
+		return err
+	}
+
+	entry, err := loadServerEntry(serverURL, opts.Context)
+	if err != nil {
+		return err
+	}
+	var candidate *RegisteredClient
+	if clients := entry.registeredClients(deviceCodeGrantType); len(clients) > 0 {
+		candidate = clients[0]
+	}
+	register := func() (*RegisteredClient, error) {
+		return registerClient(meta, map[string]any{
+			"grant_types":                meta.clientGrants(deviceCodeGrantType),
+			"token_endpoint_auth_method": "client_secret_post",
+			"client_name":                "dot-ai CLI (device)",
+		})
+	}
+
+	reg, reused, err := obtainClient(serverURL, opts.Context, candidate, register)
+	if err != nil {
+		return err
+	}
+	da, err := requestDeviceCode(meta, reg.ClientID, reg.ClientSecret)
+	if reused && clientRejected(err) {
+		// The stored registration is gone: replace it and start over.
+		if err := dropClient(serverURL, opts.Context, reg.ClientID); err != nil {
+			return err
+		}
+		if reg, _, err = obtainClient(serverURL, opts.Context, nil, register); err != nil {
+			return err
+		}
+		da, err = requestDeviceCode(meta, reg.ClientID, reg.ClientSecret)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "To sign in, open %s and enter the code: %s\n", da.VerificationURI, da.UserCode)
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "Or open this URL, which includes the code:\n%s\n", da.VerificationURIComplete)
+	}
+	fmt.Fprintln(out, "Waiting for approval...")
+
+	tok, err := pollDeviceToken(meta, da, reg, opts.TokenTTL)
+	if err != nil {
+		return err
+	}
+	if err := storeSession(serverURL, opts.Context, tok, reg); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Authentication successful.")
+	return nil
+}
+
+// requestDeviceCode starts a device authorization at the server's device
+// authorization endpoint.
+func requestDeviceCode(meta *ServerMetadata, clientID, clientSecret string) (*deviceAuthorization, error) {
+	data := url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	}
+	if meta.SupportsGrant("refresh_token") {
+		data.Set("scope", "offline_access")
+	}
+	status, body, err := postForm(meta.DeviceAuthorizationEndpoint, data)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, newOAuthError("device authorization", status, body)
+	}
+	var da deviceAuthorization
+	if err := json.Unmarshal(body, &da); err != nil {
+		return nil, fmt.Errorf("parsing device authorization response: %w", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization response is missing device_code, user_code or verification_uri")
+	}
+	return &da, nil
+}
+
+// pollDeviceToken polls the token endpoint until the device code is approved,
+// denied or expires. authorization_pending keeps polling and slow_down adds
+// five seconds to the interval, as RFC 8628 §3.5 requires.
+func pollDeviceToken(meta *ServerMetadata, da *deviceAuthorization, reg *RegisteredClient, requestedExpiry int) (*tokenResponse, error) {
+	interval := defaultDevicePollInterval
+	if da.Interval > 0 {
+		interval = time.Duration(da.Interval) * time.Second
+	}
+	expiresIn := 5 * time.Minute
+	if da.ExpiresIn > 0 {
+		expiresIn = time.Duration(da.ExpiresIn) * time.Second
+	}
+	deadline := time.Now().Add(expiresIn)
+
+	data := url.Values{
+		"grant_type":    {deviceCodeGrantType},
+		"device_code":   {da.DeviceCode},
+		"client_id":     {reg.ClientID},
+		"client_secret": {reg.ClientSecret},
+	}
+	if requestedExpiry > 0 {
+		data.Set("requested_expiry", strconv.Itoa(requestedExpiry))
+	}
+
+	for {
+		sleepFunc(interval)
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the device code expired before it was approved; run 'dot-ai auth login --device' again")
+		}
+
+		status, body, err := postForm(meta.TokenEndpoint, data)
+		if err != nil {
+			return nil, fmt.Errorf("token request failed: %w", err)
+		}
+		if status == http.StatusOK {
+			var tok tokenResponse
+			if err := json.Unmarshal(body, &tok); err != nil {
+				return nil, fmt.Errorf("parsing token response: %w", err)
+			}
+			return &tok, nil
+		}
+
+		te := newOAuthError("token request", status, body)
+		switch te.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, fmt.Errorf("the login request was denied")
+		case "expired_token":
+			return nil, fmt.Errorf("the device code expired before it was approved; run 'dot-ai auth login --device' again")
+		default:
+			return nil, te
+		}
+	}
+}
+
+// postForm posts a form and returns the status and body.
+func postForm(endpoint string, data url.Values) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClientFunc(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
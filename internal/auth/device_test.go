@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginDevicePollsWithSlowDown(t *testing.T) {
+	dir := t.TempDir()
+	origFunc := configDirFunc
+	configDirFunc = func() string { return dir }
+	defer func() { configDirFunc = origFunc }()
+
+	var slept []time.Duration
+	origSleep := sleepFunc
+	sleepFunc = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleepFunc = origSleep }()
+
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/register":
+			json.NewEncoder(w).Encode(map[string]string{"client_id": "cid", "client_secret": "secret"})
+		case "/device/code":
+			json.NewEncoder(w).Encode(map[string]any{
+				"device_code":      "dev-code",
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://dex.example.com/device",
+				"expires_in":       600,
+				"interval":         2,
+			})
+		case "/token":
+			if r.Form.Get("grant_type") != deviceCodeGrantType || r.Form.Get("device_code") != "dev-code" {
+				t.Errorf("unexpected token request: %v", r.Form)
+			}
+			polls++
+			switch polls {
+			case 1:
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+			case 2:
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "slow_down"})
+			default:
+				json.NewEncoder(w).Encode(map[string]any{"access_token": "device-token", "refresh_token": "r", "expires_in": 3600})
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	if err := LoginDevice(LoginOptions{ServerURL: srv.URL, Out: &out}); err != nil {
+		t.Fatalf("LoginDevice: %v", err)
+	}
+	if !strings.Contains(out.String(), "ABCD-EFGH") || !strings.Contains(out.String(), "https://dex.example.com/device") {
+		t.Errorf("output = %q, want the user code and verification URI", out.String())
+	}
+	want := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second}
+	if len(slept) != len(want) {
+		t.Fatalf("slept %v, want %v", slept, want)
+	}
+	for i := range want {
+		if slept[i] != want[i] {
+			t.Errorf("poll %d waited %v, want %v (slow_down adds 5s)", i+1, slept[i], want[i])
+		}
+	}
+
+	creds, err := LoadCredentials()
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if got := creds.Server(srv.URL); got == nil || got.AccessToken != "device-token" || got.ClientID != "cid" {
+		t.Errorf("stored session = %+v", got)
+	}
+}
+
+func TestLoginDeviceDenied(t *testing.T) {
+	origSleep := sleepFunc
+	sleepFunc = func(time.Duration) {}
+	defer func() { sleepFunc = origSleep }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/register":
+			json.NewEncoder(w).Encode(map[string]string{"client_id": "cid"})
+		case "/device/code":
+			json.NewEncoder(w).Encode(map[string]any{"device_code": "d", "user_code": "u", "verification_uri": "https://v"})
+		case "/token":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "access_denied"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	err := LoginDevice(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}})
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("err = %v, want a denial", err)
+	}
+}
+
+func TestReadPastedRedirect(t *testing.T) {
+	cases := []struct {
+		name     string
+		in       string
+		wantCode string
+		wantErr  string
+	}{
+		{"redirect URL", "http://127.0.0.1:8085/callback?code=abc&state=x\n", "abc", ""},
+		{"error redirect", "http://127.0.0.1:8085/callback?error=access_denied&error_description=nope&state=x\n", "", "access_denied: nope"},
+		{"not a redirect", "abc\n", "", "pasted URL"},
+		{"state mismatch", "http://127.0.0.1:8085/callback?code=abc&state=forged\n", "", "state mismatch"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			codeCh := make(chan string, 1)
+			errCh := make(chan error, 1)
+			(&authorizationRequest{state: "x"}).readPastedRedirect(strings.NewReader(tc.in), codeCh, errCh)
+			select {
+			case code := <-codeCh:
+				if code != tc.wantCode {
+					t.Errorf("code = %q, want %q", code, tc.wantCode)
+				}
+			case err := <-errCh:
+				if tc.wantErr == "" || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("err = %v, want %q", err, tc.wantErr)
+				}
+			default:
+				t.Error("nothing delivered")
+			}
+		})
+	}
+
+	// EOF (no terminal) delivers nothing, leaving the callback to finish.
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	(&authorizationRequest{state: "x"}).readPastedRedirect(strings.NewReader(""), codeCh, errCh)
+	if len(codeCh)+len(errCh) != 0 {
+		t.Error("EOF should deliver nothing")
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
+)
+
+// metadataCacheTTL is how long discovered server metadata is reused before
+// it is fetched again. auth login always fetches it afresh.
+const metadataCacheTTL = 24 * time.Hour
+
+// fallbackCacheTTL is how long the fallback endpoints of a server without a
+// metadata document are reused, so a document published later is picked up
+// soon.
+const fallbackCacheTTL = 5 * time.Minute
+
+// ServerMetadata is the OAuth authorization server metadata (RFC 8414) the
+// CLI uses. OpenID Connect discovery documents carry the same fields.
+type ServerMetadata struct {
+	Issuer                        string   `json:"issuer,omitempty"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	RegistrationEndpoint          string   `json:"registration_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint   string   `json:"device_authorization_endpoint,omitempty"`
+	RevocationEndpoint            string   `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint         string   `json:"introspection_endpoint,omitempty"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint,omitempty"`
+	GrantTypesSupported           []string `json:"grant_types_supported,omitempty"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+	// AuthorizationResponseIssParameterSupported reports that authorization
+	// responses carry the issuer (RFC 9207).
+	AuthorizationResponseIssParameterSupported bool `json:"authorization_response_iss_parameter_supported,omitempty"`
+}
+
+// cachedMetadata is an entry of the metadata cache file.
+type cachedMetadata struct {
+	Metadata  ServerMetadata `json:"metadata"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	// Fallback marks the fallback endpoints rather than a discovered
+	// document; they are cached for fallbackCacheTTL only.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// current reports whether the entry is within its TTL.
+func (e cachedMetadata) current() bool {
+	ttl := metadataCacheTTL
+	if e.Fallback {
+		ttl = fallbackCacheTTL
+	}
+	return time.Since(e.FetchedAt) < ttl
+}
+
+// MetadataCachePath returns the path of the server metadata cache.
+func MetadataCachePath() string {
+	return filepath.Join(ConfigDir(), "oauth-metadata.json")
+}
+
+// Discover returns the OAuth metadata for serverURL, from the cache when it
+// was fetched within metadataCacheTTL (fallbackCacheTTL for the fallback
+// endpoints).
+func Discover(serverURL string) (*ServerMetadata, error) {
+	return discover(serverURL, false)
+}
+
+// discover returns the metadata for serverURL, fetching it when fresh is set
+// or the cache has no current entry, and caches what it fetched.
+func discover(serverURL string, fresh bool) (*ServerMetadata, error) {
+	if _, err := Origin(serverURL); err != nil {
+		return nil, err
+	}
+	key := strings.TrimRight(serverURL, "/")
+	cache := loadMetadataCache()
+	if entry, ok := cache[key]; ok && !fresh && entry.current() {
+		return &entry.Metadata, nil
+	}
+
+	meta, fallback, err := fetchMetadata(key)
+	if err != nil {
+		return nil, fmt.Errorf("discovering the OAuth endpoints of %s: %w", key, err)
+	}
+	cache[key] = cachedMetadata{Metadata: *meta, FetchedAt: time.Now().UTC(), Fallback: fallback}
+	// The cache only saves round-trips; failing to write it is not an error.
+	_ = saveMetadataCache(cache)
+	return meta, nil
+}
+
+// fetchMetadata tries the well-known metadata locations of serverURL in turn
+// and falls back to the endpoints the server has always served under its
+// own URL (/authorize, /token, /register, /device/code) when none has a
+// usable document, reporting the fallback. Only a 404 or an unusable
+// document moves on: a network error or another error status may be
+// transient, and is returned rather than mistaken for a server without
+// metadata.
+func fetchMetadata(serverURL string) (*ServerMetadata, bool, error) {
+	for _, wellKnown := range wellKnownURLs(serverURL) {
+		meta, err := getMetadata(wellKnown)
+		if err == nil {
+			return meta, false, nil
+		}
+		if !errors.Is(err, errNoMetadata) {
+			return nil, false, err
+		}
+	}
+	return &ServerMetadata{
+		Issuer:                      serverURL,
+		AuthorizationEndpoint:       serverURL + "/authorize",
+		TokenEndpoint:               serverURL + "/token",
+		RegistrationEndpoint:        serverURL + "/register",
+		DeviceAuthorizationEndpoint: serverURL + "/device/code",
+	}, true, nil
+}
+
+// errNoMetadata is returned by getMetadata when a location has no usable
+// metadata document: a 404 or a document that fails validation.
+var errNoMetadata = errors.New("no usable server metadata")
+
+// wellKnownURLs lists the metadata locations for serverURL: the RFC 8414
+// location (the well-known path inserted before any path component), the
+// same path appended to a path-prefixed server URL, and the OpenID Connect
+// discovery document.
+func wellKnownURLs(serverURL string) []string {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil
+	}
+	path := strings.TrimRight(u.Path, "/")
+	base := u.Scheme + "://" + u.Host
+
+	urls := []string{base + "/.well-known/oauth-authorization-server" + path}
+	if path != "" {
+		urls = append(urls, serverURL+"/.well-known/oauth-authorization-server")
+	}
+	return append(urls, serverURL+"/.well-known/openid-configuration")
+}
+
+// getMetadata fetches and validates one metadata document.
+func getMetadata(wellKnown string) (*ServerMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClientFunc(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w at %s", errNoMetadata, wellKnown)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata request to %s failed (%d)", wellKnown, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta ServerMetadata
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("%w: parsing %s: %v", errNoMetadata, wellKnown, err)
+	}
+	if !isHTTPURL(meta.TokenEndpoint) {
+		return nil, fmt.Errorf("%w: %s has no valid token_endpoint", errNoMetadata, wellKnown)
+	}
+	for _, endpoint := range []string{meta.AuthorizationEndpoint, meta.RegistrationEndpoint, meta.DeviceAuthorizationEndpoint, meta.RevocationEndpoint, meta.IntrospectionEndpoint, meta.UserinfoEndpoint} {
+		if endpoint != "" && !isHTTPURL(endpoint) {
+			return nil, fmt.Errorf("%w: %s has an invalid endpoint %q", errNoMetadata, wellKnown, endpoint)
+		}
+	}
+	return &meta, nil
+}
+
+// isHTTPURL reports whether s is an absolute http(s) URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// SupportsGrant reports whether the server accepts the grant type. A server
+// that does not advertise its grant types is assumed to accept it; the token
+// endpoint reports otherwise.
+func (m *ServerMetadata) SupportsGrant(grant string) bool {
+	return len(m.GrantTypesSupported) == 0 || slices.Contains(m.GrantTypesSupported, grant)
+}
+
+// pkceMethod picks the PKCE code challenge method: S256 unless the server
+// advertises only plain.
+func (m *ServerMetadata) pkceMethod() (string, error) {
+	methods := m.CodeChallengeMethodsSupported
+	switch {
+	case len(methods) == 0 || slices.Contains(methods, "S256"):
+		return "S256", nil
+	case slices.Contains(methods, "plain"):
+		return "plain", nil
+	default:
+		return "", fmt.Errorf("the server supports no PKCE method the CLI implements (advertised: %s)", strings.Join(methods, ", "))
+	}
+}
+
+// requireGrant returns an error naming the login option when the server does
+// not accept grant.
+func (m *ServerMetadata) requireGrant(grant, what string) error {
+	if !m.SupportsGrant(grant) {
+		return fmt.Errorf("the server does not support %s (grant types: %s)", what, strings.Join(m.GrantTypesSupported, ", "))
+	}
+	return nil
+}
+
+// loadMetadataCache reads the metadata cache; a missing or unreadable cache
+// is empty.
+func loadMetadataCache() map[string]cachedMetadata {
+	cache := map[string]cachedMetadata{}
+	data, err := os.ReadFile(MetadataCachePath())
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil || cache == nil {
+		return map[string]cachedMetadata{}
+	}
+	return cache
+}
+
+// saveMetadataCache writes the metadata cache, dropping expired entries.
+func saveMetadataCache(cache map[string]cachedMetadata) error {
+	for key, entry := range cache {
+		if !entry.current() {
+			delete(cache, key)
+		}
+	}
+	if err := os.MkdirAll(ConfigDir(), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(MetadataCachePath(), data, 0600)
+}
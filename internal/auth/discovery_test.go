@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiscoverPathPrefixedServer(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+
+	var fetches atomic.Int32
+	var issuer string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/oauth-authorization-server/dot-ai" {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           issuer,
+			"authorization_endpoint":           "https://idp.example.com/oauth2/auth",
+			"token_endpoint":                   "https://idp.example.com/oauth2/token",
+			"registration_endpoint":            "https://idp.example.com/oauth2/register",
+			"grant_types_supported":            []string{"authorization_code"},
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	}))
+	defer srv.Close()
+	issuer = srv.URL + "/dot-ai"
+
+	meta, err := Discover(srv.URL + "/dot-ai/")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if meta.TokenEndpoint != "https://idp.example.com/oauth2/token" || meta.Issuer != issuer {
+		t.Errorf("metadata = %+v", meta)
+	}
+	if meta.SupportsGrant("refresh_token") || !meta.SupportsGrant("authorization_code") {
+		t.Errorf("SupportsGrant disagrees with grant_types_supported %v", meta.GrantTypesSupported)
+	}
+
+	// The second lookup is served from the cache; a fresh one refetches.
+	if _, err := Discover(srv.URL + "/dot-ai"); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("metadata fetched %d times, want 1", n)
+	}
+	if _, err := discover(srv.URL+"/dot-ai", true); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("metadata fetched %d times, want 2", n)
+	}
+}
+
+func TestDiscoverFallsBackToServerPaths(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	meta, err := Discover(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	want := ServerMetadata{
+		Issuer:                      srv.URL,
+		AuthorizationEndpoint:       srv.URL + "/authorize",
+		TokenEndpoint:               srv.URL + "/token",
+		RegistrationEndpoint:        srv.URL + "/register",
+		DeviceAuthorizationEndpoint: srv.URL + "/device/code",
+	}
+	if meta.Issuer != want.Issuer || meta.AuthorizationEndpoint != want.AuthorizationEndpoint ||
+		meta.TokenEndpoint != want.TokenEndpoint || meta.RegistrationEndpoint != want.RegistrationEndpoint ||
+		meta.DeviceAuthorizationEndpoint != want.DeviceAuthorizationEndpoint {
+		t.Errorf("metadata = %+v, want %+v", meta, want)
+	}
+}
+
+func TestDiscoverCachesFallbackBriefly(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := Discover(srv.URL); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	entry, ok := loadMetadataCache()[srv.URL]
+	if !ok || !entry.Fallback {
+		t.Fatalf("cache entry = %+v, want a fallback entry", entry)
+	}
+	entry.FetchedAt = time.Now().Add(-2 * fallbackCacheTTL)
+	if entry.current() {
+		t.Errorf("fallback entry still current after %v", 2*fallbackCacheTTL)
+	}
+}
+
+func TestDiscoverDoesNotFallBackOnServerError(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+	var down atomic.Bool
+	down.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"token_endpoint": "https://idp.example.com/token"})
+	}))
+	defer srv.Close()
+
+	if meta, err := Discover(srv.URL); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Discover = %+v, %v; want the 503 error", meta, err)
+	}
+	if _, ok := loadMetadataCache()[srv.URL]; ok {
+		t.Error("a failed discovery was cached")
+	}
+
+	// Once the server is back its real metadata is used.
+	down.Store(false)
+	meta, err := Discover(srv.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if meta.TokenEndpoint != "https://idp.example.com/token" {
+		t.Errorf("TokenEndpoint = %q, want the discovered one", meta.TokenEndpoint)
+	}
+}
+
+func TestDiscoverRejectsInvalidDocument(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token_endpoint":"/relative/token"}`))
+	}))
+	defer srv.Close()
+
+	meta, err := Discover(srv.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if meta.TokenEndpoint != srv.URL+"/token" {
+		t.Errorf("TokenEndpoint = %q, want the fallback", meta.TokenEndpoint)
+	}
+}
+
+func TestWellKnownURLs(t *testing.T) {
+	got := wellKnownURLs("https://example.com/dot-ai")
+	want := []string{
+		"https://example.com/.well-known/oauth-authorization-server/dot-ai",
+		"https://example.com/dot-ai/.well-known/oauth-authorization-server",
+		"https://example.com/dot-ai/.well-known/openid-configuration",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("wellKnownURLs = %v, want %v", got, want)
+	}
+	if got := wellKnownURLs("https://example.com"); len(got) != 2 {
+		t.Errorf("wellKnownURLs without a path = %v, want 2 locations", got)
+	}
+}
+
+func TestAuthorizationURL(t *testing.T) {
+	meta := &ServerMetadata{
+		AuthorizationEndpoint: "https://idp.example.com/auth?tenant=ops",
+		GrantTypesSupported:   []string{"authorization_code"},
+	}
+	raw, err := authorizationURL(meta, "cid", "http://127.0.0.1:8085/callback", "verifier", "plain", "state-1")
+	if err != nil {
+		t.Fatalf("authorizationURL: %v", err)
+	}
+	u, _ := url.Parse(raw)
+	q := u.Query()
+	if u.Host != "idp.example.com" || q.Get("tenant") != "ops" || q.Get("code_challenge_method") != "plain" || q.Get("client_id") != "cid" || q.Get("state") != "state-1" {
+		t.Errorf("authorizationURL = %s", raw)
+	}
+	if q.Has("scope") {
+		t.Errorf("offline_access requested from a server without refresh tokens: %s", raw)
+	}
+}
+
+func TestPKCEMethod(t *testing.T) {
+	tests := []struct {
+		methods []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "S256", false},
+		{[]string{"plain", "S256"}, "S256", false},
+		{[]string{"plain"}, "plain", false},
+		{[]string{"S512"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := (&ServerMetadata{CodeChallengeMethodsSupported: tt.methods}).pkceMethod()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("pkceMethod(%v) = %q, %v", tt.methods, got, err)
+		}
+	}
+}
+
+func TestLoginDeviceRequiresAdvertisedGrant(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/oauth-authorization-server" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"token_endpoint":                "https://idp.example.com/token",
+			"device_authorization_endpoint": "https://idp.example.com/device",
+			"grant_types_supported":         []string{"authorization_code"},
+		})
+	}))
+	defer srv.Close()
+
+	err := LoginDevice(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}})
+	if err == nil || !strings.Contains(err.Error(), "device authorization grant") {
+		t.Fatalf("LoginDevice error = %v, want unsupported grant", err)
+	}
+}
+
+func TestMetadataCacheDropsExpiredEntries(t *testing.T) {
+	saveTestSession(t, "http://unused.example.com", Credentials{})
+	cache := map[string]cachedMetadata{
+		"https://old.example.com": {FetchedAt: time.Now().Add(-2 * metadataCacheTTL)},
+		"https://new.example.com": {FetchedAt: time.Now()},
+	}
+	if err := saveMetadataCache(cache); err != nil {
+		t.Fatalf("saveMetadataCache: %v", err)
+	}
+	got := loadMetadataCache()
+	if _, ok := got["https://old.example.com"]; ok || len(got) != 1 {
+		t.Errorf("cache = %v, want only the current entry", got)
+	}
+}
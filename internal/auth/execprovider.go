@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// execTimeout bounds one run of an exec provider, which may prompt for a
+// login.
+const execTimeout = 2 * time.Minute
+
+// ExecProvider runs a command that prints a token, like a kubeconfig exec
+// credential plugin. The command writes an ExecCredential document to
+// stdout:
+//
+//	{"status": {"token": "...", "expirationTimestamp": "2026-01-02T15:04:05Z"}}
+//
+// A token with an expiry is cached in credentials.json until it is about to
+// expire; one without is fetched for every command.
+type ExecProvider struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// CachedToken is a token from an exec provider, kept until it expires. Key
+// identifies the provider configuration that produced it, so changing the
+// command discards it.
+type CachedToken struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+	Key       string `json:"key"`
+}
+
+// execCredential is the part of the ExecCredential document the CLI reads.
+type execCredential struct {
+	Status struct {
+		Token               string `json:"token"`
+		ExpirationTimestamp string `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// key fingerprints the provider configuration.
+func (p *ExecProvider) key() string {
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ExecToken returns a token from the exec provider for the origin of
+// serverURL: the token cached in the named context while it is not about to
+// expire, otherwise a fresh one from the command. It returns the token and
+// its RFC 3339 expiry (empty when the command gave none).
+func ExecToken(p *ExecProvider, serverURL, contextName string) (string, string, error) {
+	key := p.key()
+	entry, err := loadServerEntry(serverURL, contextName)
+	if err != nil {
+		return "", "", err
+	}
+	if cached := entry.ExecToken; cached != nil && cached.Key == key && !expiresWithin(cached.ExpiresAt, RefreshLeeway) {
+		return cached.Token, cached.ExpiresAt, nil
+	}
+
+	token, expiresAt, err := runExecProvider(p, serverURL)
+	if err != nil {
+		return "", "", err
+	}
+	if expiresAt != "" {
+		err = updateServerEntry(serverURL, contextName, func(cred *Credentials) {
+			cred.ExecToken = &CachedToken{Token: token, ExpiresAt: expiresAt, Key: key}
+		})
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return token, expiresAt, nil
+}
+
+// runExecProvider runs the command and parses its ExecCredential. The
+// command's stderr is passed through so it can show login prompts, and
+// DOT_AI_SERVER_URL tells it which server the token is for.
+func runExecProvider(p *ExecProvider, serverURL string) (string, string, error) {
+	if p.Command == "" {
+		return "", "", fmt.Errorf("exec provider has no command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Env = append(os.Environ(), "DOT_AI_SERVER_URL="+serverURL)
+	for k, v := range p.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("exec provider %s failed: %w", p.Command, err)
+	}
+
+	var ec execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &ec); err != nil {
+		return "", "", fmt.Errorf("exec provider %s: parsing output: %w", p.Command, err)
+	}
+	token := strings.TrimSpace(ec.Status.Token)
+	if token == "" {
+		return "", "", fmt.Errorf("exec provider %s returned no status.token", p.Command)
+	}
+	expiresAt := ec.Status.ExpirationTimestamp
+	if expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return "", "", fmt.Errorf("exec provider %s: invalid status.expirationTimestamp %q", p.Command, expiresAt)
+		}
+		expiresAt = t.UTC().Format(time.RFC3339)
+	}
+	return token, expiresAt, nil
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeExecProvider writes a script printing an ExecCredential with the
+// given expiry and counting its runs in a file next to it.
+func writeExecProvider(t *testing.T, expiresAt string) (*ExecProvider, func() int) {
+	t.Helper()
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	script := fmt.Sprintf(`#!/bin/sh
+[ "$DOT_AI_SERVER_URL" = "https://dot-ai.example.com" ] || exit 1
+echo run >> %q
+printf '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"%%s-token","expirationTimestamp":"%s"}}' "$TOKEN_PREFIX"
+`, runs, expiresAt)
+	path := filepath.Join(dir, "provider")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		data, _ := os.ReadFile(runs)
+		return strings.Count(string(data), "run")
+	}
+	return &ExecProvider{Command: path, Env: map[string]string{"TOKEN_PREFIX": "exec"}}, count
+}
+
+func TestExecTokenCachedUntilExpiry(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{})
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	p, runs := writeExecProvider(t, expires)
+
+	for i := 0; i < 2; i++ {
+		token, expiresAt, err := ExecToken(p, "https://dot-ai.example.com", "")
+		if err != nil {
+			t.Fatalf("ExecToken: %v", err)
+		}
+		if token != "exec-token" || expiresAt != expires {
+			t.Errorf("ExecToken = %q, %q; want exec-token, %s", token, expiresAt, expires)
+		}
+	}
+	if n := runs(); n != 1 {
+		t.Errorf("provider ran %d times, want 1 (cached)", n)
+	}
+
+	// A changed configuration does not reuse the cached token.
+	p.Env["TOKEN_PREFIX"] = "other"
+	if token, _, _ := ExecToken(p, "https://dot-ai.example.com", ""); token != "other-token" || runs() != 2 {
+		t.Errorf("ExecToken after a config change = %q after %d runs", token, runs())
+	}
+}
+
+func TestExecTokenRerunsNearExpiry(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{})
+	p, runs := writeExecProvider(t, time.Now().Add(RefreshLeeway/2).UTC().Format(time.RFC3339))
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := ExecToken(p, "https://dot-ai.example.com", ""); err != nil {
+			t.Fatalf("ExecToken: %v", err)
+		}
+	}
+	if n := runs(); n != 2 {
+		t.Errorf("provider ran %d times, want 2 (token about to expire)", n)
+	}
+}
+
+func TestExecTokenInvalidOutput(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{})
+	for name, p := range map[string]*ExecProvider{
+		"no token": {Command: "sh", Args: []string{"-c", `echo '{"status":{}}'`}},
+		"not json": {Command: "sh", Args: []string{"-c", "echo token"}},
+		"bad time": {Command: "sh", Args: []string{"-c", `echo '{"status":{"token":"t","expirationTimestamp":"tomorrow"}}'`}},
+		"failure":  {Command: "sh", Args: []string{"-c", "exit 3"}},
+	} {
+		if _, _, err := ExecToken(p, "https://dot-ai.example.com", ""); err == nil {
+			t.Errorf("%s: ExecToken succeeded", name)
+		}
+	}
+}
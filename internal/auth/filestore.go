@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
+)
+
+// configLockTimeout bounds how long a process waits for another one to
+// finish updating a config file. It covers one token request, the longest
+// step done under the credentials lock.
+const configLockTimeout = 45 * time.Second
+
+// warnings receives non-fatal warnings; tests replace it.
+var warnings io.Writer = os.Stderr
+
+// lockCredentials takes the exclusive lock guarding credentials.json
+// read-modify-write cycles.
+func lockCredentials() (*flock.Flock, error) {
+	return lockConfigFile("credentials")
+}
+
+// lockSettings takes the exclusive lock guarding settings.json
+// read-modify-write cycles. Code holding both locks takes this one first.
+func lockSettings() (*flock.Flock, error) {
+	return lockConfigFile("settings")
+}
+
+// lockConfigFile takes the lock file <name>.lock in the config directory.
+// It polls until acquired or configLockTimeout elapses.
+func lockConfigFile(name string) (*flock.Flock, error) {
+	dir := ConfigDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	fl := flock.New(filepath.Join(dir, name+".lock"))
+	deadline := time.Now().Add(configLockTimeout)
+	for {
+		ok, err := fl.TryLock()
+		if err != nil {
+			return nil, fmt.Errorf("could not acquire the %s lock: %w", name, err)
+		}
+		if ok {
+			return fl, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for another dot-ai process to finish updating %s", name)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// UpdateSettings applies update to settings.json under the settings lock:
+// the file is re-read after the lock is taken, so concurrent updates are
+// not lost. Nothing is written when update fails.
+func UpdateSettings(update func(*Settings) error) error {
+	lock, err := lockSettings()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	s, err := LoadUserSettings()
+	if err != nil {
+		return fmt.Errorf("loading settings: %w", err)
+	}
+	if err := update(&s); err != nil {
+		return err
+	}
+	return s.Save()
+}
+
+// UpdateCredentials applies update to credentials.json under the
+// credentials lock, like UpdateSettings.
+func UpdateCredentials(update func(*Credentials) error) error {
+	lock, err := lockCredentials()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	c, err := LoadCredentials()
+	if err != nil {
+		return fmt.Errorf("loading credentials: %w", err)
+	}
+	if err := update(&c); err != nil {
+		return err
+	}
+	if err := c.Save(); err != nil {
+		return fmt.Errorf("saving credentials: %w", err)
+	}
+	return nil
+}
+
+// UpdateState applies update to settings.json and credentials.json together,
+// holding both locks, for changes that must keep the two in step (contexts).
+func UpdateState(update func(*Settings, *Credentials) error) error {
+	return UpdateSettings(func(s *Settings) error {
+		return UpdateCredentials(func(c *Credentials) error {
+			return update(s, c)
+		})
+	})
+}
+
+// writeConfigFile atomically replaces path with data (temp file, fsync,
+// rename), keeping the current content as path.bak when it is intact so a
+// later corruption can be recovered from.
+func writeConfigFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(path); err == nil && json.Valid(old) {
+		if err := atomicfile.WriteFile(path+".bak", old, 0600); err != nil {
+			return fmt.Errorf("backing up %s: %w", filepath.Base(path), err)
+		}
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+// readConfigFile reads path, returning nil when it does not exist. A file
+// that is not valid JSON (truncated or interleaved by a crash or an older
+// version's unlocked write) is moved aside to path.corrupt and replaced by
+// the backup from the last save, with a warning; without a usable backup the
+// file reads as empty.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if json.Valid(data) {
+		return data, nil
+	}
+
+	name := filepath.Base(path)
+	if err := os.Rename(path, path+".corrupt"); err != nil {
+		if os.IsNotExist(err) {
+			// Another process recovered it first.
+			return readConfigFile(path)
+		}
+		return nil, fmt.Errorf("%s is corrupt and could not be moved aside: %w", name, err)
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || !json.Valid(backup) {
+		fmt.Fprintf(warnings, "Warning: %s was corrupt and no backup was usable; moved it to %s.corrupt and started afresh\n", name, path)
+		return nil, nil
+	}
+	if err := atomicfile.WriteFile(path, backup, 0600); err != nil {
+		return nil, fmt.Errorf("restoring %s from its backup: %w", name, err)
+	}
+	fmt.Fprintf(warnings, "Warning: %s was corrupt; moved it to %s.corrupt and restored the backup from the last save\n", name, path)
+	return backup, nil
+}
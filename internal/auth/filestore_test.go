@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUpdateSettingsConcurrent(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{})
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateSettings(func(s *Settings) error {
+				if s.Contexts == nil {
+					s.Contexts = map[string]*Settings{}
+				}
+				s.Contexts[fmt.Sprintf("ctx-%d", i)] = &Settings{ServerURL: "https://dot-ai.example.com"}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateSettings: %v", err)
+		}
+	}
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if len(s.Contexts) != writers {
+		t.Errorf("got %d contexts, want %d: concurrent updates were lost", len(s.Contexts), writers)
+	}
+}
+
+func TestUpdateCredentialsFailureWritesNothing(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{AuthToken: "kept"})
+	err := UpdateCredentials(func(c *Credentials) error {
+		c.Servers = nil
+		return fmt.Errorf("changed my mind")
+	})
+	if err == nil {
+		t.Fatal("UpdateCredentials succeeded")
+	}
+	if got := storedToken(t); got != "kept" {
+		t.Errorf("token = %q, want the unchanged file", got)
+	}
+}
+
+func TestLoadRecoversCorruptFile(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{})
+	var warned strings.Builder
+	origWarnings := warnings
+	warnings = &warned
+	t.Cleanup(func() { warnings = origWarnings })
+
+	for _, url := range []string{"https://first.example.com", "https://second.example.com"} {
+		s := Settings{ServerURL: url}
+		if err := s.Save(); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	// A torn write leaves half a document behind.
+	if err := os.WriteFile(SettingsPath(), []byte(`{"server_url": "https://sec`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if s.ServerURL != "https://first.example.com" {
+		t.Errorf("ServerURL = %q, want the backup's", s.ServerURL)
+	}
+	if _, err := os.Stat(SettingsPath() + ".corrupt"); err != nil {
+		t.Errorf("corrupt file not kept: %v", err)
+	}
+	if !strings.Contains(warned.String(), "restored the backup") {
+		t.Errorf("warning = %q", warned.String())
+	}
+
+	// Without a usable backup the file starts afresh.
+	warned.Reset()
+	os.Remove(SettingsPath() + ".bak")
+	if err := os.WriteFile(SettingsPath(), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := LoadSettings(); err != nil || s.ServerURL != "" {
+		t.Errorf("LoadSettings = %+v, %v; want empty settings", s, err)
+	}
+	if !strings.Contains(warned.String(), "no backup was usable") {
+		t.Errorf("warning = %q", warned.String())
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CredentialHelperPrefix is prepended to a configured credential helper name
+// to find its executable on PATH, as Docker does with
+// docker-credential-<name>.
+const CredentialHelperPrefix = "dot-ai-credential-"
+
+// helperTimeout bounds one credential helper call; helpers may unlock a
+// keychain interactively.
+const helperTimeout = 2 * time.Minute
+
+// helperUsername is the user name stored alongside tokens; the protocol
+// requires one, but bearer tokens have none.
+const helperUsername = "dot-ai"
+
+// ErrCredentialsNotFound is returned by HelperGet when the helper holds no
+// credentials for the server.
+var ErrCredentialsNotFound = errors.New("credentials not found")
+
+// helperCredentials is the JSON document of the Docker credential helper
+// protocol.
+type helperCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// HelperGet asks the credential helper for the token of the origin of
+// serverURL ("get": the origin on stdin, credentials JSON on stdout).
+func HelperGet(helper, serverURL string) (string, error) {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return "", err
+	}
+	out, err := runHelper(helper, "get", []byte(origin))
+	if err != nil {
+		return "", err
+	}
+	var hc helperCredentials
+	if err := json.Unmarshal(out, &hc); err != nil {
+		return "", fmt.Errorf("credential helper %s: parsing response: %w", helper, err)
+	}
+	if hc.Secret == "" {
+		return "", ErrCredentialsNotFound
+	}
+	return hc.Secret, nil
+}
+
+// HelperStore stores token for the origin of serverURL with the credential
+// helper ("store": credentials JSON on stdin).
+func HelperStore(helper, serverURL, token string) error {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return err
+	}
+	in, err := json.Marshal(helperCredentials{ServerURL: origin, Username: helperUsername, Secret: token})
+	if err != nil {
+		return err
+	}
+	_, err = runHelper(helper, "store", in)
+	return err
+}
+
+// HelperErase removes the credentials for the origin of serverURL from the
+// credential helper ("erase": the origin on stdin). Credentials that are
+// already gone are not an error.
+func HelperErase(helper, serverURL string) error {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return err
+	}
+	if _, err := runHelper(helper, "erase", []byte(origin)); err != nil && !errors.Is(err, ErrCredentialsNotFound) {
+		return err
+	}
+	return nil
+}
+
+// runHelper runs dot-ai-credential-<helper> <action> with input on stdin.
+// Helpers report errors as text on stdout with a non-zero exit; a
+// "credentials not found" message maps to ErrCredentialsNotFound.
+func runHelper(helper, action string, input []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), helperTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, CredentialHelperPrefix+helper, action)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		if strings.Contains(strings.ToLower(msg), "credentials not found") {
+			return nil, ErrCredentialsNotFound
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("credential helper %s %s failed: %s", helper, action, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// StoreStaticToken stores a static token for the origin of serverURL: with
+// the credential helper when one is configured, otherwise as auth_token in
+// the named context of credentials.json.
+func StoreStaticToken(serverURL, contextName, helper, token string) error {
+	if helper != "" {
+		return HelperStore(helper, serverURL, token)
+	}
+	return updateServerEntry(serverURL, contextName, func(cred *Credentials) {
+		cred.AuthToken = token
+	})
+}
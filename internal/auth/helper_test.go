@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// installHelper puts a dot-ai-credential-test script on PATH that keeps one
+// secret per server in dir.
+func installHelper(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+store="` + dir + `/store"
+input=$(cat)
+case "$1" in
+get)
+	[ -f "$store" ] && grep -q "\"ServerURL\":\"$input\"" "$store" || { echo "credentials not found in native keychain"; exit 1; }
+	cat "$store" ;;
+store) printf '%s' "$input" > "$store" ;;
+erase) rm -f "$store" ;;
+*) echo "unknown action $1"; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, CredentialHelperPrefix+"test"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestCredentialHelperRoundTrip(t *testing.T) {
+	installHelper(t)
+	serverURL := "https://dot-ai.example.com/api"
+
+	if _, err := HelperGet("test", serverURL); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Fatalf("HelperGet before store = %v, want ErrCredentialsNotFound", err)
+	}
+	if err := HelperStore("test", serverURL, "helper-token"); err != nil {
+		t.Fatalf("HelperStore: %v", err)
+	}
+	token, err := HelperGet("test", "https://DOT-AI.example.com:443")
+	if err != nil || token != "helper-token" {
+		t.Fatalf("HelperGet = %q, %v; want the stored token for the same origin", token, err)
+	}
+	if _, err := HelperGet("test", "https://other.example.com"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("HelperGet for another origin = %v, want ErrCredentialsNotFound", err)
+	}
+	if err := HelperErase("test", serverURL); err != nil {
+		t.Fatalf("HelperErase: %v", err)
+	}
+	if _, err := HelperGet("test", serverURL); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("HelperGet after erase = %v, want ErrCredentialsNotFound", err)
+	}
+}
+
+func TestCredentialHelperFailure(t *testing.T) {
+	installHelper(t)
+	_, err := runHelper("test", "list", nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown action list") {
+		t.Errorf("runHelper error = %v, want the helper's message", err)
+	}
+	if _, err := HelperGet("missing", "https://dot-ai.example.com"); err == nil || errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("HelperGet with a missing helper = %v, want a failure", err)
+	}
+}
+
+func TestStoreStaticTokenWithoutHelper(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{})
+	if err := StoreStaticToken("https://dot-ai.example.com", "", "", "static-token"); err != nil {
+		t.Fatalf("StoreStaticToken: %v", err)
+	}
+	entry, err := loadServerEntry("https://dot-ai.example.com", "")
+	if err != nil || entry.AuthToken != "static-token" {
+		t.Errorf("auth_token = %q, %v; want static-token", entry.AuthToken, err)
+	}
+}
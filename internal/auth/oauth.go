@@ -1,21 +1,25 @@
 package auth
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,17 +29,57 @@ var openBrowserFunc = openBrowser
 // httpClientFunc can be overridden in tests.
 var httpClientFunc = http.DefaultClient.Do
 
-// registrationResponse holds the dynamic client registration result.
-type registrationResponse struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-}
-
 // tokenResponse holds the token endpoint result.
 type tokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// oauthError is an OAuth error (RFC 6749 §5.2): the response of a failed
+// request to an endpoint, or the error passed back on the authorization
+// redirect (Status 0).
+type oauthError struct {
+	What        string `json:"-"` // the failed request, e.g. "token exchange"
+	Status      int    `json:"-"`
+	Body        string `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// newOAuthError parses the error response of a failed request.
+func newOAuthError(what string, status int, body []byte) *oauthError {
+	e := &oauthError{What: what, Status: status, Body: string(body)}
+	_ = json.Unmarshal(body, e)
+	return e
+}
+
+func (e *oauthError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s failed (%d): %s", e.What, e.Status, e.Body)
+	}
+	msg := e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return "authorization failed: " + msg
+}
+
+// clientRejected reports whether err is the server refusing the client
+// itself, as it does for a registration it has deleted.
+func clientRejected(err error) bool {
+	var oe *oauthError
+	return errors.As(err, &oe) && (oe.Code == "invalid_client" || oe.Code == "unauthorized_client")
+}
+
+// expiresAt converts expires_in to an RFC 3339 timestamp, empty when the
+// server did not say.
+func (t *tokenResponse) expiresAt() string {
+	if t.ExpiresIn <= 0 {
+		return ""
+	}
+	return time.Now().Add(time.Duration(t.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
 }
 
 // GenerateCodeVerifier creates a random PKCE code verifier (43-128 chars, base64url).
@@ -54,15 +98,35 @@ func CodeChallenge(verifier string) string {
 }
 
 // RegisterClient performs dynamic client registration (RFC 7591).
-func RegisterClient(serverURL, redirectURI string) (*registrationResponse, error) {
-	regURL := strings.TrimRight(serverURL, "/") + "/register"
-
-	body, err := json.Marshal(map[string]any{
+func RegisterClient(meta *ServerMetadata, redirectURI string) (*RegisteredClient, error) {
+	return registerClient(meta, map[string]any{
 		"redirect_uris":              []string{redirectURI},
-		"grant_types":                []string{"authorization_code"},
+		"grant_types":                meta.clientGrants("authorization_code"),
 		"response_types":             []string{"code"},
 		"token_endpoint_auth_method": "client_secret_post",
 	})
+}
+
+// clientGrants lists the grant types to register a client for: grant, plus
+// refresh_token when the server issues refresh tokens.
+func (m *ServerMetadata) clientGrants(grant string) []string {
+	grants := []string{grant}
+	if m.SupportsGrant("refresh_token") {
+		grants = append(grants, "refresh_token")
+	}
+	return grants
+}
+
+// registerClient posts client metadata to the registration endpoint. The
+// registered redirect URIs and grant types default to the requested ones when
+// the server does not echo them.
+func registerClient(meta *ServerMetadata, client map[string]any) (*RegisteredClient, error) {
+	regURL := meta.RegistrationEndpoint
+	if regURL == "" {
+		return nil, fmt.Errorf("the server does not support dynamic client registration")
+	}
+
+	body, err := json.Marshal(client)
 	if err != nil {
 		return nil, fmt.Errorf("building registration request: %w", err)
 	}
@@ -90,17 +154,24 @@ func RegisterClient(serverURL, redirectURI string) (*registrationResponse, error
 		return nil, fmt.Errorf("client registration failed (%d): %s", resp.StatusCode, string(respBody))
 	}
 
-	var reg registrationResponse
+	var reg RegisteredClient
 	if err := json.Unmarshal(respBody, &reg); err != nil {
 		return nil, fmt.Errorf("parsing registration response: %w", err)
 	}
+	if reg.ClientID == "" {
+		return nil, fmt.Errorf("client registration response has no client_id")
+	}
+	if len(reg.RedirectURIs) == 0 {
+		reg.RedirectURIs, _ = client["redirect_uris"].([]string)
+	}
+	if len(reg.GrantTypes) == 0 {
+		reg.GrantTypes, _ = client["grant_types"].([]string)
+	}
 	return &reg, nil
 }
 
 // ExchangeCode exchanges an authorization code for an access token.
-func ExchangeCode(serverURL, code, redirectURI, codeVerifier, clientID, clientSecret string, requestedExpiry int) (*tokenResponse, error) {
-	tokenURL := strings.TrimRight(serverURL, "/") + "/token"
-
+func ExchangeCode(meta *ServerMetadata, code, redirectURI, codeVerifier, clientID, clientSecret string, requestedExpiry int) (*tokenResponse, error) {
 	data := url.Values{
 		"grant_type":    {"authorization_code"},
 		"code":          {code},
@@ -113,6 +184,12 @@ func ExchangeCode(serverURL, code, redirectURI, codeVerifier, clientID, clientSe
 		data.Set("requested_expiry", strconv.Itoa(requestedExpiry))
 	}
 
+	return requestToken(meta.TokenEndpoint, data, "token exchange")
+}
+
+// requestToken posts a form to the token endpoint and parses the token
+// response. what names the grant in error messages.
+func requestToken(tokenURL string, data url.Values, what string) (*tokenResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(data.Encode()))
@@ -123,7 +200,7 @@ func ExchangeCode(serverURL, code, redirectURI, codeVerifier, clientID, clientSe
 
 	resp, err := httpClientFunc(req)
 	if err != nil {
-		return nil, fmt.Errorf("token exchange failed: %w", err)
+		return nil, fmt.Errorf("%s failed: %w", what, err)
 	}
 	defer resp.Body.Close()
 
@@ -133,7 +210,7 @@ func ExchangeCode(serverURL, code, redirectURI, codeVerifier, clientID, clientSe
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token exchange failed (%d): %s", resp.StatusCode, string(respBody))
+		return nil, newOAuthError(what, resp.StatusCode, respBody)
 	}
 
 	var tok tokenResponse
@@ -143,20 +220,94 @@ func ExchangeCode(serverURL, code, redirectURI, codeVerifier, clientID, clientSe
 	return &tok, nil
 }
 
+// LoginOptions configures an interactive login.
+type LoginOptions struct {
+	// ServerURL is the server to authenticate against; the session is bound
+	// to its origin.
+	ServerURL string
+	// Context names the context the session is stored under (the flat
+	// credentials when empty).
+	Context string
+	// NoBrowser prints the authorization URL instead of opening a browser,
+	// and accepts the redirect URL pasted back on In.
+	NoBrowser bool
+	// TokenTTL is the requested token lifetime in seconds.
+	TokenTTL int
+	// CallbackPort fixes the loopback callback port (random when 0), so it
+	// can be forwarded from another machine.
+	CallbackPort int
+
+	// Out receives instructions for the user; In supplies a pasted redirect
+	// URL. They default to os.Stdout and os.Stdin.
+	Out io.Writer
+	In  io.Reader
+}
+
+func (o *LoginOptions) out() io.Writer {
+	if o.Out == nil {
+		return os.Stdout
+	}
+	return o.Out
+}
+
+func (o *LoginOptions) in() io.Reader {
+	if o.In == nil {
+		return os.Stdin
+	}
+	return o.In
+}
+
 // Login performs the full OAuth Authorization Code flow with PKCE.
 // It registers a dynamic client, starts a local callback server, opens the
-// browser, waits for the callback, exchanges the code, and stores credentials.
-func Login(serverURL string, noBrowser bool, tokenTTL int) error {
-	// Start local callback server on random port.
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+// browser, waits for the callback, exchanges the code, and stores credentials
+// under the named context (the flat credentials when opts.Context is empty),
+// bound to the origin of the server URL.
+//
+// With NoBrowser the user may instead paste the URL the browser was
+// redirected to, for when the callback listener is unreachable from the
+// browser (a remote host or container).
+func Login(opts LoginOptions) error {
+	serverURL := opts.ServerURL
+	out := opts.out()
+	if _, err := Origin(serverURL); err != nil {
+		return err
+	}
+
+	// Fetch the server's endpoints afresh, so a login picks up changes.
+	meta, err := discover(serverURL, true)
+	if err != nil {
+		return err
+	}
+	if meta.AuthorizationEndpoint == "" {
+		return fmt.Errorf("the server does not advertise an authorization endpoint; try 'dot-ai auth login --device'")
+	}
+	if err := meta.requireGrant("authorization_code", "browser login"); err != nil {
+		return err
+	}
+	method, err := meta.pkceMethod()
+	if err != nil {
+		return err
+	}
+
+	entry, err := loadServerEntry(serverURL, opts.Context)
+	if err != nil {
+		return err
+	}
+
+	// Start local callback server: on the port of a stored registration when
+	// one is free, so its redirect URI matches and the client is reused, else
+	// on a random port (or --callback-port).
+	listener, candidate, err := callbackListener(entry.registeredClients("authorization_code"), opts.CallbackPort)
 	if err != nil {
 		return fmt.Errorf("starting callback server: %w", err)
 	}
 	addr := listener.Addr().(*net.TCPAddr)
 	redirectURI := fmt.Sprintf("http://%s:%d/callback", addr.IP.String(), addr.Port)
 
-	// Register dynamic client.
-	reg, err := RegisterClient(serverURL, redirectURI)
+	// Reuse the registered client, or register a new one.
+	reg, reused, err := obtainClient(serverURL, opts.Context, candidate, func() (*RegisteredClient, error) {
+		return RegisterClient(meta, redirectURI)
+	})
 	if err != nil {
 		listener.Close()
 		return err
@@ -168,49 +319,72 @@ func Login(serverURL string, noBrowser bool, tokenTTL int) error {
 		listener.Close()
 		return err
 	}
-	challenge := CodeChallenge(verifier)
+	challenge := verifier
+	if method == "S256" {
+		challenge = CodeChallenge(verifier)
+	}
+	state, err := GenerateCodeVerifier()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	authReq := &authorizationRequest{state: state, issuer: meta.Issuer, requireIss: meta.AuthorizationResponseIssParameterSupported}
 
 	// Channel to receive the authorization code from the callback.
 	codeCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
+	// The callback is single-use: once a redirect for this login has been
+	// handled, further hits (a reload, a replay) are ignored. Requests
+	// without this login's state (stray or forged) are rejected without
+	// using up the callback, so they cannot abort the login.
+	var handled atomic.Bool
 	mux := http.NewServeMux()
 	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			errMsg := r.URL.Query().Get("error")
-			if errMsg == "" {
-				errMsg = "no authorization code received"
-			}
-			errCh <- fmt.Errorf("authorization failed: %s", errMsg)
-			fmt.Fprintf(w, "<html><body><h1>Authentication failed</h1><p>%s</p><p>You can close this window.</p></body></html>", html.EscapeString(errMsg))
+		code, err := authReq.codeFromRedirect(r.URL.Query())
+		if errors.Is(err, errStateMismatch) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<html><body><h1>This response does not belong to the current login</h1><p>You can close this window.</p></body></html>")
+			return
+		}
+		if handled.Swap(true) {
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprint(w, "<html><body><h1>This login has already been handled</h1><p>You can close this window.</p></body></html>")
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			deliver(errCh, err)
+			fmt.Fprintf(w, "<html><body><h1>Authentication failed</h1><p>%s</p><p>You can close this window.</p></body></html>", html.EscapeString(err.Error()))
 			return
 		}
-		codeCh <- code
+		deliver(codeCh, code)
 		fmt.Fprint(w, "<html><body><h1>Authentication successful</h1><p>You can close this window.</p></body></html>")
 	})
 
 	srv := &http.Server{Handler: mux}
 	go func() {
 		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
-			errCh <- fmt.Errorf("callback server error: %w", err)
+			deliver(errCh, fmt.Errorf("callback server error: %w", err))
 		}
 	}()
 
 	// Build authorization URL and open browser.
-	authURL := fmt.Sprintf("%s/authorize?response_type=code&client_id=%s&redirect_uri=%s&code_challenge=%s&code_challenge_method=S256",
-		strings.TrimRight(serverURL, "/"),
-		url.QueryEscape(reg.ClientID),
-		url.QueryEscape(redirectURI),
-		url.QueryEscape(challenge),
-	)
-
-	if noBrowser {
-		fmt.Printf("Open this URL in your browser:\n%s\n", authURL)
+	authURL, err := authorizationURL(meta, reg.ClientID, redirectURI, challenge, method, state)
+	if err != nil {
+		srv.Shutdown(context.Background())
+		return err
+	}
+
+	if opts.NoBrowser {
+		fmt.Fprintf(out, "Open this URL in your browser:\n%s\n\n", authURL)
+		fmt.Fprintln(out, "If the browser cannot reach this machine, paste the URL it was redirected to")
+		fmt.Fprint(out, "(starting with "+redirectURI+"?code=) and press Enter: ")
+		go authReq.readPastedRedirect(opts.in(), codeCh, errCh)
 	} else {
-		fmt.Println("Opening browser for authentication...")
+		fmt.Fprintln(out, "Opening browser for authentication...")
 		if err := openBrowserFunc(authURL); err != nil {
-			fmt.Printf("Could not open browser. Please visit:\n%s\n", authURL)
+			fmt.Fprintf(out, "Could not open browser. Please visit:\n%s\n", authURL)
 		}
 	}
 
@@ -220,6 +394,10 @@ func Login(serverURL string, noBrowser bool, tokenTTL int) error {
 	case code = <-codeCh:
 	case err := <-errCh:
 		srv.Shutdown(context.Background())
+		if reused && clientRejected(err) {
+			dropClient(serverURL, opts.Context, reg.ClientID)
+			return rejectedClientError(err)
+		}
 		return err
 	case <-time.After(5 * time.Minute):
 		srv.Shutdown(context.Background())
@@ -230,46 +408,209 @@ func Login(serverURL string, noBrowser bool, tokenTTL int) error {
 	srv.Shutdown(context.Background())
 
 	// Exchange code for token.
-	tok, err := ExchangeCode(serverURL, code, redirectURI, verifier, reg.ClientID, reg.ClientSecret, tokenTTL)
+	tok, err := ExchangeCode(meta, code, redirectURI, verifier, reg.ClientID, reg.ClientSecret, opts.TokenTTL)
 	if err != nil {
+		if reused && clientRejected(err) {
+			dropClient(serverURL, opts.Context, reg.ClientID)
+			return rejectedClientError(err)
+		}
+		return err
+	}
+	if err := storeSession(serverURL, opts.Context, tok, reg); err != nil {
 		return err
 	}
 
-	// Compute expiry time.
-	expiresAt := ""
-	if tok.ExpiresIn > 0 {
-		expiresAt = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
+	fmt.Fprintln(out, "Authentication successful.")
+	return nil
+}
+
+// authorizationURL builds the authorization request for the advertised
+// authorization endpoint, keeping any query it already carries.
+// offline_access asks for a refresh token, when the server issues them, so
+// the session can be renewed without another browser round-trip. state
+// binds the response to this request.
+func authorizationURL(meta *ServerMetadata, clientID, redirectURI, challenge, method, state string) (string, error) {
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint %q: %w", meta.AuthorizationEndpoint, err)
 	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", method)
+	q.Set("state", state)
+	if meta.SupportsGrant("refresh_token") {
+		q.Set("scope", "offline_access")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// authorizationRequest holds what an authorization response must match.
+type authorizationRequest struct {
+	// state is the random value sent with the request; the response must
+	// echo it, or it was not issued for this login.
+	state string
+	// issuer is the authorization server's issuer. A response carrying iss
+	// (RFC 9207) must name it; requireIss makes iss mandatory, for servers
+	// that advertise sending it.
+	issuer     string
+	requireIss bool
+}
 
-	// Store credentials.
-	creds, err := LoadCredentials()
+// errStateMismatch is returned for a redirect whose state was not issued for
+// this login.
+var errStateMismatch = errors.New("authorization failed: the response does not match this login request (state mismatch); run 'dot-ai auth login' again")
+
+// codeFromRedirect extracts the authorization code from the query of the
+// redirect to the callback, turning an error response into an error. The
+// state and issuer are verified first, for error responses too.
+func (a *authorizationRequest) codeFromRedirect(q url.Values) (string, error) {
+	if q.Get("state") != a.state {
+		return "", errStateMismatch
+	}
+	if iss := q.Get("iss"); iss != "" {
+		if a.issuer != "" && strings.TrimRight(iss, "/") != strings.TrimRight(a.issuer, "/") {
+			return "", fmt.Errorf("authorization failed: the response was issued by %s, not by %s", iss, a.issuer)
+		}
+	} else if a.requireIss {
+		return "", fmt.Errorf("authorization failed: the response has no iss parameter although the server advertises one")
+	}
+	if code := q.Get("code"); code != "" {
+		return code, nil
+	}
+	if q.Get("error") == "" {
+		return "", fmt.Errorf("authorization failed: no authorization code received")
+	}
+	return "", &oauthError{Code: q.Get("error"), Description: q.Get("error_description")}
+}
+
+// readPastedRedirect reads one line from in: the redirect URL copied from the
+// browser's address bar. Blank input and EOF (no terminal attached) are
+// ignored so the callback listener can still complete the login.
+func (a *authorizationRequest) readPastedRedirect(in io.Reader, codeCh chan<- string, errCh chan<- error) {
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	u, err := url.Parse(line)
+	if err != nil || u.RawQuery == "" {
+		deliver(errCh, fmt.Errorf("could not read an authorization code from the pasted URL; paste the full address the browser was redirected to"))
+		return
+	}
+	code, err := a.codeFromRedirect(u.Query())
 	if err != nil {
-		return fmt.Errorf("loading credentials: %w", err)
+		deliver(errCh, err)
+		return
 	}
-	creds.AccessToken = tok.AccessToken
-	creds.TokenType = tok.TokenType
-	creds.ExpiresAt = expiresAt
-	creds.ClientID = reg.ClientID
-	creds.ClientSecret = reg.ClientSecret
-	if err := creds.Save(); err != nil {
-		return fmt.Errorf("saving credentials: %w", err)
+	deliver(codeCh, code)
+}
+
+// deliver sends v on a buffered result channel without blocking: the first
+// result wins and later ones (a second callback, a paste after the callback)
+// are dropped.
+func deliver[T any](ch chan<- T, v T) {
+	select {
+	case ch <- v:
+	default:
 	}
+}
 
-	fmt.Println("Authentication successful.")
-	return nil
+// storeSession stores a freshly issued OAuth session for the origin of
+// serverURL in the named context, and marks the client it was issued to as
+// used.
+func storeSession(serverURL, contextName string, tok *tokenResponse, reg *RegisteredClient) error {
+	return updateServerEntry(serverURL, contextName, func(cred *Credentials) {
+		cred.ClearOAuth()
+		cred.setToken(tok)
+		cred.ClientID = reg.ClientID
+		cred.ClientSecret = reg.ClientSecret
+		cred.rememberClient(reg)
+	})
+}
+
+// LogoutResult reports what Logout did on the server.
+type LogoutResult struct {
+	// Revoked reports whether the server's revocation endpoint (RFC 7009)
+	// accepted the session's tokens.
+	Revoked bool
+	// RevokeErr is why revocation failed. The stored session is removed
+	// regardless.
+	RevokeErr error
 }
 
-// Logout clears OAuth session fields from credentials.json.
-func Logout() error {
-	creds, err := LoadCredentials()
+// Logout ends the OAuth session stored for the origin of serverURL in the
+// named context (the flat credentials when contextName is empty). When the
+// server advertises a revocation endpoint the tokens are revoked there first,
+// so they stop working server-side too; then the session fields and any cached
+// exec provider token are cleared from credentials.json.
+func Logout(serverURL, contextName string) (*LogoutResult, error) {
+	entry, err := loadServerEntry(serverURL, contextName)
 	if err != nil {
-		return fmt.Errorf("loading credentials: %w", err)
+		return nil, err
 	}
-	creds.ClearOAuth()
-	if err := creds.Save(); err != nil {
-		return fmt.Errorf("saving credentials: %w", err)
+	result := &LogoutResult{}
+	if entry.AccessToken != "" || entry.RefreshToken != "" {
+		result.Revoked, result.RevokeErr = revokeSession(serverURL, entry)
 	}
-	return nil
+	err = updateServerEntry(serverURL, contextName, func(cred *Credentials) {
+		cred.ClearOAuth()
+		cred.ExecToken = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// revokeSession revokes the refresh token and the access token of a stored
+// session (RFC 7009). It reports false without an error when the server does
+// not advertise a revocation endpoint.
+func revokeSession(serverURL string, cred *Credentials) (bool, error) {
+	meta, err := Discover(serverURL)
+	if err != nil {
+		return false, err
+	}
+	if meta.RevocationEndpoint == "" {
+		return false, nil
+	}
+
+	clientSecret := cred.ClientSecret
+	if cred.GrantType == GrantClientCredentials {
+		// The secret is never stored; revoke with it when it is at hand.
+		if cc, err := LoadClientCredentials(cred.ClientSecretFile); err == nil && cc.ClientID == cred.ClientID {
+			clientSecret = cc.ClientSecret
+		}
+	}
+
+	// The refresh token goes first: revoking it usually ends the whole grant.
+	for _, t := range []struct{ token, hint string }{
+		{cred.RefreshToken, "refresh_token"},
+		{cred.AccessToken, "access_token"},
+	} {
+		if t.token == "" {
+			continue
+		}
+		data := url.Values{
+			"token":           {t.token},
+			"token_type_hint": {t.hint},
+			"client_id":       {cred.ClientID},
+		}
+		if clientSecret != "" {
+			data.Set("client_secret", clientSecret)
+		}
+		status, body, err := postForm(meta.RevocationEndpoint, data)
+		if err != nil {
+			return false, fmt.Errorf("token revocation failed: %w", err)
+		}
+		if status != http.StatusOK {
+			return false, newOAuthError("token revocation", status, body)
+		}
+	}
+	return true, nil
 }
 
 // StatusInfo holds information about the current authentication state.
@@ -278,18 +619,40 @@ type StatusInfo struct {
 	Token     string // masked token for display
 	ExpiresAt string // RFC 3339 timestamp (OAuth only)
 	Expired   bool   // whether the OAuth token is expired
+	// Refreshable reports whether a refresh token is stored, so an expired
+	// OAuth token is renewed on the next request.
+	Refreshable bool
+	// GrantType is GrantClientCredentials for a service-account session.
+	GrantType string
+	ClientID  string
+
+	Origin  string   // normalised origin of the server the status is for
+	Origins []string // origins that hold stored credentials
 }
 
-// Status returns information about the current authentication state.
-// It follows the same token-selection precedence as config.Resolve():
-// auth_token (static) > access_token (OAuth, only if not expired).
-func Status() (*StatusInfo, error) {
-	creds, err := LoadCredentials()
+// Status returns information about the authentication state for the origin
+// of serverURL in the named context (the flat credentials when contextName is
+// empty). It reports the stored credentials only, in the order
+// config.Resolve() tries them: auth_token (static) > access_token (OAuth,
+// only if not expired). Tokens from flags, the environment, an exec
+// provider or a credential helper, which Resolve tries first, are not
+// covered.
+func Status(serverURL, contextName string) (*StatusInfo, error) {
+	all, err := LoadCredentials()
 	if err != nil {
 		return nil, fmt.Errorf("loading credentials: %w", err)
 	}
+	profile := all.For(contextName)
 
-	info := &StatusInfo{Mode: "none"}
+	info := &StatusInfo{Mode: "none", Origins: profile.Origins()}
+	info.Origin, err = Origin(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	creds := profile.Server(serverURL)
+	if creds == nil {
+		return info, nil
+	}
 
 	// Static token takes precedence, matching config.Resolve() behavior.
 	if creds.AuthToken != "" {
@@ -302,6 +665,9 @@ func Status() (*StatusInfo, error) {
 		info.Mode = "oauth"
 		info.Token = maskToken(creds.AccessToken)
 		info.ExpiresAt = creds.ExpiresAt
+		info.Refreshable = creds.Renewable()
+		info.GrantType = creds.GrantType
+		info.ClientID = creds.ClientID
 		if creds.ExpiresAt != "" {
 			t, err := time.Parse(time.RFC3339, creds.ExpiresAt)
 			if err == nil {
@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -46,3 +51,185 @@ func TestMaskToken(t *testing.T) {
 		}
 	}
 }
+
+// newLoginServer serves registration and the authorization code exchange
+// under the fallback paths.
+func newLoginServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/register":
+			json.NewEncoder(w).Encode(map[string]string{"client_id": "cid", "client_secret": "secret"})
+		case "/token":
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "token-" + r.Form.Get("code"), "expires_in": 3600})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeBrowser replaces the browser with one that follows the authorization
+// URL to the callback with the query built by redirect.
+func fakeBrowser(t *testing.T, redirect func(state string) []url.Values) *[]int {
+	t.Helper()
+	var statuses []int
+	orig := openBrowserFunc
+	openBrowserFunc = func(rawURL string) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Errorf("authorization URL: %v", err)
+			return nil
+		}
+		callback := u.Query().Get("redirect_uri")
+		for _, q := range redirect(u.Query().Get("state")) {
+			resp, err := http.Get(callback + "?" + q.Encode())
+			if err != nil {
+				t.Errorf("callback: %v", err)
+				continue
+			}
+			resp.Body.Close()
+			statuses = append(statuses, resp.StatusCode)
+		}
+		return nil
+	}
+	t.Cleanup(func() { openBrowserFunc = orig })
+	return &statuses
+}
+
+func TestLoginCallbackIsSingleUse(t *testing.T) {
+	srv := newLoginServer(t)
+	saveTestSession(t, srv.URL, Credentials{})
+	statuses := fakeBrowser(t, func(state string) []url.Values {
+		return []url.Values{
+			{"code": {"good"}, "state": {state}},
+			{"code": {"replayed"}, "state": {state}},
+		}
+	})
+
+	if err := Login(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if len(*statuses) != 2 || (*statuses)[0] != http.StatusOK || (*statuses)[1] != http.StatusGone {
+		t.Errorf("callback statuses = %v, want [200 410]", *statuses)
+	}
+	creds, _ := LoadCredentials()
+	if got := creds.Server(srv.URL); got == nil || got.AccessToken != "token-good" {
+		t.Errorf("stored session = %+v, want the first code's token", got)
+	}
+}
+
+func TestLoginIgnoresStateMismatch(t *testing.T) {
+	srv := newLoginServer(t)
+	saveTestSession(t, srv.URL, Credentials{})
+	statuses := fakeBrowser(t, func(state string) []url.Values {
+		return []url.Values{
+			{"code": {"forged"}, "state": {"not-" + state}},
+			{"code": {"good"}, "state": {state}},
+		}
+	})
+
+	// A forged callback is rejected without ending the login, which the
+	// genuine redirect then completes.
+	if err := Login(LoginOptions{ServerURL: srv.URL, Out: &strings.Builder{}}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if len(*statuses) != 2 || (*statuses)[0] != http.StatusBadRequest || (*statuses)[1] != http.StatusOK {
+		t.Errorf("callback statuses = %v, want [400 200]", *statuses)
+	}
+	creds, _ := LoadCredentials()
+	if got := creds.Server(srv.URL); got == nil || got.AccessToken != "token-good" {
+		t.Errorf("stored session = %+v, want the genuine code's token", got)
+	}
+}
+
+func TestCodeFromRedirectValidatesIssuer(t *testing.T) {
+	tests := []struct {
+		name       string
+		requireIss bool
+		query      url.Values
+		wantErr    string
+	}{
+		{"matching iss", false, url.Values{"code": {"c"}, "state": {"s"}, "iss": {"https://idp.example.com/"}}, ""},
+		{"no iss", false, url.Values{"code": {"c"}, "state": {"s"}}, ""},
+		{"foreign iss", false, url.Values{"code": {"c"}, "state": {"s"}, "iss": {"https://evil.example.com"}}, "issued by https://evil.example.com"},
+		{"missing required iss", true, url.Values{"code": {"c"}, "state": {"s"}}, "no iss parameter"},
+		{"error response with foreign iss", false, url.Values{"error": {"access_denied"}, "state": {"s"}, "iss": {"https://evil.example.com"}}, "issued by"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authorizationRequest{state: "s", issuer: "https://idp.example.com", requireIss: tt.requireIss}
+			code, err := a.codeFromRedirect(tt.query)
+			if tt.wantErr == "" {
+				if err != nil || code != "c" {
+					t.Errorf("codeFromRedirect = %q, %v", code, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("codeFromRedirect error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	var revoked []string
+	fail := false
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/.well-known/oauth-authorization-server":
+			json.NewEncoder(w).Encode(map[string]any{
+				"issuer":              srvURL,
+				"token_endpoint":      srvURL + "/token",
+				"revocation_endpoint": srvURL + "/revoke",
+			})
+		case "/revoke":
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.Form.Get("client_id") != "cid" || r.Form.Get("client_secret") != "secret" {
+				t.Errorf("revocation without client authentication: %v", r.Form)
+			}
+			revoked = append(revoked, r.Form.Get("token_type_hint")+"="+r.Form.Get("token"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	session := Credentials{AccessToken: "access", RefreshToken: "refresh", ClientID: "cid", ClientSecret: "secret"}
+	saveTestSession(t, srv.URL, session)
+	result, err := Logout(srv.URL, "")
+	if err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if !result.Revoked || strings.Join(revoked, ",") != "refresh_token=refresh,access_token=access" {
+		t.Errorf("Revoked = %v, revocations %v; want the refresh token, then the access token", result.Revoked, revoked)
+	}
+	creds, _ := LoadCredentials()
+	if creds.Server(srv.URL) != nil {
+		t.Errorf("session still stored: %+v", creds.Server(srv.URL))
+	}
+
+	// A failed revocation is reported, but the session is removed anyway.
+	fail = true
+	saveTestSession(t, srv.URL, session)
+	result, err = Logout(srv.URL, "")
+	if err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if result.Revoked || result.RevokeErr == nil {
+		t.Errorf("result = %+v, want a revocation error", result)
+	}
+	creds, _ = LoadCredentials()
+	if creds.Server(srv.URL) != nil {
+		t.Errorf("session still stored after a failed revocation")
+	}
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Origin normalises a server URL to the origin credentials are bound to:
+// lower-cased scheme and host, the scheme's default port dropped, and any
+// path, query or fragment discarded. Only http and https URLs are accepted.
+func Origin(serverURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %w", serverURL, err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("invalid server URL %q: scheme must be http or https", serverURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "", fmt.Errorf("invalid server URL %q: missing host", serverURL)
+	}
+	port := u.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	return scheme + "://" + host, nil
+}
+
+// Server returns the credentials stored for the origin of serverURL, or nil
+// when there are none (or serverURL is not a valid server URL).
+func (c *Credentials) Server(serverURL string) *Credentials {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return nil
+	}
+	return c.Servers[origin]
+}
+
+// ForServer returns the credentials for the origin of serverURL, creating an
+// empty entry when none exists yet.
+func (c *Credentials) ForServer(serverURL string) (*Credentials, error) {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	if c.Servers == nil {
+		c.Servers = map[string]*Credentials{}
+	}
+	entry, ok := c.Servers[origin]
+	if !ok || entry == nil {
+		entry = &Credentials{}
+		c.Servers[origin] = entry
+	}
+	return entry, nil
+}
+
+// Origins returns the origins that hold a static or OAuth token, sorted.
+func (c *Credentials) Origins() []string {
+	var origins []string
+	for origin, entry := range c.Servers {
+		if entry != nil && entry.hasToken() {
+			origins = append(origins, origin)
+		}
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// Prune drops server entries that no longer hold any credential or client
+// registration.
+func (c *Credentials) Prune() {
+	for origin, entry := range c.Servers {
+		if entry == nil || (!entry.hasToken() && entry.ClientID == "" && len(entry.Clients) == 0 && entry.ExecToken == nil) {
+			delete(c.Servers, origin)
+		}
+	}
+}
+
+// hasToken reports whether c holds a static or OAuth token.
+func (c *Credentials) hasToken() bool {
+	return c.AuthToken != "" || c.AccessToken != ""
+}
+
+// hasUnbound reports whether c holds credentials in its top-level fields,
+// which predate origin binding.
+func (c *Credentials) hasUnbound() bool {
+	return c.hasToken() || c.ClientID != ""
+}
+
+// bindTo moves the unbound top-level credentials of c to origin. An existing
+// entry for origin wins; the unbound values are discarded in that case. When
+// serverURL has no valid origin c is left untouched and bindTo reports
+// false, so the credentials are kept until the server URL is fixed.
+func (c *Credentials) bindTo(serverURL string) bool {
+	if _, err := Origin(serverURL); err != nil {
+		return false
+	}
+	unbound := Credentials{
+		AuthToken:    c.AuthToken,
+		AccessToken:  c.AccessToken,
+		RefreshToken: c.RefreshToken,
+		TokenType:    c.TokenType,
+		ExpiresAt:    c.ExpiresAt,
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+
+		GrantType:        c.GrantType,
+		ClientSecretFile: c.ClientSecretFile,
+	}
+	c.AuthToken = ""
+	c.ClearOAuth()
+
+	if c.Server(serverURL) == nil {
+		entry, _ := c.ForServer(serverURL)
+		*entry = unbound
+	}
+	return true
+}
+
+// BindOrigins migrates credentials stored before they were bound to a server
+// origin. Unbound credentials of the flat profile are bound to the flat
+// settings' server URL, and those of each context to that context's server
+// URL; defaultURL stands in where no server URL is configured. Credentials
+// whose server URL is not a valid http(s) URL stay unbound. It reports
+// whether anything changed.
+func BindOrigins(s *Settings, c *Credentials, defaultURL string) bool {
+	changed := false
+	bind := func(cred *Credentials, serverURL string) {
+		if cred == nil || !cred.hasUnbound() {
+			return
+		}
+		if serverURL == "" {
+			serverURL = defaultURL
+		}
+		if cred.bindTo(serverURL) {
+			changed = true
+		}
+	}
+
+	bind(c, s.ServerURL)
+	for name, cred := range c.Contexts {
+		serverURL := ""
+		if ctx := s.Contexts[name]; ctx != nil {
+			serverURL = ctx.ServerURL
+		}
+		bind(cred, serverURL)
+	}
+	return changed
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrigin(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"https://Dot-AI.Example.com/api/v1?x=1", "https://dot-ai.example.com", false},
+		{"HTTPS://example.com:443", "https://example.com", false},
+		{"http://example.com:80/", "http://example.com", false},
+		{"http://localhost:3456", "http://localhost:3456", false},
+		{"https://example.com:8443", "https://example.com:8443", false},
+		{"http://[::1]:3456/", "http://[::1]:3456", false},
+		{"ftp://example.com", "", true},
+		{"example.com", "", true},
+		{"https://", "", true},
+	}
+	for _, tc := range cases {
+		got, err := Origin(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Origin(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Origin(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestCredentialsServerLookupMatchesOriginOnly(t *testing.T) {
+	var c Credentials
+	entry, err := c.ForServer("https://prod.example.com/")
+	if err != nil {
+		t.Fatalf("ForServer: %v", err)
+	}
+	entry.AuthToken = "prod-token"
+
+	if got := c.Server("https://PROD.example.com:443/api"); got == nil || got.AuthToken != "prod-token" {
+		t.Errorf("Server(same origin) = %+v, want the prod entry", got)
+	}
+	for _, other := range []string{"https://prod.example.com.evil.io", "http://prod.example.com", "https://prod.example.com:8443", "not a url"} {
+		if got := c.Server(other); got != nil {
+			t.Errorf("Server(%q) = %+v, want nil", other, got)
+		}
+	}
+	if got := c.Origins(); !reflect.DeepEqual(got, []string{"https://prod.example.com"}) {
+		t.Errorf("Origins = %v", got)
+	}
+}
+
+func TestBindOrigins(t *testing.T) {
+	s := Settings{
+		ServerURL: "https://flat.example.com",
+		Contexts: map[string]*Settings{
+			"dev":  {ServerURL: "https://dev.example.com"},
+			"bare": {},
+		},
+	}
+	c := Credentials{
+		AuthToken: "flat-token",
+		Contexts: map[string]*Credentials{
+			"dev":  {AccessToken: "dev-token", ExpiresAt: "2099-01-01T00:00:00Z", ClientID: "cid"},
+			"bare": {AuthToken: "bare-token"},
+		},
+	}
+
+	if !BindOrigins(&s, &c, "http://localhost:3456") {
+		t.Fatal("BindOrigins = false, want true")
+	}
+	if c.AuthToken != "" {
+		t.Errorf("flat AuthToken left unbound: %q", c.AuthToken)
+	}
+	if got := c.Server("https://flat.example.com"); got == nil || got.AuthToken != "flat-token" {
+		t.Errorf("flat token bound to %+v", c.Servers)
+	}
+	dev := c.Contexts["dev"].Server("https://dev.example.com")
+	if dev == nil || dev.AccessToken != "dev-token" || dev.ClientID != "cid" {
+		t.Errorf("dev credentials bound to %+v", c.Contexts["dev"].Servers)
+	}
+	if got := c.Contexts["bare"].Server("http://localhost:3456"); got == nil || got.AuthToken != "bare-token" {
+		t.Errorf("context without server URL bound to %+v, want the default URL", c.Contexts["bare"].Servers)
+	}
+
+	if BindOrigins(&s, &c, "http://localhost:3456") {
+		t.Error("second BindOrigins = true, want no-op")
+	}
+}
+
+func TestBindOriginsInvalidServerURL(t *testing.T) {
+	s := Settings{ServerURL: "localhost:3456"}
+	c := Credentials{AuthToken: "flat-token", AccessToken: "oauth-token", RefreshToken: "refresh"}
+
+	if BindOrigins(&s, &c, "http://localhost:3456") {
+		t.Error("BindOrigins = true for a server URL without a scheme, want no change")
+	}
+	if c.AuthToken != "flat-token" || c.AccessToken != "oauth-token" || c.RefreshToken != "refresh" {
+		t.Errorf("unbound credentials cleared: %+v", c)
+	}
+	if len(c.Servers) != 0 {
+		t.Errorf("credentials bound to %+v", c.Servers)
+	}
+
+	// Once the server URL is fixed they are bound to it.
+	s.ServerURL = "http://localhost:3456"
+	if !BindOrigins(&s, &c, "http://localhost:3456") {
+		t.Fatal("BindOrigins = false after fixing the server URL")
+	}
+	if got := c.Server("http://localhost:3456"); got == nil || got.AuthToken != "flat-token" || got.RefreshToken != "refresh" {
+		t.Errorf("credentials bound to %+v", c.Servers)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package auth
+
+import "os"
+
+// ownedByUser cannot check ownership without Unix file modes; the unlock
+// cache is only used under XDG_RUNTIME_DIR, which such platforms lack.
+func ownedByUser(fi os.FileInfo) bool { return true }
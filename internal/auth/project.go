@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the project-local settings file, discovered by walking
+// up from the working directory.
+const ProjectFileName = ".dot-ai.yaml"
+
+// FindProjectFile walks up from dir looking for ProjectFileName. The walk
+// stops at the git root (the first directory containing .git) so a file in
+// an unrelated parent directory never applies. It returns the file's path,
+// or "" when there is none, and the directory the walk stopped at: the git
+// root, or dir itself when dir is not inside a git repository.
+func FindProjectFile(dir string) (path, root string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	start := dir
+	for {
+		candidate := filepath.Join(dir, ProjectFileName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			path = candidate
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return path, dir, nil
+		}
+		if path != "" {
+			return path, dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", start, nil
+		}
+		dir = parent
+	}
+}
+
+// ProjectFilePath returns where 'config set --local' writes for dir: the
+// discovered project file, or a new one at the git root (dir itself outside
+// a repository).
+func ProjectFilePath(dir string) (string, error) {
+	path, root, err := FindProjectFile(dir)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = filepath.Join(root, ProjectFileName)
+	}
+	return path, nil
+}
+
+// LoadProjectSettings reads the project file that applies to dir. It returns
+// zero-value Settings and an empty path when there is none. Unknown keys are
+// rejected so a typo does not silently fall through to the user settings.
+func LoadProjectSettings(dir string) (*Settings, string, error) {
+	path, _, err := FindProjectFile(dir)
+	if err != nil || path == "" {
+		return &Settings{}, "", err
+	}
+	s, err := readProjectFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, path, nil
+}
+
+// readProjectFile parses a project file. A missing file yields zero-value
+// Settings.
+func readProjectFile(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Settings{}, nil
+		}
+		return nil, err
+	}
+	parsed, err := ParseProjectSettings(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return parsed, nil
+}
+
+// ParseProjectSettings parses settings in the project file format, which is
+// also the format of 'config export'. JSON is accepted as YAML. Unknown keys
+// are an error.
+func ParseProjectSettings(data []byte) (*Settings, error) {
+	var s Settings
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ExportSettings renders the shareable preferences of a profile in the
+// project file format, stamped with the schema version. Credential helpers,
+// exec providers and contexts are left out.
+func ExportSettings(s *Settings) ([]byte, error) {
+	export := s.flatProfile()
+	export.SchemaVersion = SettingsSchemaVersion
+	return yaml.Marshal(export)
+}
+
+// UpdateProjectFile applies update to the project file at path and writes
+// it back, creating the file when needed. Project files hold no secrets and
+// are meant to be committed, so they are written world-readable.
+func UpdateProjectFile(path string, update func(*Settings)) error {
+	s, err := readProjectFile(path)
+	if err != nil {
+		return err
+	}
+	update(s)
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if string(data) == "{}\n" {
+		data = nil
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindProjectFileStopsAtGitRoot(t *testing.T) {
+	outer := t.TempDir()
+	repo := filepath.Join(outer, "repo")
+	sub := filepath.Join(repo, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A file above the git root must not apply.
+	if err := os.WriteFile(filepath.Join(outer, ProjectFileName), []byte("server_url: https://outer\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, root, err := FindProjectFile(sub)
+	if err != nil {
+		t.Fatalf("FindProjectFile: %v", err)
+	}
+	if path != "" || root != repo {
+		t.Errorf("FindProjectFile = (%q, %q), want no file and root %q", path, root, repo)
+	}
+
+	want := filepath.Join(repo, "a", ProjectFileName)
+	if err := os.WriteFile(want, []byte("server_url: https://project.example.com\nskills_include: query\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, path, err := LoadProjectSettings(sub)
+	if err != nil {
+		t.Fatalf("LoadProjectSettings: %v", err)
+	}
+	if path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if s.ServerURL != "https://project.example.com" || s.SkillsInclude != "query" {
+		t.Errorf("settings = %+v", s)
+	}
+}
+
+func TestLoadProjectSettingsRejectsUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ProjectFileName), []byte("server-url: https://typo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := LoadProjectSettings(dir)
+	if err == nil || !strings.Contains(err.Error(), "server-url") {
+		t.Errorf("err = %v, want an error naming the unknown key", err)
+	}
+}
+
+func TestUpdateProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := ProjectFilePath(sub)
+	if err != nil {
+		t.Fatalf("ProjectFilePath: %v", err)
+	}
+	if want := filepath.Join(dir, ProjectFileName); path != want {
+		t.Errorf("ProjectFilePath = %q, want the git root %q", path, want)
+	}
+	if err := UpdateProjectFile(path, func(s *Settings) { s.OutputFormat = "json" }); err != nil {
+		t.Fatalf("UpdateProjectFile: %v", err)
+	}
+	s, found, err := LoadProjectSettings(sub)
+	if err != nil || found != path || s.OutputFormat != "json" {
+		t.Errorf("LoadProjectSettings = (%+v, %q, %v)", s, found, err)
+	}
+}
+
+func TestExportSettingsRoundTrip(t *testing.T) {
+	s := &Settings{
+		ServerURL:        "https://team.example.com",
+		OutputFormat:     "json",
+		Defaults:         map[string]map[string]string{"knowledge ask": {"limit": "20"}},
+		CredentialHelper: "pass",
+		Exec:             &ExecProvider{Command: "sso-token"},
+		CurrentContext:   "prod",
+	}
+	data, err := ExportSettings(s)
+	if err != nil {
+		t.Fatalf("ExportSettings: %v", err)
+	}
+	if strings.Contains(string(data), "pass") || strings.Contains(string(data), "sso-token") || strings.Contains(string(data), "prod") {
+		t.Errorf("export leaks programs or contexts:\n%s", data)
+	}
+	got, err := ParseProjectSettings(data)
+	if err != nil {
+		t.Fatalf("ParseProjectSettings: %v", err)
+	}
+	if got.ServerURL != s.ServerURL || got.OutputFormat != s.OutputFormat || got.SchemaVersion != SettingsSchemaVersion ||
+		got.Defaults["knowledge ask"]["limit"] != "20" {
+		t.Errorf("round trip = %+v", got)
+	}
+}
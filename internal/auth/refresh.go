@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// RefreshLeeway is how close to expiry an OAuth token is renewed ahead of
+// time, so a request never goes out with a token that lapses in flight.
+const RefreshLeeway = 60 * time.Second
+
+// ErrNoRefreshToken is returned by Refresh when the stored session has no
+// refresh token to renew it with.
+var ErrNoRefreshToken = errors.New("no refresh token stored; run 'dot-ai auth login' to re-authenticate")
+
+// RefreshToken exchanges a refresh token for a new access token
+// (grant_type=refresh_token).
+func RefreshToken(meta *ServerMetadata, refreshToken, clientID, clientSecret string) (*tokenResponse, error) {
+	data := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	}
+	return requestToken(meta.TokenEndpoint, data, "token refresh")
+}
+
+// Refresh renews the OAuth session stored for the origin of serverURL in the
+// named context and returns the updated credentials. Sessions with a refresh
+// token use it; client-credentials sessions request a new token with the
+// client's credentials.
+//
+// Refreshes are serialised across processes with a lock beside
+// credentials.json: parallel invocations (hooks, agents) would otherwise all
+// spend the same refresh token, and servers that rotate refresh tokens reject
+// all but the first. After taking the lock the credentials are re-read; if
+// another process already stored a token that is neither rejected (the token
+// the server just refused, empty when refreshing ahead of expiry) nor about
+// to expire, that token is returned without another round-trip.
+func Refresh(serverURL, contextName, rejected string) (*Credentials, error) {
+	lock, err := lockCredentials()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	creds, err := LoadCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("loading credentials: %w", err)
+	}
+	cred := creds.For(contextName).Server(serverURL)
+	if cred == nil || cred.AccessToken == "" {
+		return nil, fmt.Errorf("no OAuth session stored for this server; run 'dot-ai auth login'")
+	}
+	if cred.AccessToken != rejected && !expiresWithin(cred.ExpiresAt, RefreshLeeway) {
+		return cred, nil
+	}
+	var tok *tokenResponse
+	switch {
+	case cred.GrantType == GrantClientCredentials:
+		tok, err = reacquireClientCredentials(serverURL, cred)
+	case cred.RefreshToken != "":
+		var meta *ServerMetadata
+		if meta, err = Discover(serverURL); err == nil {
+			tok, err = RefreshToken(meta, cred.RefreshToken, cred.ClientID, cred.ClientSecret)
+		}
+	default:
+		return nil, ErrNoRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	cred.setToken(tok)
+	if err := creds.Save(); err != nil {
+		return nil, fmt.Errorf("saving credentials: %w", err)
+	}
+	return cred, nil
+}
+
+// expiresWithin reports whether the RFC 3339 timestamp expiresAt falls
+// within d from now. An empty or unparseable value counts as expiring.
+func expiresWithin(expiresAt string, d time.Duration) bool {
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return true
+	}
+	return time.Until(t) < d
+}
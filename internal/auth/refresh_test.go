@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// saveTestSession saves an OAuth session for serverURL in a temp config dir.
+func saveTestSession(t *testing.T, serverURL string, cred Credentials) {
+	t.Helper()
+	dir := t.TempDir()
+	origFunc := configDirFunc
+	configDirFunc = func() string { return dir }
+	t.Cleanup(func() { configDirFunc = origFunc })
+
+	var c Credentials
+	entry, err := c.ForServer(serverURL)
+	if err != nil {
+		t.Fatalf("ForServer: %v", err)
+	}
+	*entry = cred
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func TestRefreshStoresRotatedTokens(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No metadata document: the CLI falls back to <server>/token.
+		if strings.HasPrefix(r.URL.Path, "/.well-known/") {
+			http.NotFound(w, r)
+			return
+		}
+		calls.Add(1)
+		r.ParseForm()
+		if r.URL.Path != "/token" || r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh-1" {
+			t.Errorf("unexpected token request %s %v", r.URL.Path, r.Form)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access-2",
+			"refresh_token": "refresh-2",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	}))
+	defer srv.Close()
+
+	saveTestSession(t, srv.URL, Credentials{
+		AccessToken:  "access-1",
+		RefreshToken: "refresh-1",
+		ExpiresAt:    time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+		ClientID:     "cid",
+	})
+
+	// Parallel refreshes of the same expired session must hit the token
+	// endpoint once: the rest find the renewed token after the lock.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cred, err := Refresh(srv.URL, "", "")
+			if err != nil {
+				t.Errorf("Refresh: %v", err)
+				return
+			}
+			if cred.AccessToken != "access-2" {
+				t.Errorf("AccessToken = %q, want access-2", cred.AccessToken)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1", n)
+	}
+
+	creds, err := LoadCredentials()
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	stored := creds.Server(srv.URL)
+	if stored.AccessToken != "access-2" || stored.RefreshToken != "refresh-2" || expiresWithin(stored.ExpiresAt, time.Minute) {
+		t.Errorf("stored = %+v, want the rotated session", stored)
+	}
+}
+
+func TestRefreshWithoutRefreshToken(t *testing.T) {
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{
+		AccessToken: "access-1",
+		ExpiresAt:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+	if _, err := Refresh("https://dot-ai.example.com", "", "access-1"); err != ErrNoRefreshToken {
+		t.Errorf("err = %v, want ErrNoRefreshToken", err)
+	}
+}
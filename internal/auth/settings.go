@@ -4,18 +4,118 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 )
 
 // configDirFunc can be overridden in tests.
 var configDirFunc = defaultConfigDir
 
-// Settings holds durable user preferences stored in settings.json.
+// SettingsSchemaVersion is the settings.json layout this version writes.
+// Files written by a newer version still load: keys this version does not
+// know are kept in Extra and written back unchanged.
+const SettingsSchemaVersion = 1
+
+// Settings holds durable user preferences stored in settings.json. The same
+// preference fields, minus contexts, make up a project file (.dot-ai.yaml).
+//
+// The preference fields at the top level form the flat (context-less) profile
+// that predates contexts. Once contexts exist, each named context is itself a
+// Settings value carrying its own preferences (its CurrentContext and
+// Contexts are unused), and Active selects the one in effect.
 type Settings struct {
-	ServerURL        string `json:"server_url,omitempty"`
-	OutputFormat     string `json:"output_format,omitempty"`
-	SkillsInclude    string `json:"skills_include,omitempty"`
-	SkillsExclude    string `json:"skills_exclude,omitempty"`
-	SkillsCustomOnly string `json:"skills_custom_only,omitempty"`
+	// SchemaVersion is the layout version of the file (top level only).
+	SchemaVersion int `json:"schema_version,omitempty" yaml:"schema_version,omitempty"`
+
+	ServerURL        string `json:"server_url,omitempty" yaml:"server_url,omitempty"`
+	OutputFormat     string `json:"output_format,omitempty" yaml:"output_format,omitempty"`
+	SkillsInclude    string `json:"skills_include,omitempty" yaml:"skills_include,omitempty"`
+	SkillsExclude    string `json:"skills_exclude,omitempty" yaml:"skills_exclude,omitempty"`
+	SkillsCustomOnly string `json:"skills_custom_only,omitempty" yaml:"skills_custom_only,omitempty"`
+
+	// Defaults holds default flag values per command, keyed by the command
+	// path below the root ("knowledge ask") and then by flag name.
+	Defaults map[string]map[string]string `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+
+	// CredentialHelper and Exec name programs that supply the token. They are
+	// never read from a project file, so a cloned repository cannot choose
+	// what runs.
+	CredentialHelper string        `json:"credential_helper,omitempty" yaml:"-"`
+	Exec             *ExecProvider `json:"exec,omitempty" yaml:"-"`
+
+	// SkillsRepoDirAllow is the colon-separated list of base directories
+	// 'skills generate --repo-dir' may read from (as DOT_AI_REPO_DIR_ALLOW).
+	// It bounds the world-writable check too, so it is never read from a
+	// project file either.
+	SkillsRepoDirAllow string `json:"skills_repo_dir_allow,omitempty" yaml:"-"`
+
+	// CurrentContext names the context used when neither --context nor
+	// DOT_AI_CONTEXT selects one.
+	CurrentContext string               `json:"current_context,omitempty" yaml:"-"`
+	Contexts       map[string]*Settings `json:"contexts,omitempty" yaml:"-"`
+
+	// Locked lists the keys locked by the system settings file (see
+	// LoadSystemSettings). It is set by LoadSettings and never saved.
+	Locked []string `json:"-" yaml:"-"`
+
+	// Extra holds the JSON keys this version does not know, so settings
+	// written by a newer version survive a save by an older one.
+	Extra map[string]json.RawMessage `json:"-" yaml:"-"`
+}
+
+// settingsJSON has the fields of Settings without its JSON methods.
+type settingsJSON Settings
+
+// settingsKeys are the JSON keys Settings knows.
+var settingsKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeFor[settingsJSON]()
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// UnmarshalJSON decodes the known fields and keeps the rest in Extra.
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*settingsJSON)(s)); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	s.Extra = nil
+	for key, value := range all {
+		if !settingsKeys[key] {
+			if s.Extra == nil {
+				s.Extra = map[string]json.RawMessage{}
+			}
+			s.Extra[key] = value
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the known fields followed by the preserved unknown
+// ones.
+func (s Settings) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(settingsJSON(s))
+	if err != nil || len(s.Extra) == 0 {
+		return data, err
+	}
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for key, value := range s.Extra {
+		if _, ok := all[key]; !ok {
+			all[key] = value
+		}
+	}
+	return json.Marshal(all)
 }
 
 func defaultConfigDir() string {
@@ -36,40 +136,47 @@ func SettingsPath() string {
 	return filepath.Join(ConfigDir(), "settings.json")
 }
 
-// LoadSettings reads settings from disk. Returns zero-value Settings if the
-// file does not exist.
+// LoadSettings returns the settings in effect: settings.json with the system
+// settings file beneath it (see Settings.underlay), so system values fill in
+// what the user leaves unset and locked keys always take the system value.
+// The result is for reading; changes to settings.json go through
+// UpdateSettings, which works on the user's file alone.
 func LoadSettings() (Settings, error) {
-	var s Settings
-	data, err := os.ReadFile(SettingsPath())
+	s, err := LoadUserSettings()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return s, nil
-		}
 		return s, err
 	}
-	if err := json.Unmarshal(data, &s); err != nil {
+	sys, err := LoadSystemSettings()
+	if err != nil {
 		return s, err
 	}
+	s.underlay(&sys)
 	return s, nil
 }
 
-// Save writes settings to disk with 0600 permissions, creating the config
-// directory if needed.
-func (s *Settings) Save() error {
-	dir := ConfigDir()
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return err
+// LoadUserSettings reads settings.json alone. Returns zero-value Settings if
+// the file does not exist. A corrupt file is restored from its backup (see
+// readConfigFile).
+func LoadUserSettings() (Settings, error) {
+	var s Settings
+	data, err := readConfigFile(SettingsPath())
+	if err != nil || data == nil {
+		return s, err
 	}
-	if err := os.Chmod(dir, 0700); err != nil {
-		return err
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, err
 	}
+	return s, nil
+}
+
+// Save atomically writes settings to disk with 0600 permissions, creating
+// the config directory if needed. Read-modify-write cycles go through
+// UpdateSettings so concurrent processes do not lose each other's changes.
+func (s *Settings) Save() error {
+	s.SchemaVersion = max(s.SchemaVersion, SettingsSchemaVersion)
 	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return err
 	}
-	path := SettingsPath()
-	if err := os.WriteFile(path, data, 0600); err != nil {
-		return err
-	}
-	return os.Chmod(path, 0600)
+	return writeConfigFile(SettingsPath(), data)
 }
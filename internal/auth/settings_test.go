@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -59,7 +60,9 @@ func TestLoadSettingsInvalidJSON(t *testing.T) {
 	configDirFunc = func() string { return dir }
 	defer func() { configDirFunc = origFunc }()
 
-	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte("{bad"), 0600); err != nil {
+	// Well-formed JSON with the wrong types is reported, not recovered from
+	// like a torn write (see TestLoadRecoversCorruptFile).
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(`{"server_url": 5}`), 0600); err != nil {
 		t.Fatal(err)
 	}
 	_, err := LoadSettings()
@@ -67,3 +70,58 @@ func TestLoadSettingsInvalidJSON(t *testing.T) {
 		t.Fatal("expected error for invalid JSON")
 	}
 }
+
+func TestSettingsPreserveUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	origFunc := configDirFunc
+	configDirFunc = func() string { return dir }
+	defer func() { configDirFunc = origFunc }()
+
+	// A file written by a newer version, with keys this one doesn't know at
+	// the top level and in a context.
+	newer := `{"schema_version": 3, "server_url": "https://a.example.com", "telemetry": {"enabled": false},
+		"contexts": {"prod": {"server_url": "https://prod.example.com", "theme": "dark"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(newer), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	s.OutputFormat = "json"
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var saved map[string]any
+	data, _ := os.ReadFile(filepath.Join(dir, "settings.json"))
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["schema_version"] != float64(3) || saved["output_format"] != "json" {
+		t.Errorf("saved = %v, want the newer schema version kept and the change applied", saved)
+	}
+	if _, ok := saved["telemetry"]; !ok {
+		t.Errorf("unknown top-level key dropped: %s", data)
+	}
+	prod, _ := saved["contexts"].(map[string]any)["prod"].(map[string]any)
+	if prod["theme"] != "dark" {
+		t.Errorf("unknown context key dropped: %s", data)
+	}
+}
+
+func TestSettingsSaveStampsSchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+	origFunc := configDirFunc
+	configDirFunc = func() string { return dir }
+	defer func() { configDirFunc = origFunc }()
+
+	s := Settings{ServerURL: "https://a.example.com"}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadSettings()
+	if err != nil || loaded.SchemaVersion != SettingsSchemaVersion || loaded.Extra != nil {
+		t.Errorf("LoadSettings = %+v, %v", loaded, err)
+	}
+}
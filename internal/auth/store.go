@@ -0,0 +1,434 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
+	"github.com/vfarcic/dot-ai-cli/internal/terminal"
+)
+
+// encryptedFormat marks a credentials.json holding an encrypted store.
+const encryptedFormat = "dot-ai-encrypted-credentials/v1"
+
+// Key sources of an encrypted store.
+const (
+	KeySourcePassphrase = "passphrase"
+	KeySourceKeyFile    = "key-file"
+)
+
+// minKeyFileSize is the least key material a key file must hold.
+const minKeyFileSize = 32
+
+// defaultUnlockTTL is how long an unlocked store stays unlocked for later
+// invocations when DOT_AI_UNLOCK_TTL is unset.
+const defaultUnlockTTL = 15 * time.Minute
+
+// pbkdf2Iterations is the work factor for new passphrase-protected stores;
+// tests lower it. The count used is recorded in each store.
+var pbkdf2Iterations = 600000
+
+// passphraseFunc prompts for the store passphrase; tests replace it.
+var passphraseFunc = terminal.ReadPassword
+
+// unlockCacheDirFunc returns the directory of the unlock cache; tests
+// replace it.
+var unlockCacheDirFunc = defaultUnlockCacheDir
+
+// ErrStoreLocked is returned when the credentials store is encrypted and no
+// passphrase or key is available without prompting.
+var ErrStoreLocked = errors.New("credentials store is locked")
+
+// encryptedStore is the on-disk form of an encrypted credentials.json: the
+// credentials JSON sealed with AES-256-GCM under a key derived from a
+// passphrase (PBKDF2-SHA256) or a key file (HKDF-SHA256).
+type encryptedStore struct {
+	Format     string `json:"format"`
+	KeySource  string `json:"key_source"`
+	KeyFile    string `json:"key_file,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// StoreOptions selects the format MigrateStore converts credentials.json to.
+type StoreOptions struct {
+	// Encrypt selects the encrypted store; false selects plain JSON.
+	Encrypt bool
+	// KeyFile derives the key from this file instead of a passphrase.
+	KeyFile string
+	// Passphrase protects the store when KeyFile is empty.
+	Passphrase string
+}
+
+// StoreInfo describes the format of credentials.json.
+type StoreInfo struct {
+	Encrypted bool
+	KeySource string
+	KeyFile   string
+}
+
+// storeKey is a derived key with the salt it was derived with.
+type storeKey struct {
+	salt []byte
+	key  []byte
+}
+
+// unlocked caches the key of the encrypted store for the rest of the
+// process, so it is derived (and prompted for) at most once per command.
+var (
+	unlockedMu sync.Mutex
+	unlocked   *storeKey
+)
+
+// parseEncryptedStore returns the encrypted store in data, or nil when data
+// is plain credentials JSON.
+func parseEncryptedStore(data []byte) *encryptedStore {
+	if !bytes.Contains(data, []byte(encryptedFormat)) {
+		return nil
+	}
+	var es encryptedStore
+	if err := json.Unmarshal(data, &es); err != nil || es.Format != encryptedFormat {
+		return nil
+	}
+	return &es
+}
+
+// open decrypts the store, unlocking it first when needed.
+func (es *encryptedStore) open() ([]byte, error) {
+	k, err := es.unlock()
+	if err != nil {
+		return nil, err
+	}
+	return es.decrypt(k.key)
+}
+
+// decrypt opens the ciphertext with key. A wrong key fails authentication.
+func (es *encryptedStore) decrypt(key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, es.Nonce, es.Ciphertext, []byte(encryptedFormat))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting credentials: wrong passphrase or key file")
+	}
+	return plain, nil
+}
+
+// seal encrypts data under key with a fresh nonce, keeping the store's key
+// parameters.
+func (es *encryptedStore) seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := *es
+	sealed.Format = encryptedFormat
+	sealed.Nonce = nonce
+	sealed.Ciphertext = gcm.Seal(nil, nonce, data, []byte(encryptedFormat))
+	return json.MarshalIndent(&sealed, "", "  ")
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// unlock returns the key of the store, trying in turn the key already
+// unlocked by this process, the key file, the unlock cache,
+// DOT_AI_CREDENTIALS_PASSPHRASE and finally a passphrase prompt. A
+// passphrase-derived key is written to the unlock cache.
+func (es *encryptedStore) unlock() (*storeKey, error) {
+	unlockedMu.Lock()
+	defer unlockedMu.Unlock()
+	if unlocked != nil && bytes.Equal(unlocked.salt, es.Salt) {
+		return unlocked, nil
+	}
+
+	var k *storeKey
+	switch es.KeySource {
+	case KeySourceKeyFile:
+		path := es.KeyFile
+		if v := os.Getenv("DOT_AI_CREDENTIALS_KEY_FILE"); v != "" {
+			path = v
+		}
+		key, err := keyFromFile(path, es.Salt)
+		if err != nil {
+			return nil, err
+		}
+		k = &storeKey{salt: es.Salt, key: key}
+	case KeySourcePassphrase:
+		if key := loadUnlockCache(es.Salt); key != nil {
+			if _, err := es.decrypt(key); err == nil {
+				unlocked = &storeKey{salt: es.Salt, key: key}
+				return unlocked, nil
+			}
+			clearUnlockCache()
+		}
+		passphrase := os.Getenv("DOT_AI_CREDENTIALS_PASSPHRASE")
+		if passphrase == "" {
+			var err error
+			passphrase, err = passphraseFunc("Passphrase for " + CredentialsPath() + ": ")
+			if err != nil {
+				return nil, fmt.Errorf("%w: set DOT_AI_CREDENTIALS_PASSPHRASE or run a command interactively to unlock it (%v)", ErrStoreLocked, err)
+			}
+		}
+		k = &storeKey{salt: es.Salt, key: keyFromPassphrase(passphrase, es.Salt, es.Iterations)}
+	default:
+		return nil, fmt.Errorf("unsupported credentials key source %q", es.KeySource)
+	}
+
+	if _, err := es.decrypt(k.key); err != nil {
+		return nil, err
+	}
+	if es.KeySource == KeySourcePassphrase {
+		saveUnlockCache(k)
+	}
+	unlocked = k
+	return k, nil
+}
+
+func keyFromPassphrase(passphrase string, salt []byte, iterations int) []byte {
+	key, _ := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	return key
+}
+
+func keyFromFile(path string, salt []byte) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: no key file configured", ErrStoreLocked)
+	}
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading credentials key file: %w", err)
+	}
+	if len(secret) < minKeyFileSize {
+		return nil, fmt.Errorf("credentials key file %s holds %d bytes, need at least %d", path, len(secret), minKeyFileSize)
+	}
+	return hkdf.Key(sha256.New, secret, salt, "dot-ai credentials", 32)
+}
+
+// CredentialsStore reports the format of credentials.json.
+func CredentialsStore() (StoreInfo, error) {
+	data, err := os.ReadFile(CredentialsPath())
+	if err != nil && !os.IsNotExist(err) {
+		return StoreInfo{}, err
+	}
+	es := parseEncryptedStore(data)
+	if es == nil {
+		return StoreInfo{}, nil
+	}
+	return StoreInfo{Encrypted: true, KeySource: es.KeySource, KeyFile: es.KeyFile}, nil
+}
+
+// MigrateStore rewrites credentials.json in the format selected by opts,
+// converting between plain JSON and the encrypted store (or re-keying an
+// encrypted one). The current contents are read first, so an encrypted
+// store must be unlockable.
+func MigrateStore(opts StoreOptions) error {
+	lock, err := lockCredentials()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	creds, err := LoadCredentials()
+	if err != nil {
+		return fmt.Errorf("loading credentials: %w", err)
+	}
+
+	unlockedMu.Lock()
+	unlocked = nil
+	unlockedMu.Unlock()
+	clearUnlockCache()
+
+	if !opts.Encrypt {
+		return creds.write(nil)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	es := &encryptedStore{Format: encryptedFormat, Salt: salt}
+	k := &storeKey{salt: salt}
+	if opts.KeyFile != "" {
+		path, err := filepath.Abs(opts.KeyFile)
+		if err != nil {
+			return err
+		}
+		es.KeySource, es.KeyFile = KeySourceKeyFile, path
+		if k.key, err = keyFromFile(path, salt); err != nil {
+			return err
+		}
+	} else {
+		if opts.Passphrase == "" {
+			return fmt.Errorf("a passphrase or key file is required to encrypt the credentials store")
+		}
+		es.KeySource, es.Iterations = KeySourcePassphrase, pbkdf2Iterations
+		k.key = keyFromPassphrase(opts.Passphrase, salt, es.Iterations)
+	}
+	if err := creds.write(&sealer{store: es, key: k.key}); err != nil {
+		return err
+	}
+
+	unlockedMu.Lock()
+	unlocked = k
+	unlockedMu.Unlock()
+	if es.KeySource == KeySourcePassphrase {
+		saveUnlockCache(k)
+	}
+	return nil
+}
+
+// LockStore forgets the unlocked key, so the next command needs the
+// passphrase again.
+func LockStore() error {
+	unlockedMu.Lock()
+	unlocked = nil
+	unlockedMu.Unlock()
+	return clearUnlockCache()
+}
+
+// sealer encrypts credentials on save.
+type sealer struct {
+	store *encryptedStore
+	key   []byte
+}
+
+// currentSealer returns the sealer for the store on disk, or nil when
+// credentials.json is plain JSON or does not exist.
+func currentSealer() (*sealer, error) {
+	data, err := os.ReadFile(CredentialsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	es := parseEncryptedStore(data)
+	if es == nil {
+		return nil, nil
+	}
+	k, err := es.unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{store: es, key: k.key}, nil
+}
+
+// unlockCache is the unlock cache file: the derived key, so repeated
+// invocations within the TTL are not prompted.
+type unlockCache struct {
+	Salt      []byte    `json:"salt"`
+	Key       []byte    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// defaultUnlockCacheDir is the per-user runtime directory, which lives in
+// memory on most systems and is emptied at logout. Without XDG_RUNTIME_DIR
+// the cache is disabled: a directory under the shared temporary directory
+// has a predictable name that another local user could create first.
+func defaultUnlockCacheDir() string {
+	return os.Getenv("XDG_RUNTIME_DIR")
+}
+
+// unlockCachePath is specific to the config directory, so separate
+// configurations do not share a key. It is empty when the cache directory
+// is unset or not private to the current user (see privateDir).
+func unlockCachePath() string {
+	dir := unlockCacheDirFunc()
+	if dir == "" || !privateDir(dir) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(ConfigDir()))
+	return filepath.Join(dir, "dot-ai-unlock-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// privateDir reports whether dir is a real directory (not a symlink) owned
+// by the current user and closed to everyone else.
+func privateDir(dir string) bool {
+	fi, err := os.Lstat(dir)
+	return err == nil && fi.IsDir() && fi.Mode().Perm()&0077 == 0 && ownedByUser(fi)
+}
+
+// unlockTTL reads DOT_AI_UNLOCK_TTL (a Go duration); 0 disables the cache.
+func unlockTTL() time.Duration {
+	if v := os.Getenv("DOT_AI_UNLOCK_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultUnlockTTL
+}
+
+// loadUnlockCache only trusts a regular file of the current user's, so a
+// symlink or a file planted by someone else is never read.
+func loadUnlockCache(salt []byte) []byte {
+	path := unlockCachePath()
+	if path == "" {
+		return nil
+	}
+	fi, err := os.Lstat(path)
+	if err != nil || !fi.Mode().IsRegular() || !ownedByUser(fi) {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var uc unlockCache
+	if err := json.Unmarshal(data, &uc); err != nil || !bytes.Equal(uc.Salt, salt) || time.Now().After(uc.ExpiresAt) {
+		return nil
+	}
+	return uc.Key
+}
+
+// saveUnlockCache is best effort: failing to cache only means prompting
+// again next time. The file is written through a fresh temporary file and
+// renamed into place, which replaces rather than follows anything already
+// at the path.
+func saveUnlockCache(k *storeKey) {
+	ttl := unlockTTL()
+	path := unlockCachePath()
+	if ttl == 0 || path == "" {
+		return
+	}
+	data, err := json.Marshal(unlockCache{Salt: k.salt, Key: k.key, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return
+	}
+	atomicfile.WriteFile(path, data, 0600)
+}
+
+func clearUnlockCache() error {
+	path := unlockCachePath()
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
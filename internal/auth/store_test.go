@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupStore points the config directory and unlock cache at temp
+// directories, lowers the PBKDF2 work factor and counts passphrase prompts.
+func setupStore(t *testing.T, passphrase string) *int {
+	t.Helper()
+	saveTestSession(t, "https://dot-ai.example.com", Credentials{AuthToken: "secret-token"})
+
+	cacheDir := t.TempDir()
+	if err := os.Chmod(cacheDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	origCache, origPrompt, origIter := unlockCacheDirFunc, passphraseFunc, pbkdf2Iterations
+	unlockCacheDirFunc = func() string { return cacheDir }
+	pbkdf2Iterations = 1000
+	prompts := 0
+	passphraseFunc = func(string) (string, error) {
+		prompts++
+		if passphrase == "" {
+			return "", errors.New("no terminal")
+		}
+		return passphrase, nil
+	}
+	t.Setenv("DOT_AI_CREDENTIALS_PASSPHRASE", "")
+	t.Setenv("DOT_AI_CREDENTIALS_KEY_FILE", "")
+	t.Setenv("DOT_AI_UNLOCK_TTL", "")
+	t.Cleanup(func() {
+		unlockCacheDirFunc, passphraseFunc, pbkdf2Iterations = origCache, origPrompt, origIter
+		LockStore()
+	})
+	return &prompts
+}
+
+// forgetKey drops the key unlocked by this process, as a new invocation
+// would start without it.
+func forgetKey() {
+	unlockedMu.Lock()
+	unlocked = nil
+	unlockedMu.Unlock()
+}
+
+func storedToken(t *testing.T) string {
+	t.Helper()
+	creds, err := LoadCredentials()
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	entry := creds.Server("https://dot-ai.example.com")
+	if entry == nil {
+		t.Fatal("no entry for the server")
+	}
+	return entry.AuthToken
+}
+
+func TestEncryptedStorePassphrase(t *testing.T) {
+	prompts := setupStore(t, "correct horse")
+
+	if err := MigrateStore(StoreOptions{Encrypt: true, Passphrase: "correct horse"}); err != nil {
+		t.Fatalf("MigrateStore: %v", err)
+	}
+	data, _ := os.ReadFile(CredentialsPath())
+	if bytes.Contains(data, []byte("secret-token")) {
+		t.Fatalf("credentials.json holds the token in clear:\n%s", data)
+	}
+	if info, _ := CredentialsStore(); !info.Encrypted || info.KeySource != KeySourcePassphrase {
+		t.Errorf("CredentialsStore = %+v", info)
+	}
+
+	// A new process is unlocked by the unlock cache without a prompt.
+	forgetKey()
+	if got := storedToken(t); got != "secret-token" {
+		t.Errorf("token = %q", got)
+	}
+	if *prompts != 0 {
+		t.Errorf("prompted %d times despite the unlock cache", *prompts)
+	}
+
+	// Saving keeps the store encrypted.
+	if err := updateServerEntry("https://dot-ai.example.com", "", func(c *Credentials) { c.AuthToken = "rotated-token" }); err != nil {
+		t.Fatalf("updateServerEntry: %v", err)
+	}
+	if info, _ := CredentialsStore(); !info.Encrypted {
+		t.Error("Save wrote plain JSON over the encrypted store")
+	}
+
+	// Once locked, the passphrase is prompted for once per process.
+	if err := LockStore(); err != nil {
+		t.Fatalf("LockStore: %v", err)
+	}
+	if got := storedToken(t); got != "rotated-token" {
+		t.Errorf("token = %q", got)
+	}
+	storedToken(t)
+	if *prompts != 1 {
+		t.Errorf("prompted %d times, want 1", *prompts)
+	}
+
+	// Back to plain JSON.
+	if err := MigrateStore(StoreOptions{}); err != nil {
+		t.Fatalf("MigrateStore: %v", err)
+	}
+	data, _ = os.ReadFile(CredentialsPath())
+	if !bytes.Contains(data, []byte("rotated-token")) {
+		t.Errorf("credentials.json is not plain JSON:\n%s", data)
+	}
+}
+
+func TestEncryptedStoreWrongPassphrase(t *testing.T) {
+	setupStore(t, "wrong")
+	if err := MigrateStore(StoreOptions{Encrypt: true, Passphrase: "right"}); err != nil {
+		t.Fatalf("MigrateStore: %v", err)
+	}
+	if err := LockStore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCredentials(); err == nil {
+		t.Fatal("LoadCredentials succeeded with the wrong passphrase")
+	}
+
+	t.Setenv("DOT_AI_CREDENTIALS_PASSPHRASE", "right")
+	if got := storedToken(t); got != "secret-token" {
+		t.Errorf("token = %q", got)
+	}
+}
+
+func TestEncryptedStoreLockedWithoutTerminal(t *testing.T) {
+	setupStore(t, "")
+	if err := MigrateStore(StoreOptions{Encrypt: true, Passphrase: "pass"}); err != nil {
+		t.Fatalf("MigrateStore: %v", err)
+	}
+	LockStore()
+	if _, err := LoadCredentials(); !errors.Is(err, ErrStoreLocked) {
+		t.Errorf("LoadCredentials = %v, want ErrStoreLocked", err)
+	}
+}
+
+func TestEncryptedStoreKeyFile(t *testing.T) {
+	prompts := setupStore(t, "")
+	keyFile := filepath.Join(t.TempDir(), "credentials.key")
+	if err := os.WriteFile(keyFile, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateStore(StoreOptions{Encrypt: true, KeyFile: keyFile}); err == nil {
+		t.Fatal("MigrateStore accepted a key file with too little key material")
+	}
+	if err := os.WriteFile(keyFile, bytes.Repeat([]byte{7}, 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateStore(StoreOptions{Encrypt: true, KeyFile: keyFile}); err != nil {
+		t.Fatalf("MigrateStore: %v", err)
+	}
+
+	forgetKey()
+	if got := storedToken(t); got != "secret-token" || *prompts != 0 {
+		t.Errorf("token = %q after %d prompts", got, *prompts)
+	}
+	if _, err := os.Stat(unlockCachePath()); !os.IsNotExist(err) {
+		t.Errorf("a key-file store wrote the unlock cache: %v", err)
+	}
+
+	// DOT_AI_CREDENTIALS_KEY_FILE points at a moved key file.
+	moved := filepath.Join(t.TempDir(), "moved.key")
+	if err := os.Rename(keyFile, moved); err != nil {
+		t.Fatal(err)
+	}
+	forgetKey()
+	if _, err := LoadCredentials(); err == nil {
+		t.Fatal("LoadCredentials succeeded without the key file")
+	}
+	t.Setenv("DOT_AI_CREDENTIALS_KEY_FILE", moved)
+	if got := storedToken(t); got != "secret-token" {
+		t.Errorf("token = %q", got)
+	}
+}
+
+func TestUnlockCacheRequiresPrivateDir(t *testing.T) {
+	prompts := setupStore(t, "correct horse")
+	if err := MigrateStore(StoreOptions{Encrypt: true, Passphrase: "correct horse"}); err != nil {
+		t.Fatalf("MigrateStore: %v", err)
+	}
+
+	// A cache directory others can read is not used.
+	shared := t.TempDir()
+	if err := os.Chmod(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	unlockCacheDirFunc = func() string { return shared }
+	LockStore()
+	storedToken(t)
+	forgetKey()
+	storedToken(t)
+	if *prompts != 2 {
+		t.Errorf("prompted %d times, want 2 with the cache disabled", *prompts)
+	}
+	if entries, _ := os.ReadDir(shared); len(entries) != 0 {
+		t.Errorf("unlock cache written to a shared directory: %v", entries)
+	}
+
+	// Nor is a symlink to a private one.
+	private := t.TempDir()
+	os.Chmod(private, 0700)
+	link := filepath.Join(t.TempDir(), "runtime")
+	if err := os.Symlink(private, link); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	unlockCacheDirFunc = func() string { return link }
+	if path := unlockCachePath(); path != "" {
+		t.Errorf("unlockCachePath = %q under a symlinked directory", path)
+	}
+
+	// Without XDG_RUNTIME_DIR there is no cache directory at all.
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if dir := defaultUnlockCacheDir(); dir != "" {
+		t.Errorf("defaultUnlockCacheDir = %q without XDG_RUNTIME_DIR", dir)
+	}
+}
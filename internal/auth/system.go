@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// systemSettingsPathFunc can be overridden in tests.
+var systemSettingsPathFunc = defaultSystemSettingsPath
+
+func defaultSystemSettingsPath() string {
+	return "/etc/dot-ai/settings.json"
+}
+
+// LockableKeys are the settings an administrator can lock in the system
+// settings file.
+var LockableKeys = []string{
+	"server_url", "output_format", "skills_include", "skills_exclude",
+	"skills_custom_only", "skills_repo_dir_allow", "credential_helper", "exec",
+}
+
+// profileFields maps the JSON key of each preference field of Settings (the
+// fields a profile carries) to its field index.
+var profileFields = func() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeFor[settingsJSON]()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		switch name {
+		case "", "-", "schema_version", "current_context", "contexts":
+			continue
+		}
+		fields[name] = i
+	}
+	return fields
+}()
+
+// SystemSettingsPath returns the path to the administrator-managed settings
+// file shared by every user of the machine.
+func SystemSettingsPath() string {
+	return systemSettingsPathFunc()
+}
+
+// LoadSystemSettings reads the system settings file: the preference fields
+// of a settings.json profile, plus "locked", the keys users cannot override.
+// Returns zero-value Settings if the file does not exist. Unlike the user's
+// files, the system file is never repaired or rewritten.
+func LoadSystemSettings() (Settings, error) {
+	var s Settings
+	path := SystemSettingsPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, fmt.Errorf("reading system settings: %w", err)
+	}
+	var locked struct {
+		Locked []string `json:"locked"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parsing system settings %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &locked); err != nil {
+		return s, fmt.Errorf("parsing system settings %s: %w", path, err)
+	}
+	delete(s.Extra, "locked")
+	for _, key := range locked.Locked {
+		if !slices.Contains(LockableKeys, key) {
+			fmt.Fprintf(warnings, "Warning: %s locks %q, which cannot be locked (lockable keys: %s)\n", path, key, strings.Join(LockableKeys, ", "))
+			continue
+		}
+		s.Locked = append(s.Locked, key)
+	}
+	s.CurrentContext, s.Contexts = "", nil
+	return s, nil
+}
+
+// IsLocked reports whether the system settings file locks key (a JSON key
+// such as "server_url").
+func (s *Settings) IsLocked(key string) bool {
+	return slices.Contains(s.Locked, key)
+}
+
+// underlay places the system settings beneath s: every profile (the flat one
+// and each context) takes the system value of each preference it leaves
+// unset, and the system value of each locked key whatever it sets.
+func (s *Settings) underlay(sys *Settings) {
+	profiles := []*Settings{s}
+	for _, name := range s.ContextNames() {
+		profiles = append(profiles, s.Contexts[name])
+	}
+	src := reflect.ValueOf(sys).Elem()
+	for _, p := range profiles {
+		dst := reflect.ValueOf(p).Elem()
+		for key, i := range profileFields {
+			if sys.IsLocked(key) || dst.Field(i).IsZero() {
+				dst.Field(i).Set(src.Field(i))
+			}
+		}
+	}
+	s.Locked = sys.Locked
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func writeSystemSettings(t *testing.T, content string) {
+	t.Helper()
+	SetConfigDirForTest(t.TempDir())
+	t.Cleanup(ResetConfigDir)
+	if err := os.WriteFile(SystemSettingsPath(), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSettingsUnderlaysSystemSettings(t *testing.T) {
+	writeSystemSettings(t, `{
+  "server_url": "https://managed.example.com",
+  "output_format": "json",
+  "skills_include": "query|remediate",
+  "skills_repo_dir_allow": "/opt/skills",
+  "locked": ["server_url", "skills_include", "skills_repo_dir_allow"]
+}`)
+	user := Settings{
+		CurrentContext: "dev",
+		Contexts: map[string]*Settings{
+			"dev": {ServerURL: "https://dev.example.com", SkillsInclude: ".*", SkillsRepoDirAllow: "/"},
+			"ci":  {OutputFormat: "yaml"},
+		},
+	}
+	if err := user.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if !s.IsLocked("server_url") || s.IsLocked("output_format") {
+		t.Errorf("Locked = %v", s.Locked)
+	}
+	dev, ci := s.Contexts["dev"], s.Contexts["ci"]
+	if dev.ServerURL != "https://managed.example.com" || dev.SkillsInclude != "query|remediate" || dev.SkillsRepoDirAllow != "/opt/skills" {
+		t.Errorf("locked keys not enforced: %+v", dev)
+	}
+	if dev.OutputFormat != "json" || ci.OutputFormat != "yaml" {
+		t.Errorf("unlocked system value should fill only unset keys: dev %q, ci %q", dev.OutputFormat, ci.OutputFormat)
+	}
+
+	// Updates work on the user's file alone.
+	if err := UpdateSettings(func(*Settings) error { return nil }); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	raw, err := LoadUserSettings()
+	if err != nil {
+		t.Fatalf("LoadUserSettings: %v", err)
+	}
+	if raw.Contexts["dev"].ServerURL != "https://dev.example.com" || raw.Contexts["ci"].ServerURL != "" {
+		t.Errorf("system values leaked into settings.json: %+v %+v", raw.Contexts["dev"], raw.Contexts["ci"])
+	}
+}
+
+func TestLoadSystemSettingsIgnoresUnlockableKeys(t *testing.T) {
+	writeSystemSettings(t, `{"locked": ["server_url", "current_context"]}`)
+	var warned bytes.Buffer
+	orig := warnings
+	warnings = &warned
+	defer func() { warnings = orig }()
+
+	s, err := LoadSystemSettings()
+	if err != nil {
+		t.Fatalf("LoadSystemSettings: %v", err)
+	}
+	if len(s.Locked) != 1 || s.Locked[0] != "server_url" || len(s.Extra) != 0 {
+		t.Errorf("LoadSystemSettings = %+v", s)
+	}
+	if !bytes.Contains(warned.Bytes(), []byte(`"current_context"`)) {
+		t.Errorf("no warning for the unlockable key: %q", warned.String())
+	}
+}
@@ -1,12 +1,18 @@
 package auth
 
-// SetConfigDirForTest overrides the config directory. Call ResetConfigDir to
-// restore the default. Intended for use in tests only.
+import "path/filepath"
+
+// SetConfigDirForTest overrides the config directory, and places the system
+// settings file in it so tests never read the machine's. Call ResetConfigDir
+// to restore the defaults. Intended for use in tests only.
 func SetConfigDirForTest(dir string) {
 	configDirFunc = func() string { return dir }
+	systemSettingsPathFunc = func() string { return filepath.Join(dir, "system-settings.json") }
 }
 
-// ResetConfigDir restores the default config directory function.
+// ResetConfigDir restores the default config directory and system settings
+// path.
 func ResetConfigDir() {
 	configDirFunc = defaultConfigDir
+	systemSettingsPathFunc = defaultSystemSettingsPath
 }
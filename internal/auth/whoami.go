@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Identity describes who a token authenticates, as far as the CLI can tell:
+// the claims of a JWT decoded locally, confirmed or completed by the server's
+// userinfo (OIDC) or token introspection (RFC 7662) endpoint when it
+// advertises one.
+type Identity struct {
+	Server    string     `json:"server"`
+	TokenType string     `json:"token_type"` // "jwt" or "opaque"
+	Subject   string     `json:"subject,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Groups    []string   `json:"groups,omitempty"`
+	Issuer    string     `json:"issuer,omitempty"`
+	Audience  []string   `json:"audience,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Scope     string     `json:"scope,omitempty"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+
+	// VerifiedBy names the server endpoint that confirmed the identity
+	// ("userinfo" or "introspection"); empty when only the local claims are
+	// known.
+	VerifiedBy string `json:"verified_by,omitempty"`
+	// Active is the server's verdict on the token, when it was asked.
+	Active *bool `json:"active,omitempty"`
+	// ServerError is why the server could not be asked; the local claims
+	// are still reported.
+	ServerError string `json:"server_error,omitempty"`
+}
+
+// Whoami describes the identity behind token for serverURL. JWT claims are
+// decoded without verifying the signature, for display only; the server's
+// userinfo endpoint, or else its introspection endpoint (authenticated with
+// the client stored for the session in the named context), confirms them.
+// Failing to reach the server is reported in ServerError, not as an error.
+func Whoami(serverURL, contextName, token string) (*Identity, error) {
+	origin, err := Origin(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{Server: origin, TokenType: "opaque"}
+	if claims, err := DecodeJWT(token); err == nil {
+		id.TokenType = "jwt"
+		id.apply(claims)
+	}
+
+	meta, err := Discover(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case meta.UserinfoEndpoint != "":
+		err = id.userinfo(meta.UserinfoEndpoint, token)
+	case meta.IntrospectionEndpoint != "":
+		var entry *Credentials
+		if entry, err = loadServerEntry(serverURL, contextName); err == nil {
+			err = id.introspect(meta.IntrospectionEndpoint, token, entry.ClientID, entry.ClientSecret)
+		}
+	}
+	if err != nil {
+		id.ServerError = err.Error()
+	}
+	if id.ExpiresAt != nil {
+		id.Expired = time.Now().After(*id.ExpiresAt)
+	}
+	return id, nil
+}
+
+// DecodeJWT returns the claims of a JWT without verifying its signature.
+func DecodeJWT(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("decoding JWT payload: %w", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("parsing JWT claims: %w", err)
+	}
+	return claims, nil
+}
+
+// userinfo asks the OIDC userinfo endpoint, which answers for valid tokens
+// only.
+func (id *Identity) userinfo(endpoint, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClientFunc(req)
+	if err != nil {
+		return fmt.Errorf("userinfo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading userinfo response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		id.setActive(false)
+		id.VerifiedBy = "userinfo"
+		return nil
+	default:
+		return fmt.Errorf("userinfo request failed (%d): %s", resp.StatusCode, string(body))
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return fmt.Errorf("parsing userinfo response: %w", err)
+	}
+	id.apply(claims)
+	id.setActive(true)
+	id.VerifiedBy = "userinfo"
+	return nil
+}
+
+// introspect asks the token introspection endpoint (RFC 7662).
+func (id *Identity) introspect(endpoint, token, clientID, clientSecret string) error {
+	data := url.Values{"token": {token}}
+	if clientID != "" {
+		data.Set("client_id", clientID)
+		data.Set("client_secret", clientSecret)
+	}
+	status, body, err := postForm(endpoint, data)
+	if err != nil {
+		return fmt.Errorf("token introspection failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return newOAuthError("token introspection", status, body)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return fmt.Errorf("parsing introspection response: %w", err)
+	}
+	active, _ := claims["active"].(bool)
+	if active {
+		id.apply(claims)
+	}
+	id.setActive(active)
+	id.VerifiedBy = "introspection"
+	return nil
+}
+
+func (id *Identity) setActive(active bool) {
+	id.Active = &active
+}
+
+// apply copies the identity claims shared by JWTs, userinfo responses and
+// introspection responses. Claims already known are overwritten, so the
+// server's answer wins over the local decoding.
+func (id *Identity) apply(claims map[string]any) {
+	setString := func(dst *string, names ...string) {
+		for _, name := range names {
+			if v, ok := claims[name].(string); ok && v != "" {
+				*dst = v
+				return
+			}
+		}
+	}
+	setString(&id.Subject, "sub")
+	setString(&id.Username, "preferred_username", "username")
+	setString(&id.Name, "name")
+	setString(&id.Email, "email")
+	setString(&id.Issuer, "iss")
+	setString(&id.ClientID, "client_id", "azp")
+	setString(&id.Scope, "scope")
+	if groups := stringList(claims["groups"]); groups != nil {
+		id.Groups = groups
+	}
+	if aud := stringList(claims["aud"]); aud != nil {
+		id.Audience = aud
+	}
+	if t := unixTime(claims["iat"]); t != nil {
+		id.IssuedAt = t
+	}
+	if t := unixTime(claims["exp"]); t != nil {
+		id.ExpiresAt = t
+	}
+}
+
+// stringList reads a claim that is a string or a list of strings.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// unixTime reads a NumericDate claim.
+func unixTime(v any) *time.Time {
+	secs, ok := v.(float64)
+	if !ok || secs <= 0 {
+		return nil
+	}
+	t := time.Unix(int64(secs), 0).UTC()
+	return &t
+}
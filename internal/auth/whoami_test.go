@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJWT builds an unsigned JWT carrying claims.
+func testJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc(payload) + ".sig"
+}
+
+// newMetadataServer advertises the given endpoints (paths on the server) and
+// serves them with handler.
+func newMetadataServer(t *testing.T, endpoints map[string]string, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			doc := map[string]string{"issuer": srv.URL, "token_endpoint": srv.URL + "/token"}
+			for name, path := range endpoints {
+				doc[name] = srv.URL + path
+			}
+			json.NewEncoder(w).Encode(doc)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWhoamiDecodesJWTAndAsksUserinfo(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	token := testJWT(t, map[string]any{
+		"sub":    "user-1",
+		"email":  "old@example.com",
+		"groups": []string{"devs"},
+		"iss":    "https://dex.example.com",
+		"aud":    "dot-ai",
+		"exp":    exp.Unix(),
+	})
+	srv := newMetadataServer(t, map[string]string{"userinfo_endpoint": "/userinfo"}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/userinfo" || r.Header.Get("Authorization") != "Bearer "+token {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewEncoder(w).Encode(map[string]any{"sub": "user-1", "email": "viktor@example.com", "groups": []string{"devs", "admins"}})
+	})
+	saveTestSession(t, srv.URL, Credentials{})
+
+	id, err := Whoami(srv.URL, "", token)
+	if err != nil {
+		t.Fatalf("Whoami: %v", err)
+	}
+	if id.TokenType != "jwt" || id.Subject != "user-1" || id.Issuer != "https://dex.example.com" || strings.Join(id.Audience, ",") != "dot-ai" {
+		t.Errorf("identity = %+v", id)
+	}
+	if id.Email != "viktor@example.com" || strings.Join(id.Groups, ",") != "devs,admins" {
+		t.Errorf("userinfo did not override the local claims: %+v", id)
+	}
+	if id.ExpiresAt == nil || !id.ExpiresAt.Equal(exp) || id.Expired {
+		t.Errorf("ExpiresAt = %v, Expired = %v; want %v", id.ExpiresAt, id.Expired, exp)
+	}
+	if id.Active == nil || !*id.Active || id.VerifiedBy != "userinfo" {
+		t.Errorf("server check = %v via %q", id.Active, id.VerifiedBy)
+	}
+}
+
+func TestWhoamiIntrospectsOpaqueToken(t *testing.T) {
+	active := true
+	srv := newMetadataServer(t, map[string]string{"introspection_endpoint": "/introspect"}, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("token") != "opaque-token" || r.Form.Get("client_id") != "cid" || r.Form.Get("client_secret") != "secret" {
+			t.Errorf("unexpected introspection request %v", r.Form)
+		}
+		if !active {
+			json.NewEncoder(w).Encode(map[string]any{"active": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"active": true, "sub": "svc", "username": "ci-bot", "exp": time.Now().Add(-time.Minute).Unix()})
+	})
+	saveTestSession(t, srv.URL, Credentials{AccessToken: "opaque-token", ClientID: "cid", ClientSecret: "secret"})
+
+	id, err := Whoami(srv.URL, "", "opaque-token")
+	if err != nil {
+		t.Fatalf("Whoami: %v", err)
+	}
+	if id.TokenType != "opaque" || id.Username != "ci-bot" || !id.Expired || id.VerifiedBy != "introspection" || id.Active == nil || !*id.Active {
+		t.Errorf("identity = %+v", id)
+	}
+
+	active = false
+	id, err = Whoami(srv.URL, "", "opaque-token")
+	if err != nil {
+		t.Fatalf("Whoami: %v", err)
+	}
+	if id.Active == nil || *id.Active || id.Subject != "" {
+		t.Errorf("identity = %+v, want an inactive token without claims", id)
+	}
+}
+
+func TestWhoamiWithoutServerEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	saveTestSession(t, srv.URL, Credentials{})
+
+	id, err := Whoami(srv.URL, "", testJWT(t, map[string]any{"sub": "user-1"}))
+	if err != nil {
+		t.Fatalf("Whoami: %v", err)
+	}
+	if id.Subject != "user-1" || id.Active != nil || id.ServerError != "" {
+		t.Errorf("identity = %+v, want local claims only", id)
+	}
+}
+
+func TestDecodeJWTRejectsMalformedTokens(t *testing.T) {
+	for _, token := range []string{"opaque", "a.b", "a.!!!.c", "a." + base64.RawURLEncoding.EncodeToString([]byte("not json")) + ".c"} {
+		if _, err := DecodeJWT(token); err == nil {
+			t.Errorf("DecodeJWT(%q) succeeded", token)
+		}
+	}
+}
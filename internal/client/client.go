@@ -6,11 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/vfarcic/dot-ai-cli/internal/auth"
 	"github.com/vfarcic/dot-ai-cli/internal/config"
 )
 
@@ -20,6 +25,9 @@ const (
 	ExitToolError  = 1
 	ExitConnError  = 2
 	ExitUsageError = 3
+	// ExitExpectationFailed signals that the request succeeded but an --expect
+	// or --fail-on-empty assertion on the response did not hold.
+	ExitExpectationFailed = 4
 )
 
 // RequestError wraps an HTTP error with an exit code for the CLI.
@@ -54,6 +62,47 @@ type Param struct {
 	ForceString bool
 }
 
+// Response is a response body together with the metadata callers need to
+// decide how to present it.
+type Response struct {
+	Body []byte
+	// ContentType is the lower-cased media type without parameters (e.g.
+	// "application/json"). Empty when the server sent no Content-Type.
+	ContentType string
+	// Filename is the base name suggested by a Content-Disposition header, or
+	// empty. It is reduced to a single path element so it can never escape
+	// the directory it is saved into.
+	Filename string
+}
+
+// IsJSON reports whether the body should be treated as JSON. A missing
+// Content-Type counts as JSON, which is what the server sends for every API
+// response.
+func (r *Response) IsJSON() bool {
+	ct := r.ContentType
+	return ct == "" || ct == "application/json" || strings.HasSuffix(ct, "+json")
+}
+
+// IsText reports whether the body is human-readable text that should be
+// printed untouched (text/*, YAML, XML).
+func (r *Response) IsText() bool {
+	ct := r.ContentType
+	switch {
+	case strings.HasPrefix(ct, "text/"):
+		return true
+	case ct == "application/yaml", ct == "application/x-yaml", ct == "application/xml":
+		return true
+	case strings.HasSuffix(ct, "+xml"), strings.HasSuffix(ct, "+yaml"):
+		return true
+	}
+	return false
+}
+
+// IsBinary reports whether the body is neither JSON nor text.
+func (r *Response) IsBinary() bool {
+	return !r.IsJSON() && !r.IsText()
+}
+
 // Do executes an HTTP request against the server.
 //
 // It handles path parameter substitution, query parameters, JSON body
@@ -67,6 +116,22 @@ func Do(cfg *config.Config, method, pathTemplate string, params []Param) ([]byte
 // X-Dot-AI-Git-Token credential on prompts-override requests. Header values
 // are never logged.
 func DoWithHeaders(cfg *config.Config, method, pathTemplate string, params []Param, headers map[string]string) ([]byte, error) {
+	resp, err := doParams(cfg, method, pathTemplate, params, headers)
+	if resp == nil {
+		return nil, err
+	}
+	return resp.Body, err
+}
+
+// DoResponse behaves like Do but returns the full Response, so callers can
+// handle non-JSON bodies (plain text, binary downloads) by content type.
+func DoResponse(cfg *config.Config, method, pathTemplate string, params []Param) (*Response, error) {
+	return doParams(cfg, method, pathTemplate, params, nil)
+}
+
+// doParams builds and sends a request from resolved params. On an HTTP error
+// status the Response is returned alongside the classified error.
+func doParams(cfg *config.Config, method, pathTemplate string, params []Param, headers map[string]string) (*Response, error) {
 	resolvedPath := pathTemplate
 	queryParams := url.Values{}
 	bodyFields := map[string]json.RawMessage{}
@@ -107,86 +172,68 @@ func DoWithHeaders(cfg *config.Config, method, pathTemplate string, params []Par
 		fullURL += "?" + queryParams.Encode()
 	}
 
-	var bodyReader io.Reader
+	var bodyBytes []byte
 	if len(bodyFields) > 0 {
-		bodyBytes, err := json.Marshal(bodyFields)
+		var err error
+		bodyBytes, err = json.Marshal(bodyFields)
 		if err != nil {
 			return nil, &RequestError{
 				Message:  fmt.Sprintf("failed to build request body: %v", err),
 				ExitCode: ExitUsageError,
 			}
 		}
-		bodyReader = bytes.NewReader(bodyBytes)
 	} else if method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch {
-		bodyReader = bytes.NewReader([]byte("{}"))
+		bodyBytes = []byte("{}")
 	}
 
-	ctx := context.Background()
-
-	req, err := http.NewRequestWithContext(ctx, method, fullURL, bodyReader)
+	resp, body, err := send(cfg, method, fullURL, bodyBytes, headers)
 	if err != nil {
-		return nil, &RequestError{
-			Message:  fmt.Sprintf("failed to create request: %v", err),
-			ExitCode: ExitToolError,
-		}
+		return nil, err
 	}
 
-	if bodyReader != nil {
-		req.Header.Set("Content-Type", "application/json")
+	result := &Response{
+		Body:        body,
+		ContentType: mediaType(resp.Header.Get("Content-Type")),
+		Filename:    dispositionFilename(resp.Header.Get("Content-Disposition")),
 	}
-	if cfg.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.Token)
-	}
-	for k, v := range headers {
-		if v != "" {
-			req.Header.Set(k, v)
-		}
+	if resp.StatusCode >= 400 {
+		return result, explainAuthFailure(cfg, classifyHTTPError(resp.StatusCode, body))
 	}
 
-	httpClient := &http.Client{
-		// net/http strips Authorization/Cookie on a cross-host redirect but
-		// leaves caller-supplied headers intact, so X-Dot-AI-Git-Token (the
-		// per-request git credential) would otherwise be re-sent to whatever
-		// host the configured server redirects to. Drop every caller-supplied
-		// header when the redirect target host differs from the original, so a
-		// credential is never sent to a host the caller did not target.
-		// Same-host redirects keep the headers, preserving normal behavior.
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return fmt.Errorf("stopped after 10 redirects")
-			}
-			if len(via) > 0 && req.URL.Host != via[0].URL.Host {
-				for k := range headers {
-					req.Header.Del(k)
-				}
-			}
-			return nil
-		},
-	}
+	return result, nil
+}
 
-	resp, err := httpClient.Do(req)
+// mediaType returns the lower-cased media type of a Content-Type header
+// without its parameters. Unparseable values fall back to the text before the
+// first ';'.
+func mediaType(header string) string {
+	if header == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(header)
 	if err != nil {
-		return nil, &RequestError{
-			Message: fmt.Sprintf("Error: cannot connect to server at %s.\n"+
-				"Set the server URL with --server-url or DOT_AI_URL.", cfg.ServerURL),
-			ExitCode: ExitConnError,
-		}
+		mt, _, _ = strings.Cut(header, ";")
 	}
-	defer resp.Body.Close()
+	return strings.ToLower(strings.TrimSpace(mt))
+}
 
-	body, err := io.ReadAll(resp.Body)
+// dispositionFilename extracts the filename parameter of a
+// Content-Disposition header (RFC 6266, including the RFC 5987 filename*
+// form) and reduces it to a safe base name. Returns "" when absent or unsafe.
+func dispositionFilename(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
 	if err != nil {
-		return nil, &RequestError{
-			Message:  fmt.Sprintf("Error: failed to read response: %v", err),
-			ExitCode: ExitToolError,
-		}
+		return ""
 	}
-
-	if resp.StatusCode >= 400 {
-		return body, classifyHTTPError(resp.StatusCode, body)
+	name := strings.ReplaceAll(params["filename"], "\\", "/")
+	name = path.Base(name)
+	if name == "." || name == ".." || name == "/" {
+		return ""
 	}
-
-	return body, nil
+	return name
 }
 
 // DoJSON sends method to path with the given pre-marshaled JSON body and extra
@@ -199,15 +246,68 @@ func DoWithHeaders(cfg *config.Config, method, pathTemplate string, params []Par
 // target on a different host.
 func DoJSON(cfg *config.Config, method, path string, body []byte, headers map[string]string) ([]byte, error) {
 	fullURL := strings.TrimRight(cfg.ServerURL, "/") + path
+	if body == nil {
+		body = []byte{}
+	}
+
+	resp, respBody, err := send(cfg, method, fullURL, body, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		return respBody, explainAuthFailure(cfg, classifyHTTPError(resp.StatusCode, respBody))
+	}
+	return respBody, nil
+}
+
+// send issues a request with the bearer token and the extra headers, and
+// reads the whole response. A non-nil body is sent as JSON.
+//
+// OAuth tokens are kept fresh transparently: a token about to expire is
+// refreshed before the request, and a 401 on a refreshable OAuth token
+// triggers one refresh and retry. A failed refresh is reported on stderr and
+// the request proceeds as it would have without it.
+func send(cfg *config.Config, method, fullURL string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	RefreshIfExpiring(cfg)
+
+	resp, respBody, err := sendOnce(cfg, method, fullURL, body, headers)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized &&
+		cfg.TokenSource == config.TokenSourceOAuth && cfg.TokenRefreshable {
+		if refreshToken(cfg, cfg.Token) {
+			return sendOnce(cfg, method, fullURL, body, headers)
+		}
+	}
+	return resp, respBody, err
+}
 
-	req, err := http.NewRequestWithContext(context.Background(), method, fullURL, bytes.NewReader(body))
+// RefreshIfExpiring refreshes cfg's OAuth token in place when it expires
+// within auth.RefreshLeeway and can be renewed, as send does before every
+// request. A failed refresh is reported on stderr.
+func RefreshIfExpiring(cfg *config.Config) {
+	if cfg.TokenSource == config.TokenSourceOAuth && cfg.TokenRefreshable &&
+		!cfg.TokenExpiresAt.IsZero() && time.Until(cfg.TokenExpiresAt) < auth.RefreshLeeway {
+		refreshToken(cfg, "")
+	}
+}
+
+// sendOnce performs a single attempt of send.
+func sendOnce(cfg *config.Config, method, fullURL string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), method, fullURL, bodyReader)
 	if err != nil {
-		return nil, &RequestError{
+		return nil, nil, &RequestError{
 			Message:  fmt.Sprintf("failed to create request: %v", err),
 			ExitCode: ExitToolError,
 		}
 	}
-	req.Header.Set("Content-Type", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	if cfg.Token != "" {
 		req.Header.Set("Authorization", "Bearer "+cfg.Token)
 	}
@@ -218,6 +318,13 @@ func DoJSON(cfg *config.Config, method, path string, body []byte, headers map[st
 	}
 
 	httpClient := &http.Client{
+		// net/http strips Authorization/Cookie on a cross-host redirect but
+		// leaves caller-supplied headers intact, so X-Dot-AI-Git-Token (the
+		// per-request git credential) would otherwise be re-sent to whatever
+		// host the configured server redirects to. Drop every caller-supplied
+		// header when the redirect target host differs from the original, so a
+		// credential is never sent to a host the caller did not target.
+		// Same-host redirects keep the headers, preserving normal behavior.
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 10 {
 				return fmt.Errorf("stopped after 10 redirects")
@@ -233,7 +340,7 @@ func DoJSON(cfg *config.Config, method, path string, body []byte, headers map[st
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, &RequestError{
+		return nil, nil, &RequestError{
 			Message: fmt.Sprintf("Error: cannot connect to server at %s.\n"+
 				"Set the server URL with --server-url or DOT_AI_URL.", cfg.ServerURL),
 			ExitCode: ExitConnError,
@@ -243,16 +350,61 @@ func DoJSON(cfg *config.Config, method, path string, body []byte, headers map[st
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, &RequestError{
+		return nil, nil, &RequestError{
 			Message:  fmt.Sprintf("Error: failed to read response: %v", err),
 			ExitCode: ExitToolError,
 		}
 	}
+	return resp, respBody, nil
+}
 
-	if resp.StatusCode >= 400 {
-		return respBody, classifyHTTPError(resp.StatusCode, respBody)
+// refreshFunc can be overridden in tests.
+var refreshFunc = auth.Refresh
+
+// warnings receives non-fatal warnings; overridden in tests.
+var warnings io.Writer = os.Stderr
+
+// refreshToken renews cfg's OAuth token in place. rejected is the token the
+// server just refused (empty when refreshing ahead of expiry). On failure it
+// warns on stderr, stops further refresh attempts for this invocation and,
+// if the token has expired, drops it. It reports whether a new token is in
+// place.
+func refreshToken(cfg *config.Config, rejected string) bool {
+	cred, err := refreshFunc(cfg.ServerURL, cfg.Context, rejected)
+	if err != nil {
+		fmt.Fprintf(warnings, "Warning: could not refresh the OAuth token: %v\n", err)
+		cfg.TokenRefreshable = false
+		if time.Now().After(cfg.TokenExpiresAt) {
+			cfg.Token = ""
+			cfg.TokenSource = config.TokenSourceNone
+		}
+		return false
 	}
-	return respBody, nil
+	if cred.AccessToken == rejected {
+		return false
+	}
+	cfg.Token = cred.AccessToken
+	cfg.TokenExpiresAt, _ = time.Parse(time.RFC3339, cred.ExpiresAt)
+	return true
+}
+
+// explainAuthFailure replaces the generic 401 message when the request went
+// out without a token because no stored credential matches the server's
+// origin, naming the origins that do have credentials.
+func explainAuthFailure(cfg *config.Config, err *RequestError) *RequestError {
+	if err.Status != 401 || cfg.Token != "" {
+		return err
+	}
+	origin, oerr := auth.Origin(cfg.ServerURL)
+	if oerr != nil {
+		return err
+	}
+	msg := fmt.Sprintf("no credentials stored for %s. Run 'dot-ai auth login' against this server, use --token flag, or set DOT_AI_AUTH_TOKEN env. var.", origin)
+	if len(cfg.CredentialOrigins) > 0 {
+		msg += fmt.Sprintf("\nCredentials exist for: %s (stored tokens are only sent to the server they were issued for).", strings.Join(cfg.CredentialOrigins, ", "))
+	}
+	err.Message = msg
+	return err
 }
 
 // classifyHTTPError maps HTTP status codes to user-friendly errors.
@@ -1,6 +1,16 @@
 package client
 
-import "testing"
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vfarcic/dot-ai-cli/internal/auth"
+	"github.com/vfarcic/dot-ai-cli/internal/config"
+)
 
 // TestRedactCredentials covers the credential-scrubbing regex used to keep an
 // embedded git credential from leaking into a server-supplied message. The
@@ -42,3 +52,196 @@ func TestRedactCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestDoResponseExposesContentMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/PNG; charset=binary")
+		w.Header().Set("Content-Disposition", `attachment; filename="../../etc/diagram.png"`)
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	}))
+	defer srv.Close()
+
+	resp, err := DoResponse(&config.Config{ServerURL: srv.URL}, http.MethodGet, "/api/v1/diagram", nil)
+	if err != nil {
+		t.Fatalf("DoResponse: %v", err)
+	}
+	if resp.ContentType != "image/png" {
+		t.Errorf("ContentType = %q, want %q", resp.ContentType, "image/png")
+	}
+	if resp.Filename != "diagram.png" {
+		t.Errorf("Filename = %q, want %q (path components must be stripped)", resp.Filename, "diagram.png")
+	}
+	if !resp.IsBinary() {
+		t.Error("image/png should be classified as binary")
+	}
+}
+
+func TestResponseClassification(t *testing.T) {
+	cases := []struct {
+		contentType string
+		json, text  bool
+	}{
+		{"", true, false},
+		{"application/json", true, false},
+		{"application/problem+json", true, false},
+		{"text/plain", false, true},
+		{"text/markdown", false, true},
+		{"application/yaml", false, true},
+		{"image/svg+xml", false, true},
+		{"application/pdf", false, false},
+		{"application/octet-stream", false, false},
+	}
+	for _, tc := range cases {
+		r := &Response{ContentType: tc.contentType}
+		if r.IsJSON() != tc.json || r.IsText() != tc.text || r.IsBinary() != (!tc.json && !tc.text) {
+			t.Errorf("%q: IsJSON=%v IsText=%v IsBinary=%v", tc.contentType, r.IsJSON(), r.IsText(), r.IsBinary())
+		}
+	}
+}
+
+func TestDispositionFilename(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"inline", ""},
+		{`attachment; filename="report.txt"`, "report.txt"},
+		{`attachment; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`, "résumé.pdf"},
+		{`attachment; filename="..\\..\\evil.exe"`, "evil.exe"},
+		{`attachment; filename=".."`, ""},
+	}
+	for _, tc := range cases {
+		if got := dispositionFilename(tc.header); got != tc.want {
+			t.Errorf("dispositionFilename(%q) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestUnauthorizedWithoutStoredCredentialNamesOrigin(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{ServerURL: srv.URL + "/", CredentialOrigins: []string{"https://prod.example.com"}}
+	_, err := Do(cfg, http.MethodGet, "/api/v1/version", nil)
+	reqErr, ok := err.(*RequestError)
+	if !ok {
+		t.Fatalf("err = %v, want *RequestError", err)
+	}
+	for _, want := range []string{"no credentials stored for " + srv.URL, "https://prod.example.com"} {
+		if !strings.Contains(reqErr.Message, want) {
+			t.Errorf("Message = %q, want it to contain %q", reqErr.Message, want)
+		}
+	}
+
+	// With a token the generic message stays.
+	cfg.Token = "rejected"
+	_, err = Do(cfg, http.MethodGet, "/api/v1/version", nil)
+	if reqErr := err.(*RequestError); strings.Contains(reqErr.Message, "no credentials stored") {
+		t.Errorf("Message = %q, want the generic 401 message when a token was sent", reqErr.Message)
+	}
+}
+
+func TestOAuthTokenRefreshedAfter401(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	origRefresh := refreshFunc
+	defer func() { refreshFunc = origRefresh }()
+	var rejected []string
+	refreshFunc = func(serverURL, contextName, rejectedToken string) (*auth.Credentials, error) {
+		rejected = append(rejected, rejectedToken)
+		return &auth.Credentials{AccessToken: "fresh", ExpiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}, nil
+	}
+
+	cfg := &config.Config{
+		ServerURL:        srv.URL,
+		Token:            "stale",
+		TokenSource:      config.TokenSourceOAuth,
+		TokenExpiresAt:   time.Now().Add(time.Hour),
+		TokenRefreshable: true,
+	}
+	body, err := Do(cfg, http.MethodPost, "/api/v1/tools/query", []Param{{Name: "intent", Value: "x", Location: "body"}})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if string(body) != `{"ok":true}` {
+		t.Errorf("body = %s", body)
+	}
+	if len(rejected) != 1 || rejected[0] != "stale" {
+		t.Errorf("refresh calls = %v, want one for the rejected token", rejected)
+	}
+	if cfg.Token != "fresh" {
+		t.Errorf("cfg.Token = %q, want the refreshed token", cfg.Token)
+	}
+}
+
+func TestOAuthRefreshFailureWarns(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	origRefresh, origWarnings := refreshFunc, warnings
+	defer func() { refreshFunc, warnings = origRefresh, origWarnings }()
+	refreshFunc = func(string, string, string) (*auth.Credentials, error) {
+		return nil, errors.New("token refresh failed (400): invalid_grant")
+	}
+	var warned strings.Builder
+	warnings = &warned
+
+	// Expired token: refreshed ahead of the request; on failure the request
+	// goes out without it.
+	cfg := &config.Config{
+		ServerURL:        srv.URL,
+		Token:            "expired",
+		TokenSource:      config.TokenSourceOAuth,
+		TokenExpiresAt:   time.Now().Add(-time.Minute),
+		TokenRefreshable: true,
+	}
+	if _, err := Do(cfg, http.MethodGet, "/api/v1/version", nil); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if !strings.Contains(warned.String(), "could not refresh the OAuth token") || !strings.Contains(warned.String(), "invalid_grant") {
+		t.Errorf("warning = %q", warned.String())
+	}
+	if cfg.Token != "" || cfg.TokenRefreshable {
+		t.Errorf("cfg after failed refresh = %+v, want the expired token dropped", cfg)
+	}
+}
+
+func TestRefreshIfExpiring(t *testing.T) {
+	origRefresh := refreshFunc
+	defer func() { refreshFunc = origRefresh }()
+	calls := 0
+	renewed := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	refreshFunc = func(string, string, string) (*auth.Credentials, error) {
+		calls++
+		return &auth.Credentials{AccessToken: "fresh", ExpiresAt: renewed.Format(time.RFC3339)}, nil
+	}
+
+	// A token far from expiry and a static token are left alone.
+	for _, cfg := range []*config.Config{
+		{Token: "valid", TokenSource: config.TokenSourceOAuth, TokenExpiresAt: time.Now().Add(time.Hour), TokenRefreshable: true},
+		{Token: "static", TokenSource: config.TokenSourceStatic},
+	} {
+		RefreshIfExpiring(cfg)
+	}
+	if calls != 0 {
+		t.Fatalf("refreshed %d times, want 0", calls)
+	}
+
+	cfg := &config.Config{Token: "expiring", TokenSource: config.TokenSourceOAuth, TokenExpiresAt: time.Now().Add(time.Second), TokenRefreshable: true}
+	RefreshIfExpiring(cfg)
+	if calls != 1 || cfg.Token != "fresh" || !cfg.TokenExpiresAt.Equal(renewed) {
+		t.Errorf("cfg after refresh = %+v (%d calls)", cfg, calls)
+	}
+}
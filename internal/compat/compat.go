@@ -0,0 +1,294 @@
+// Package compat finds the version of the dot-ai server the CLI talks to and
+// compares it with the version of the API the CLI was generated from.
+package compat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vfarcic/dot-ai-cli/internal/atomicfile"
+	"github.com/vfarcic/dot-ai-cli/internal/auth"
+	"github.com/vfarcic/dot-ai-cli/internal/client"
+	"github.com/vfarcic/dot-ai-cli/internal/config"
+)
+
+// VersionPath is the server endpoint reporting its version.
+const VersionPath = "/api/v1/version"
+
+// Version is a semantic version; pre-release and build suffixes are dropped.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses "1.2.3", "v1.2" or "1.2.3-rc.1". Missing minor and
+// patch numbers are zero.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	core, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(s), "v"), "+")
+	core, _, _ = strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if core == "" || len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 as v is older than, equal to or newer than w.
+func (v Version) Compare(w Version) int {
+	for _, d := range []int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch} {
+		if d != 0 {
+			if d < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ServerVersion asks the server for its version.
+func ServerVersion(cfg *config.Config) (string, error) {
+	body, err := client.Do(cfg, "GET", VersionPath, nil)
+	if err != nil {
+		return "", err
+	}
+	return parseVersionResponse(body)
+}
+
+// parseVersionResponse extracts the server version from a version response,
+// with or without the {"success", "data"} envelope: server.version, or the
+// top-level version.
+func parseVersionResponse(body []byte) (string, error) {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return "", fmt.Errorf("failed to parse version response: %w", err)
+	}
+	if len(envelope.Data) > 0 {
+		body = envelope.Data
+	}
+	var resp struct {
+		Version string `json:"version"`
+		Server  struct {
+			Version string `json:"version"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse version response: %w", err)
+	}
+	if resp.Server.Version != "" {
+		return resp.Server.Version, nil
+	}
+	if resp.Version != "" {
+		return resp.Version, nil
+	}
+	return "", errors.New("the server's version response names no version")
+}
+
+// SupportedRange, when stamped at build time with
+// -ldflags "-X github.com/vfarcic/dot-ai-cli/internal/compat.SupportedRange=>=1.2.0 <1.4.0",
+// replaces the range derived from the embedded spec's version.
+var SupportedRange string
+
+// Range is the span of server versions a CLI build supports: Min included,
+// Max excluded.
+type Range struct {
+	Min, Max Version
+}
+
+// ParseRange parses ">=1.2.0 <1.4.0".
+func ParseRange(s string) (Range, error) {
+	var r Range
+	fields := strings.Fields(s)
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], ">=") || !strings.HasPrefix(fields[1], "<") {
+		return r, fmt.Errorf("invalid version range %q: want \">=MIN <MAX\"", s)
+	}
+	var err error
+	if r.Min, err = ParseVersion(fields[0][2:]); err != nil {
+		return r, err
+	}
+	if r.Max, err = ParseVersion(fields[1][1:]); err != nil {
+		return r, err
+	}
+	if r.Min.Compare(r.Max) >= 0 {
+		return r, fmt.Errorf("invalid version range %q: empty", s)
+	}
+	return r, nil
+}
+
+// RangeFor returns the server versions supported by a CLI generated from the
+// spec at specVersion: its major.minor release line, any patch. A stamped
+// SupportedRange takes precedence.
+func RangeFor(specVersion string) (Range, error) {
+	if SupportedRange != "" {
+		return ParseRange(SupportedRange)
+	}
+	v, err := ParseVersion(specVersion)
+	if err != nil {
+		return Range{}, fmt.Errorf("this build records no usable API version: %w", err)
+	}
+	return Range{Min: Version{v.Major, v.Minor, 0}, Max: Version{v.Major, v.Minor + 1, 0}}, nil
+}
+
+// Contains reports whether v is within r.
+func (r Range) Contains(v Version) bool {
+	return v.Compare(r.Min) >= 0 && v.Compare(r.Max) < 0
+}
+
+func (r Range) String() string {
+	return ">=" + r.Min.String() + " <" + r.Max.String()
+}
+
+// releasesURL is where CLI releases are published.
+const releasesURL = "https://github.com/vfarcic/dot-ai-cli/releases"
+
+// MismatchError reports a server version outside the supported range.
+type MismatchError struct {
+	Server    Version
+	Supported Range
+}
+
+func (e *MismatchError) Error() string {
+	if e.Server.Compare(e.Supported.Min) < 0 {
+		return fmt.Sprintf("server version %s is older than this CLI supports (%s): upgrade the server, or install a CLI release built for %d.%d from %s",
+			e.Server, e.Supported, e.Server.Major, e.Server.Minor, releasesURL)
+	}
+	return fmt.Sprintf("server version %s is newer than this CLI supports (%s): upgrade the CLI ('brew upgrade dot-ai', or %s)",
+		e.Server, e.Supported, releasesURL)
+}
+
+// Check compares the server's version, cached by CachedServerVersion, with
+// the range supported by a CLI generated from the spec at specVersion. It
+// returns a *MismatchError when the server is outside the range, and other
+// errors when the version could not be determined. A server that predates
+// the version endpoint is not checked.
+func Check(cfg *config.Config, specVersion string) error {
+	supported, err := RangeFor(specVersion)
+	if err != nil {
+		return err
+	}
+	raw, err := CachedServerVersion(cfg)
+	if err != nil {
+		return fmt.Errorf("could not determine the server version: %w", err)
+	}
+	if raw == "" {
+		return nil
+	}
+	server, err := ParseVersion(raw)
+	if err != nil {
+		return fmt.Errorf("the server reports an unparseable version: %w", err)
+	}
+	if !supported.Contains(server) {
+		return &MismatchError{Server: server, Supported: supported}
+	}
+	return nil
+}
+
+// DefaultCacheTTL is how long a server's version is reused before it is asked
+// again. DOT_AI_VERSION_CACHE_TTL overrides it; 0 disables the cache.
+const DefaultCacheTTL = time.Hour
+
+// unknownCacheTTL is how long a server without the version endpoint is
+// remembered as such: commands don't each ask it again, and an upgraded
+// server is noticed within minutes.
+const unknownCacheTTL = 5 * time.Minute
+
+// cachedVersion is one server's entry in the version cache. An empty Version
+// records a server without the version endpoint.
+type cachedVersion struct {
+	Version   string    `json:"version"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// current reports whether the entry is still within ttl, or unknownCacheTTL
+// for a server without a version.
+func (e cachedVersion) current(ttl time.Duration) bool {
+	if e.Version == "" {
+		ttl = min(ttl, unknownCacheTTL)
+	}
+	return time.Since(e.CheckedAt) < ttl
+}
+
+// cachePath returns <cache>/dot-ai-cli/server-versions.json, under
+// $XDG_CACHE_HOME when set, like the skills caches.
+func cachePath() (string, error) {
+	root := strings.TrimSpace(os.Getenv("XDG_CACHE_HOME"))
+	if root == "" {
+		var err error
+		if root, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(root, "dot-ai-cli", "server-versions.json"), nil
+}
+
+func cacheTTL() time.Duration {
+	if v := os.Getenv("DOT_AI_VERSION_CACHE_TTL"); v != "" {
+		if ttl, err := time.ParseDuration(v); err == nil {
+			return ttl
+		}
+	}
+	return DefaultCacheTTL
+}
+
+// CachedServerVersion returns the server's version, from the per-server cache
+// when it was asked within the cache TTL. The cache is best-effort: a cache
+// that cannot be read or written only costs a request.
+//
+// A server that answers 404, predating the version endpoint, has no version:
+// "" is returned, and cached for unknownCacheTTL.
+func CachedServerVersion(cfg *config.Config) (string, error) {
+	ttl := cacheTTL()
+	origin, err := auth.Origin(cfg.ServerURL)
+	if err != nil {
+		return "", err
+	}
+	path, pathErr := cachePath()
+	cache := map[string]cachedVersion{}
+	if pathErr == nil && ttl > 0 {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &cache)
+		}
+		if entry, ok := cache[origin]; ok && entry.current(ttl) {
+			return entry.Version, nil
+		}
+	}
+
+	version, err := ServerVersion(cfg)
+	var reqErr *client.RequestError
+	if errors.As(err, &reqErr) && reqErr.Status == http.StatusNotFound {
+		version, err = "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if pathErr == nil && ttl > 0 {
+		cache[origin] = cachedVersion{Version: version, CheckedAt: time.Now()}
+		if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+			if os.MkdirAll(filepath.Dir(path), 0700) == nil {
+				atomicfile.WriteFile(path, data, 0600)
+			}
+		}
+	}
+	return version, nil
+}
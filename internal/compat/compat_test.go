@@ -0,0 +1,154 @@
+package compat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vfarcic/dot-ai-cli/internal/config"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Version
+		ok   bool
+	}{
+		{"1.2.3", Version{1, 2, 3}, true},
+		{"v2.0", Version{2, 0, 0}, true},
+		{"1.4.0-rc.1+build.5", Version{1, 4, 0}, true},
+		{"", Version{}, false},
+		{"1.x", Version{}, false},
+		{"1.2.3.4", Version{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("ParseVersion(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+	if (Version{1, 2, 3}).Compare(Version{1, 10, 0}) != -1 || (Version{2, 0, 0}).Compare(Version{1, 9, 9}) != 1 {
+		t.Error("Compare orders versions wrongly")
+	}
+}
+
+func TestParseVersionResponse(t *testing.T) {
+	tests := map[string]string{
+		`{"version": "1.2.1", "server": {"version": "1.3.0", "healthy": true}}`: "1.3.0",
+		`{"success": true, "data": {"version": "1.2.1"}}`:                       "1.2.1",
+	}
+	for body, want := range tests {
+		got, err := parseVersionResponse([]byte(body))
+		if err != nil || got != want {
+			t.Errorf("parseVersionResponse(%s) = %q, %v, want %q", body, got, err, want)
+		}
+	}
+	if _, err := parseVersionResponse([]byte(`{"success": true, "data": {}}`)); err == nil {
+		t.Error("a response without a version parsed")
+	}
+}
+
+func TestRangeFor(t *testing.T) {
+	r, err := RangeFor("1.2.1")
+	if err != nil || r.String() != ">=1.2.0 <1.3.0" {
+		t.Fatalf("RangeFor = %v, %v", r, err)
+	}
+	if !r.Contains(Version{1, 2, 9}) || r.Contains(Version{1, 3, 0}) || r.Contains(Version{1, 1, 9}) {
+		t.Errorf("%v contains the wrong versions", r)
+	}
+
+	SupportedRange = ">=1.0.0 <2.0.0"
+	defer func() { SupportedRange = "" }()
+	if r, err := RangeFor("1.2.1"); err != nil || !r.Contains(Version{1, 9, 0}) {
+		t.Errorf("stamped range ignored: %v, %v", r, err)
+	}
+	if _, err := ParseRange(">=2.0.0 <1.0.0"); err == nil {
+		t.Error("ParseRange accepted an empty range")
+	}
+}
+
+func TestCheckCachesServerVersion(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("DOT_AI_VERSION_CACHE_TTL", "")
+	requests := 0
+	version := "1.2.4"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"success": true, "data": {"server": {"version": %q}}}`, version)
+	}))
+	defer srv.Close()
+	cfg := &config.Config{ServerURL: srv.URL}
+
+	if err := Check(cfg, "1.2.0"); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	// The cached version is used until the TTL passes.
+	version = "1.3.0"
+	if err := Check(cfg, "1.2.0"); err != nil || requests != 1 {
+		t.Fatalf("Check = %v after %d requests, want the cached version", err, requests)
+	}
+
+	t.Setenv("DOT_AI_VERSION_CACHE_TTL", "0")
+	var mismatch *MismatchError
+	if err := Check(cfg, "1.2.0"); !errors.As(err, &mismatch) || mismatch.Server != (Version{1, 3, 0}) {
+		t.Fatalf("Check = %v, want a mismatch for 1.3.0", err)
+	}
+	if !strings.Contains(mismatch.Error(), "newer than this CLI supports (>=1.2.0 <1.3.0)") {
+		t.Errorf("message = %q", mismatch.Error())
+	}
+}
+
+func TestCheckCachesMissingVersionEndpoint(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("DOT_AI_VERSION_CACHE_TTL", "")
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	cfg := &config.Config{ServerURL: srv.URL}
+
+	// A server that predates the endpoint is not checked, and not asked
+	// again while the entry is current.
+	for range 2 {
+		if err := Check(cfg, "1.2.0"); err != nil {
+			t.Fatalf("Check = %v, want no error for a server without a version", err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("%d requests, want the missing version cached", requests)
+	}
+
+	// The entry expires after unknownCacheTTL, well before DefaultCacheTTL.
+	path, err := cachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := map[string]cachedVersion{}
+	if err := json.Unmarshal(data, &cache); err != nil || len(cache) != 1 {
+		t.Fatalf("cache = %s (%v), want one entry", data, err)
+	}
+	for origin, entry := range cache {
+		entry.CheckedAt = time.Now().Add(-2 * unknownCacheTTL)
+		cache[origin] = entry
+	}
+	data, _ = json.Marshal(cache)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(cfg, "1.2.0"); err != nil || requests != 2 {
+		t.Errorf("Check = %v after %d requests, want the server asked again", err, requests)
+	}
+}
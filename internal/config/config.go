@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -15,17 +16,66 @@ const (
 	TokenSourceNone   = ""
 	TokenSourceStatic = "static"
 	TokenSourceOAuth  = "oauth"
+	TokenSourceExec   = "exec"
+	TokenSourceHelper = "helper"
+
+	// Sources of a resolved setting, for ServerURLSource and
+	// OutputFormatSource. SourceSystem is a value locked by the system
+	// settings file; SourceSettings covers settings.json and the unlocked
+	// system values beneath it.
+	SourceFlag     = "flag"
+	SourceEnv      = "env"
+	SourceProject  = "project"
+	SourceSettings = "settings"
+	SourceSystem   = "system"
+	SourceDefault  = "default"
 )
 
 type Config struct {
-	ServerURL    string
-	Token        string
-	TokenSource  string
-	OutputFormat string
+	// Context is the named server context in effect (--context >
+	// DOT_AI_CONTEXT > settings.json current_context). Empty when no
+	// contexts are configured.
+	Context   string
+	ServerURL string
+	// ServerURLSource is where ServerURL came from (SourceFlag, ...).
+	ServerURLSource string
+	Token           string
+	TokenSource     string
+	OutputFormat    string
+	// OutputFormatSource is where OutputFormat came from: SourceDefault
+	// when nothing chose a format.
+	OutputFormatSource string
+	// CredentialHelper and Exec are the credential helper and exec provider
+	// configured for the active profile, if any.
+	CredentialHelper string
+	Exec             *auth.ExecProvider
+	// TokenExpiresAt is the expiry of a stored OAuth token or an exec
+	// provider token (zero otherwise).
+	TokenExpiresAt time.Time
+	// TokenRefreshable reports whether a refresh token is stored for the
+	// OAuth token, so the client can renew it before expiry or after a 401.
+	TokenRefreshable bool
+	// ProjectFile is the project-local settings file (.dot-ai.yaml) in
+	// effect, or empty when none was found.
+	ProjectFile string
+	// CredentialOrigins lists the server origins the active profile holds
+	// stored credentials for, so a missing credential can be reported
+	// clearly.
+	CredentialOrigins []string
+	// NoColor disables the interactive-terminal presentation (colours,
+	// markdown rendering, pager). Set by --no-color or a non-empty NO_COLOR.
+	NoColor bool
 }
 
 // Resolve applies configuration precedence:
-// flags > env vars > settings.json/credentials.json > defaults.
+// flags > env vars > .dot-ai.yaml > settings.json/credentials.json > system
+// settings > defaults. Keys locked by the system settings file take its
+// value whatever the other tiers set.
+//
+// The project file is discovered by walking up from the working directory to
+// the git root. When contexts are configured, the user-file tier is the
+// active context's settings and credentials rather than the flat top-level
+// ones.
 //
 // Flag values are already set on the struct by cobra. If a flag was not
 // provided (empty string), we fall back to env, then file, then default.
@@ -35,51 +85,226 @@ func (c *Config) Resolve() error {
 		return fmt.Errorf("loading settings: %w", err)
 	}
 	creds, err := auth.LoadCredentials()
-	if err != nil {
+	locked := errors.Is(err, auth.ErrStoreLocked)
+	if locked {
+		// An encrypted store that cannot be unlocked without a prompt
+		// leaves stored credentials out; tokens from flags, the
+		// environment and helpers still work.
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if err != nil {
 		return fmt.Errorf("loading credentials: %w", err)
 	}
 
-	// Server URL: flag > env > settings.json > default
-	if c.ServerURL == "" {
-		if v := os.Getenv("DOT_AI_URL"); v != "" {
-			c.ServerURL = v
-		} else if settings.ServerURL != "" {
-			c.ServerURL = settings.ServerURL
-		} else {
-			c.ServerURL = DefaultServerURL
+	// Credentials written before origin binding are bound once to the
+	// server stored with them (or the default). DOT_AI_URL is deliberately
+	// not consulted: a one-off or scripted value would capture the token
+	// for good.
+	if !locked && auth.BindOrigins(&settings, &creds, DefaultServerURL) {
+		err := auth.UpdateCredentials(func(stored *auth.Credentials) error {
+			auth.BindOrigins(&settings, stored, DefaultServerURL)
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	// Token: flag > env > credentials.json auth_token > credentials.json access_token (if valid) > none
+	// Context: flag > env > settings.json current_context. The selected
+	// context's preferences and credentials stand in for the flat ones below.
+	if c.Context == "" {
+		c.Context = os.Getenv("DOT_AI_CONTEXT")
+	}
+	c.Context = settings.ActiveName(c.Context)
+	profile, err := settings.Active(c.Context)
+	if err != nil {
+		return err
+	}
+	cred := creds.For(c.Context)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("determining working directory: %w", err)
+	}
+	project, projectFile, err := auth.LoadProjectSettings(wd)
+	if err != nil {
+		return fmt.Errorf("loading project settings: %w", err)
+	}
+	c.ProjectFile = projectFile
+
+	// Server URL: flag > env > .dot-ai.yaml > settings.json > system
+	// settings > default, or only the system settings when locked there.
+	if settings.IsLocked("server_url") {
+		c.ServerURL = lockedValue("server_url", "--server-url", c.ServerURL, "DOT_AI_URL", profile.ServerURL, DefaultServerURL)
+		c.ServerURLSource = SourceSystem
+	} else if c.ServerURL != "" {
+		c.ServerURLSource = SourceFlag
+	} else if v := os.Getenv("DOT_AI_URL"); v != "" {
+		c.ServerURL, c.ServerURLSource = v, SourceEnv
+	} else if project.ServerURL != "" {
+		c.ServerURL, c.ServerURLSource = project.ServerURL, SourceProject
+	} else if profile.ServerURL != "" {
+		c.ServerURL, c.ServerURLSource = profile.ServerURL, SourceSettings
+	} else {
+		c.ServerURL, c.ServerURLSource = DefaultServerURL, SourceDefault
+	}
+
+	// Token: flag > env > exec provider > credential helper >
+	// credentials.json auth_token > credentials.json access_token (if valid
+	// or refreshable) > none
+	//
+	// Stored tokens are only used when they were issued for the origin of
+	// the resolved server URL, so pointing --server-url or DOT_AI_URL at
+	// another host never leaks them.
+	c.CredentialOrigins = cred.Origins()
+	c.CredentialHelper = profile.CredentialHelper
+	c.Exec = profile.Exec
 	if c.Token != "" {
 		// Token was set by --token flag.
 		c.TokenSource = TokenSourceStatic
 	} else {
+		stored := cred.Server(c.ServerURL)
+		if stored == nil {
+			stored = &auth.Credentials{}
+		}
 		if v := os.Getenv("DOT_AI_AUTH_TOKEN"); v != "" {
 			c.Token = v
 			c.TokenSource = TokenSourceStatic
-		} else if creds.AuthToken != "" {
-			c.Token = creds.AuthToken
+		} else if c.tokenFromPrograms(profile.ServerURL) {
+			// Set by the exec provider or the credential helper.
+		} else if stored.AuthToken != "" {
+			c.Token = stored.AuthToken
 			c.TokenSource = TokenSourceStatic
-		} else if creds.AccessToken != "" && !isExpired(creds.ExpiresAt) {
-			c.Token = creds.AccessToken
+		} else if stored.AccessToken != "" && (stored.Renewable() || !isExpired(stored.ExpiresAt)) {
+			// An expired token that can be renewed is kept: the client
+			// refreshes it before the first request.
+			c.Token = stored.AccessToken
 			c.TokenSource = TokenSourceOAuth
+			c.TokenExpiresAt, _ = time.Parse(time.RFC3339, stored.ExpiresAt)
+			c.TokenRefreshable = stored.Renewable()
+			if stored.GrantType == auth.GrantClientCredentials {
+				c.reacquireClientToken()
+			}
 		}
 	}
 
-	// Output format: flag > env > settings.json > default
-	if c.OutputFormat == "" {
-		if v := os.Getenv("DOT_AI_OUTPUT_FORMAT"); v != "" {
-			c.OutputFormat = v
-		} else if settings.OutputFormat != "" {
-			c.OutputFormat = settings.OutputFormat
-		} else {
-			c.OutputFormat = DefaultOutputFormat
+	// Output format: as the server URL.
+	if settings.IsLocked("output_format") {
+		c.OutputFormat = lockedValue("output_format", "--output", c.OutputFormat, "DOT_AI_OUTPUT_FORMAT", profile.OutputFormat, DefaultOutputFormat)
+		c.OutputFormatSource = SourceSystem
+		if profile.OutputFormat == "" {
+			c.OutputFormatSource = SourceDefault
 		}
+	} else if c.OutputFormat != "" {
+		c.OutputFormatSource = SourceFlag
+	} else if v := os.Getenv("DOT_AI_OUTPUT_FORMAT"); v != "" {
+		c.OutputFormat, c.OutputFormatSource = v, SourceEnv
+	} else if project.OutputFormat != "" {
+		c.OutputFormat, c.OutputFormatSource = project.OutputFormat, SourceProject
+	} else if profile.OutputFormat != "" {
+		c.OutputFormat, c.OutputFormatSource = profile.OutputFormat, SourceSettings
+	} else {
+		c.OutputFormat, c.OutputFormatSource = DefaultOutputFormat, SourceDefault
+	}
+
+	// No color: flag > NO_COLOR (any non-empty value, per no-color.org)
+	if !c.NoColor && os.Getenv("NO_COLOR") != "" {
+		c.NoColor = true
 	}
 	return nil
 }
 
+// lockedValue returns the value of a key locked by the system settings file
+// (value, or def when the file locks it unset), warning when the flag or
+// environment variable tries to override it.
+func lockedValue(key, flag, flagValue, env, value, def string) string {
+	if value == "" {
+		value = def
+	}
+	for _, override := range []struct{ name, value string }{{flag, flagValue}, {env, os.Getenv(env)}} {
+		if override.value != "" && override.value != value {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %s is locked to %q by %s\n", override.name, key, value, auth.SystemSettingsPath())
+		}
+	}
+	return value
+}
+
+// tokenFromPrograms asks the exec provider, then the credential helper, for a
+// token. A failing program is reported as a warning and the stored
+// credentials are tried next, so a broken helper does not lock the user out
+// of commands that need no token.
+//
+// The exec provider mints tokens for the server configured alongside it
+// (profileURL, or the default server), so it is skipped when --server-url,
+// DOT_AI_URL or a project file points at another origin. The credential
+// helper is keyed by origin already.
+func (c *Config) tokenFromPrograms(profileURL string) bool {
+	if c.Exec != nil && !sameOrigin(c.ServerURL, profileURL) {
+		if profileURL == "" {
+			profileURL = DefaultServerURL
+		}
+		fmt.Fprintf(os.Stderr, "Warning: not running the exec provider: it is configured for %s, not %s\n", profileURL, c.ServerURL)
+	} else if c.Exec != nil {
+		token, expiresAt, err := auth.ExecToken(c.Exec, c.ServerURL, c.Context)
+		if err == nil {
+			c.Token = token
+			c.TokenSource = TokenSourceExec
+			c.TokenExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+			return true
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if c.CredentialHelper != "" {
+		token, err := auth.HelperGet(c.CredentialHelper, c.ServerURL)
+		if err == nil {
+			c.Token = token
+			c.TokenSource = TokenSourceHelper
+			return true
+		}
+		if !errors.Is(err, auth.ErrCredentialsNotFound) {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether serverURL has the origin of profileURL, or of
+// the default server when profileURL is empty.
+func sameOrigin(serverURL, profileURL string) bool {
+	if profileURL == "" {
+		profileURL = DefaultServerURL
+	}
+	a, err := auth.Origin(serverURL)
+	if err != nil {
+		return false
+	}
+	b, err := auth.Origin(profileURL)
+	return err == nil && a == b
+}
+
+// reacquireClientToken replaces an expired (or nearly expired)
+// client-credentials token before any command runs, so pipelines only ever
+// cache short-lived tokens. When the client's credentials are no longer
+// available the token is dropped with a warning.
+func (c *Config) reacquireClientToken() {
+	if !c.TokenExpiresAt.IsZero() && time.Until(c.TokenExpiresAt) > auth.RefreshLeeway {
+		return
+	}
+	renewed, err := refreshFunc(c.ServerURL, c.Context, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not re-acquire the client-credentials token: %v\n", err)
+		c.Token = ""
+		c.TokenSource = TokenSourceNone
+		c.TokenExpiresAt = time.Time{}
+		c.TokenRefreshable = false
+		return
+	}
+	c.Token = renewed.AccessToken
+	c.TokenExpiresAt, _ = time.Parse(time.RFC3339, renewed.ExpiresAt)
+}
+
+// refreshFunc renews stored sessions; tests replace it.
+var refreshFunc = auth.Refresh
+
 // isExpired checks whether the given RFC 3339 timestamp is in the past.
 // Returns true (expired) if the value is empty or unparseable, so that
 // callers skip unusable tokens.
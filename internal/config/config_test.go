@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/vfarcic/dot-ai-cli/internal/auth"
 )
@@ -27,8 +31,8 @@ func TestResolveDefaults(t *testing.T) {
 		t.Fatalf("Resolve: %v", err)
 	}
 
-	if c.ServerURL != DefaultServerURL {
-		t.Errorf("ServerURL = %q, want %q", c.ServerURL, DefaultServerURL)
+	if c.ServerURL != DefaultServerURL || c.ServerURLSource != SourceDefault {
+		t.Errorf("ServerURL = %q from %q, want %q from %q", c.ServerURL, c.ServerURLSource, DefaultServerURL, SourceDefault)
 	}
 	if c.Token != "" {
 		t.Errorf("Token = %q, want empty", c.Token)
@@ -36,8 +40,8 @@ func TestResolveDefaults(t *testing.T) {
 	if c.TokenSource != TokenSourceNone {
 		t.Errorf("TokenSource = %q, want %q", c.TokenSource, TokenSourceNone)
 	}
-	if c.OutputFormat != DefaultOutputFormat {
-		t.Errorf("OutputFormat = %q, want %q", c.OutputFormat, DefaultOutputFormat)
+	if c.OutputFormat != DefaultOutputFormat || c.OutputFormatSource != SourceDefault {
+		t.Errorf("OutputFormat = %q from %q, want %q from %q", c.OutputFormat, c.OutputFormatSource, DefaultOutputFormat, SourceDefault)
 	}
 }
 
@@ -72,8 +76,8 @@ func TestResolveFromFiles(t *testing.T) {
 	if c.TokenSource != TokenSourceStatic {
 		t.Errorf("TokenSource = %q, want %q", c.TokenSource, TokenSourceStatic)
 	}
-	if c.OutputFormat != "json" {
-		t.Errorf("OutputFormat = %q, want %q", c.OutputFormat, "json")
+	if c.OutputFormat != "json" || c.OutputFormatSource != SourceSettings {
+		t.Errorf("OutputFormat = %q from %q, want %q from %q", c.OutputFormat, c.OutputFormatSource, "json", SourceSettings)
 	}
 }
 
@@ -109,8 +113,8 @@ func TestResolveEnvOverridesFiles(t *testing.T) {
 	if c.TokenSource != TokenSourceStatic {
 		t.Errorf("TokenSource = %q, want %q", c.TokenSource, TokenSourceStatic)
 	}
-	if c.OutputFormat != "json" {
-		t.Errorf("OutputFormat = %q, want %q", c.OutputFormat, "json")
+	if c.OutputFormat != "json" || c.OutputFormatSource != SourceEnv {
+		t.Errorf("OutputFormat = %q from %q, want %q from %q", c.OutputFormat, c.OutputFormatSource, "json", SourceEnv)
 	}
 }
 
@@ -140,8 +144,8 @@ func TestResolveFlagsOverrideAll(t *testing.T) {
 	if c.TokenSource != TokenSourceStatic {
 		t.Errorf("TokenSource = %q, want %q", c.TokenSource, TokenSourceStatic)
 	}
-	if c.OutputFormat != "yaml" {
-		t.Errorf("OutputFormat = %q, want %q", c.OutputFormat, "yaml")
+	if c.OutputFormat != "yaml" || c.OutputFormatSource != SourceFlag {
+		t.Errorf("OutputFormat = %q from %q, want %q from %q", c.OutputFormat, c.OutputFormatSource, "yaml", SourceFlag)
 	}
 }
 
@@ -201,6 +205,82 @@ func TestResolveExpiredOAuthTokenSkipped(t *testing.T) {
 	}
 }
 
+func TestResolveExpiredOAuthTokenKeptWhenRefreshable(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_URL", "DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT"} {
+		t.Setenv(key, "")
+	}
+
+	cr := auth.Credentials{
+		AccessToken:  "expired-token",
+		RefreshToken: "refresh-token",
+		ExpiresAt:    "2020-01-01T00:00:00Z",
+	}
+	if err := cr.Save(); err != nil {
+		t.Fatalf("Save credentials: %v", err)
+	}
+
+	c := Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	if c.Token != "expired-token" || c.TokenSource != TokenSourceOAuth || !c.TokenRefreshable {
+		t.Errorf("Resolve = %+v, want the expired token kept for refresh", c)
+	}
+	if want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC); !c.TokenExpiresAt.Equal(want) {
+		t.Errorf("TokenExpiresAt = %v, want %v", c.TokenExpiresAt, want)
+	}
+}
+
+func TestResolveReacquiresExpiredClientCredentialsToken(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_URL", "DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT"} {
+		t.Setenv(key, "")
+	}
+
+	cr := auth.Credentials{
+		AccessToken: "expired-cc-token",
+		ExpiresAt:   "2020-01-01T00:00:00Z",
+		ClientID:    "ci-bot",
+		GrantType:   auth.GrantClientCredentials,
+	}
+	if err := cr.Save(); err != nil {
+		t.Fatalf("Save credentials: %v", err)
+	}
+
+	renewed := time.Now().Add(10 * time.Minute).UTC().Truncate(time.Second)
+	origRefresh := refreshFunc
+	t.Cleanup(func() { refreshFunc = origRefresh })
+	refreshFunc = func(serverURL, contextName, rejected string) (*auth.Credentials, error) {
+		return &auth.Credentials{AccessToken: "fresh-cc-token", ExpiresAt: renewed.Format(time.RFC3339)}, nil
+	}
+
+	c := Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "fresh-cc-token" || c.TokenSource != TokenSourceOAuth || !c.TokenExpiresAt.Equal(renewed) {
+		t.Errorf("Resolve = %+v, want the re-acquired token", c)
+	}
+
+	// Without the client's credentials the expired token is dropped.
+	refreshFunc = func(serverURL, contextName, rejected string) (*auth.Credentials, error) {
+		return nil, errors.New("client credentials not found")
+	}
+	c = Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "" || c.TokenSource != TokenSourceNone || c.TokenRefreshable {
+		t.Errorf("Resolve = %+v, want no token", c)
+	}
+}
+
 func TestResolveAuthTokenTakesPriorityOverOAuth(t *testing.T) {
 	dir := t.TempDir()
 	setConfigDir(t, dir)
@@ -231,6 +311,289 @@ func TestResolveAuthTokenTakesPriorityOverOAuth(t *testing.T) {
 	}
 }
 
+func TestResolveTokenPrograms(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_URL", "DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT"} {
+		t.Setenv(key, "")
+	}
+
+	bin := t.TempDir()
+	helper := "#!/bin/sh\ncat >/dev/null\necho '{\"ServerURL\":\"x\",\"Username\":\"dot-ai\",\"Secret\":\"helper-token\"}'\n"
+	if err := os.WriteFile(filepath.Join(bin, auth.CredentialHelperPrefix+"test"), []byte(helper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	s := auth.Settings{
+		ServerURL:        "https://file.example.com",
+		CredentialHelper: "test",
+		Exec:             &auth.ExecProvider{Command: "sh", Args: []string{"-c", `echo '{"status":{"token":"exec-token"}}'`}},
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save settings: %v", err)
+	}
+	cr := auth.Credentials{AuthToken: "file-token"}
+	if err := cr.Save(); err != nil {
+		t.Fatalf("Save credentials: %v", err)
+	}
+
+	c := Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "exec-token" || c.TokenSource != TokenSourceExec {
+		t.Errorf("Token = %q (%s), want the exec provider's", c.Token, c.TokenSource)
+	}
+
+	// A failing exec provider falls through to the credential helper.
+	s.Exec = &auth.ExecProvider{Command: "false"}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save settings: %v", err)
+	}
+	c = Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "helper-token" || c.TokenSource != TokenSourceHelper {
+		t.Errorf("Token = %q (%s), want the credential helper's", c.Token, c.TokenSource)
+	}
+
+	// The exec provider only mints tokens for its own server: pointed
+	// elsewhere, the CLI does not run it.
+	s.Exec = &auth.ExecProvider{Command: "sh", Args: []string{"-c", `echo '{"status":{"token":"exec-token"}}'`}}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save settings: %v", err)
+	}
+	c = Config{ServerURL: "https://other.example.com"}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.TokenSource == TokenSourceExec {
+		t.Errorf("Token = %q (%s), exec provider ran for another origin", c.Token, c.TokenSource)
+	}
+	c = Config{ServerURL: "https://FILE.example.com:443/api"}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.TokenSource != TokenSourceExec {
+		t.Errorf("Token = %q (%s), want the exec provider's for the same origin", c.Token, c.TokenSource)
+	}
+
+	// The environment still wins over both.
+	t.Setenv("DOT_AI_AUTH_TOKEN", "env-token")
+	c = Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "env-token" || c.TokenSource != TokenSourceStatic {
+		t.Errorf("Token = %q (%s), want the env token", c.Token, c.TokenSource)
+	}
+}
+
+func TestResolveContexts(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_URL", "DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT", "DOT_AI_CONTEXT"} {
+		t.Setenv(key, "")
+	}
+
+	s := auth.Settings{
+		CurrentContext: "staging",
+		Contexts: map[string]*auth.Settings{
+			"staging": {ServerURL: "https://staging.example.com"},
+			"prod":    {ServerURL: "https://prod.example.com", OutputFormat: "json"},
+		},
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save settings: %v", err)
+	}
+	cr := auth.Credentials{Contexts: map[string]*auth.Credentials{
+		"staging": {AuthToken: "staging-token"},
+		"prod":    {AuthToken: "prod-token"},
+	}}
+	if err := cr.Save(); err != nil {
+		t.Fatalf("Save credentials: %v", err)
+	}
+
+	// Current context.
+	c := Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Context != "staging" || c.ServerURL != "https://staging.example.com" || c.Token != "staging-token" {
+		t.Errorf("current context resolved to %+v", c)
+	}
+
+	// DOT_AI_CONTEXT overrides current_context.
+	t.Setenv("DOT_AI_CONTEXT", "prod")
+	c = Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Context != "prod" || c.ServerURL != "https://prod.example.com" || c.Token != "prod-token" || c.OutputFormat != "json" {
+		t.Errorf("env context resolved to %+v", c)
+	}
+
+	// --context overrides DOT_AI_CONTEXT; an unknown name is an error.
+	c = Config{Context: "missing"}
+	err := c.Resolve()
+	var notFound *auth.ContextNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("Resolve with unknown context error = %v, want ContextNotFoundError", err)
+	}
+}
+
+func TestResolveStoredTokenBoundToOrigin(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_URL", "DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT", "DOT_AI_CONTEXT"} {
+		t.Setenv(key, "")
+	}
+
+	var cr auth.Credentials
+	prod, err := cr.ForServer("https://prod.example.com")
+	if err != nil {
+		t.Fatalf("ForServer: %v", err)
+	}
+	prod.AuthToken = "prod-token"
+	if err := cr.Save(); err != nil {
+		t.Fatalf("Save credentials: %v", err)
+	}
+
+	c := Config{ServerURL: "https://PROD.example.com:443/"}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "prod-token" {
+		t.Errorf("Token = %q, want %q for the matching origin", c.Token, "prod-token")
+	}
+
+	// Another host (e.g. a typo or a hostile URL) gets no stored token.
+	t.Setenv("DOT_AI_URL", "https://prod.example.com.attacker.io")
+	c = Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "" || c.TokenSource != TokenSourceNone {
+		t.Errorf("Token = %q (%s), want none for a different origin", c.Token, c.TokenSource)
+	}
+	if len(c.CredentialOrigins) != 1 || c.CredentialOrigins[0] != "https://prod.example.com" {
+		t.Errorf("CredentialOrigins = %v", c.CredentialOrigins)
+	}
+}
+
+func TestResolveLegacyTokenNotCapturedByEnvServer(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT", "DOT_AI_CONTEXT"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("DOT_AI_URL", "https://staging.example.com")
+
+	cr := auth.Credentials{AuthToken: "legacy-token"}
+	if err := cr.Save(); err != nil {
+		t.Fatalf("Save credentials: %v", err)
+	}
+
+	c := Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.Token != "" {
+		t.Errorf("Token = %q sent to DOT_AI_URL's server", c.Token)
+	}
+	stored, err := auth.LoadCredentials()
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if stored.Server("https://staging.example.com") != nil {
+		t.Errorf("legacy token bound to DOT_AI_URL: %+v", stored.Servers)
+	}
+	if got := stored.Server(DefaultServerURL); got == nil || got.AuthToken != "legacy-token" {
+		t.Errorf("legacy token not bound to the default server: %+v", stored.Servers)
+	}
+}
+
+func TestResolveProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+
+	for _, key := range []string{"DOT_AI_URL", "DOT_AI_AUTH_TOKEN", "DOT_AI_OUTPUT_FORMAT", "DOT_AI_CONTEXT"} {
+		t.Setenv(key, "")
+	}
+
+	s := auth.Settings{ServerURL: "https://user.example.com", OutputFormat: "json"}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save settings: %v", err)
+	}
+
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, auth.ProjectFileName), []byte("server_url: https://project.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	c := Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.ServerURL != "https://project.example.com" || c.ServerURLSource != SourceProject {
+		t.Errorf("ServerURL = %q, want the project file's value", c.ServerURL)
+	}
+	if c.OutputFormat != "json" {
+		t.Errorf("OutputFormat = %q, want settings.json's value for keys the project file leaves unset", c.OutputFormat)
+	}
+	if c.ProjectFile != filepath.Join(repo, auth.ProjectFileName) {
+		t.Errorf("ProjectFile = %q", c.ProjectFile)
+	}
+
+	// Env vars still win over the project file.
+	t.Setenv("DOT_AI_URL", "https://env.example.com")
+	c = Config{}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.ServerURL != "https://env.example.com" {
+		t.Errorf("ServerURL = %q, want the env value", c.ServerURL)
+	}
+}
+
+func TestResolveLockedKeys(t *testing.T) {
+	dir := t.TempDir()
+	setConfigDir(t, dir)
+	t.Setenv("DOT_AI_URL", "https://env.example.com")
+	t.Setenv("DOT_AI_OUTPUT_FORMAT", "")
+	t.Setenv("DOT_AI_CONTEXT", "")
+
+	s := auth.Settings{ServerURL: "https://user.example.com", OutputFormat: "json"}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save settings: %v", err)
+	}
+	system := `{"server_url": "https://managed.example.com", "output_format": "csv", "locked": ["server_url"]}`
+	if err := os.WriteFile(auth.SystemSettingsPath(), []byte(system), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{ServerURL: "https://flag.example.com"}
+	if err := c.Resolve(); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if c.ServerURL != "https://managed.example.com" || c.ServerURLSource != SourceSystem {
+		t.Errorf("ServerURL = %q, want the locked system value over flag, env and settings.json", c.ServerURL)
+	}
+	if c.OutputFormat != "json" {
+		t.Errorf("OutputFormat = %q, want settings.json's value over the unlocked system one", c.OutputFormat)
+	}
+}
+
 func TestIsExpired(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -250,4 +613,3 @@ func TestIsExpired(t *testing.T) {
 		})
 	}
 }
-
@@ -0,0 +1,295 @@
+package expect
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operators supported in an expectation.
+const (
+	OpEqual    = "=="
+	OpNotEqual = "!="
+	OpGreater  = ">"
+	OpLess     = "<"
+	OpExists   = "exists"
+	OpEmpty    = "empty"
+	OpMatches  = "matches"
+)
+
+// unaryOps take no value.
+var unaryOps = map[string]bool{OpExists: true, OpEmpty: true}
+
+var validOps = []string{OpEqual, OpNotEqual, OpGreater, OpLess, OpExists, OpEmpty, OpMatches}
+
+// Expectation is a single parsed `<path> <op> [value]` assertion.
+type Expectation struct {
+	// Raw is the expression as the user wrote it, used in reports.
+	Raw  string
+	Path []string
+	Op   string
+	// Value is the decoded comparison value: a JSON literal when the text
+	// parses as one (numbers, true/false/null, "quoted strings"), otherwise
+	// the text itself.
+	Value any
+	re    *regexp.Regexp
+}
+
+// Failure records an expectation that did not hold.
+type Failure struct {
+	Expectation Expectation
+	// Actual is the value found at the path; Missing is set when the path
+	// does not resolve at all.
+	Actual  any
+	Missing bool
+}
+
+// Parse parses an expression of the form `<path> <op> <value>` (value omitted
+// for exists and empty). Paths are dotted with optional [n] indices, e.g.
+// data.pods[0].status; a leading '.' or '$.' is ignored. A `length` segment
+// on an array, object or string yields its size.
+func Parse(expr string) (Expectation, error) {
+	fields := strings.Fields(expr)
+	if len(fields) < 2 {
+		return Expectation{}, fmt.Errorf("invalid expectation %q: want '<path> <op> <value>' with op one of %s", expr, strings.Join(validOps, ", "))
+	}
+	e := Expectation{Raw: strings.TrimSpace(expr), Op: fields[1]}
+
+	path, err := parsePath(fields[0])
+	if err != nil {
+		return Expectation{}, fmt.Errorf("invalid expectation %q: %w", expr, err)
+	}
+	e.Path = path
+
+	valid := false
+	for _, op := range validOps {
+		if e.Op == op {
+			valid = true
+		}
+	}
+	if !valid {
+		return Expectation{}, fmt.Errorf("invalid expectation %q: unknown operator %q (one of %s)", expr, e.Op, strings.Join(validOps, ", "))
+	}
+
+	// The value is everything after the operator, so it may contain spaces.
+	rest := strings.TrimSpace(expr)
+	rest = strings.TrimSpace(rest[len(fields[0]):])
+	rest = strings.TrimSpace(rest[len(e.Op):])
+	if unaryOps[e.Op] {
+		if rest != "" {
+			return Expectation{}, fmt.Errorf("invalid expectation %q: %s takes no value", expr, e.Op)
+		}
+		return e, nil
+	}
+	if rest == "" {
+		return Expectation{}, fmt.Errorf("invalid expectation %q: %s needs a value", expr, e.Op)
+	}
+
+	var v any
+	if json.Unmarshal([]byte(rest), &v) == nil {
+		e.Value = v
+	} else {
+		e.Value = rest
+	}
+	switch e.Op {
+	case OpMatches:
+		re, err := regexp.Compile(fmt.Sprint(e.Value))
+		if err != nil {
+			return Expectation{}, fmt.Errorf("invalid expectation %q: %w", expr, err)
+		}
+		e.re = re
+	case OpGreater, OpLess:
+		if _, ok := e.Value.(float64); !ok {
+			return Expectation{}, fmt.Errorf("invalid expectation %q: %s needs a numeric value", expr, e.Op)
+		}
+	}
+	return e, nil
+}
+
+// parsePath splits a path like data.items[0].name into segments
+// ["data", "items", "0", "name"].
+func parsePath(p string) ([]string, error) {
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	if p == "" {
+		return nil, nil
+	}
+	var segs []string
+	for _, part := range strings.Split(p, ".") {
+		for part != "" {
+			i := strings.IndexByte(part, '[')
+			if i < 0 {
+				segs = append(segs, part)
+				break
+			}
+			if i > 0 {
+				segs = append(segs, part[:i])
+			}
+			j := strings.IndexByte(part[i:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("unclosed '[' in path %q", p)
+			}
+			idx := part[i+1 : i+j]
+			if _, err := strconv.Atoi(idx); err != nil {
+				return nil, fmt.Errorf("invalid index %q in path %q", idx, p)
+			}
+			segs = append(segs, idx)
+			part = part[i+j+1:]
+		}
+	}
+	return segs, nil
+}
+
+// Evaluate checks every expectation against a JSON document and returns the
+// ones that failed.
+func Evaluate(data []byte, exps []Expectation) ([]Failure, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("cannot evaluate expectations: response is not JSON")
+	}
+	var failures []Failure
+	for _, e := range exps {
+		actual, found := lookup(doc, e.Path)
+		if !e.holds(actual, found) {
+			failures = append(failures, Failure{Expectation: e, Actual: actual, Missing: !found})
+		}
+	}
+	return failures, nil
+}
+
+// lookup resolves path inside doc.
+func lookup(doc any, path []string) (any, bool) {
+	cur := doc
+	for _, seg := range path {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[seg]
+			if !ok {
+				if seg == "length" {
+					cur = float64(len(v))
+					continue
+				}
+				return nil, false
+			}
+			cur = next
+		case []any:
+			if seg == "length" {
+				cur = float64(len(v))
+				continue
+			}
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		case string:
+			if seg != "length" {
+				return nil, false
+			}
+			cur = float64(len(v))
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// holds reports whether the expectation is satisfied by actual.
+func (e Expectation) holds(actual any, found bool) bool {
+	switch e.Op {
+	case OpExists:
+		return found
+	case OpEmpty:
+		return !found || isEmpty(actual)
+	}
+	if !found {
+		return false
+	}
+	switch e.Op {
+	case OpEqual:
+		return equal(actual, e.Value)
+	case OpNotEqual:
+		return !equal(actual, e.Value)
+	case OpGreater, OpLess:
+		n, ok := number(actual)
+		if !ok {
+			return false
+		}
+		want := e.Value.(float64)
+		if e.Op == OpGreater {
+			return n > want
+		}
+		return n < want
+	case OpMatches:
+		return e.re.MatchString(text(actual))
+	}
+	return false
+}
+
+// equal compares a document value with an expected value. Values of
+// different JSON types are compared by their text, so `status == 200` also
+// matches "200".
+func equal(actual, want any) bool {
+	if reflect.DeepEqual(actual, want) {
+		return true
+	}
+	return text(actual) == text(want)
+}
+
+// number converts a JSON number (or numeric string) to float64.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// isEmpty reports whether v is null, "", [] or {}.
+func isEmpty(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []any:
+		return len(val) == 0
+	case map[string]any:
+		return len(val) == 0
+	}
+	return false
+}
+
+// text renders a value for comparison and reports: strings bare, everything
+// else as compact JSON.
+func text(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Report renders failures as a readable expected-vs-actual listing.
+func Report(failures []Failure, total int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d expectations failed:", len(failures), total)
+	for _, f := range failures {
+		fmt.Fprintf(&b, "\n  ✗ %s", f.Expectation.Raw)
+		if f.Missing {
+			b.WriteString("\n      actual: <missing>")
+			continue
+		}
+		actual, _ := json.Marshal(f.Actual)
+		if len(actual) > 200 {
+			actual = append(actual[:200], "..."...)
+		}
+		fmt.Fprintf(&b, "\n      actual: %s", actual)
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package expect
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const doc = `{
+  "success": true,
+  "data": {
+    "count": 3,
+    "status": "degraded",
+    "pods": [{"name": "web-1", "ready": true}, {"name": "db-0", "ready": false}],
+    "unhealthy": [],
+    "note": ""
+  }
+}`
+
+func TestParse(t *testing.T) {
+	e, err := Parse(`data.pods[1].name == "db 0"`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := []string{"data", "pods", "1", "name"}; !reflect.DeepEqual(e.Path, want) {
+		t.Errorf("Path = %v, want %v", e.Path, want)
+	}
+	if e.Op != OpEqual || e.Value != "db 0" {
+		t.Errorf("Op/Value = %q/%v", e.Op, e.Value)
+	}
+
+	e, err = Parse(".data.count > 2")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if e.Value != float64(2) {
+		t.Errorf("numeric value = %#v, want 2", e.Value)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"data.count",
+		"data.count ~= 3",
+		"data.count >",
+		"data.count > many",
+		"data.unhealthy empty now",
+		"data.pods[x].name exists",
+		"data.status matches (",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected error", expr)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"success == true", true},
+		{"data.count == 3", true},
+		{`data.count == "3"`, true},
+		{"data.count != 3", false},
+		{"data.count > 2", true},
+		{"data.count < 2", false},
+		{"data.status == healthy", false},
+		{"data.status matches ^deg", true},
+		{"data.pods[0].ready == true", true},
+		{"data.pods[1].ready == true", false},
+		{"data.pods.length == 2", true},
+		{"data.unhealthy empty", true},
+		{"data.pods empty", false},
+		{"data.note empty", true},
+		{"data.missing empty", true},
+		{"data.missing exists", false},
+		{"data.pods[5] exists", false},
+		{"data.status exists", true},
+	}
+	for _, tc := range cases {
+		e, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.expr, err)
+		}
+		failures, err := Evaluate([]byte(doc), []Expectation{e})
+		if err != nil {
+			t.Fatalf("Evaluate(%q): %v", tc.expr, err)
+		}
+		if got := len(failures) == 0; got != tc.want {
+			t.Errorf("%q held = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	var exps []Expectation
+	for _, expr := range []string{"data.count > 5", "data.status == healthy", "data.gone exists", "success == true"} {
+		e, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exps = append(exps, e)
+	}
+	failures, err := Evaluate([]byte(doc), exps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Report(failures, len(exps))
+	for _, want := range []string{
+		"3 of 4 expectations failed:",
+		"✗ data.count > 5\n      actual: 3",
+		"✗ data.status == healthy\n      actual: \"degraded\"",
+		"✗ data.gone exists\n      actual: <missing>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "success") {
+		t.Errorf("report should only list failures:\n%s", got)
+	}
+}
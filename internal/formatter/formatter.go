@@ -8,6 +8,9 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Formats lists the supported output formats.
+var Formats = []string{"json", "yaml", "ndjson", "csv"}
+
 // Format converts raw JSON response bytes to the requested output format.
 func Format(data []byte, format string) (string, error) {
 	switch format {
@@ -24,7 +27,11 @@ func Format(data []byte, format string) (string, error) {
 			return string(data), nil
 		}
 		return strings.TrimRight(string(out), "\n"), nil
+	case "ndjson":
+		return FormatNDJSON(data)
+	case "csv":
+		return FormatCSV(data, nil)
 	default:
-		return "", fmt.Errorf("unsupported output format: %q (valid: json, yaml)", format)
+		return "", fmt.Errorf("unsupported output format: %q (valid: %s)", format, strings.Join(Formats, ", "))
 	}
 }
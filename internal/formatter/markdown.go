@@ -0,0 +1,108 @@
+package formatter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ANSI SGR sequences used by the terminal renderers.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiBlue      = "\x1b[34m"
+	ansiMagenta   = "\x1b[35m"
+	ansiCyan      = "\x1b[36m"
+	ansiGray      = "\x1b[90m"
+)
+
+var (
+	mdHeadingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBulletRe    = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdOrderedRe   = regexp.MustCompile(`^(\s*)(\d+[.)])\s+(.*)$`)
+	mdQuoteRe     = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	mdRuleRe      = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdCodeSpanRe  = regexp.MustCompile("`([^`]+)`")
+	mdBoldRe      = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalicRe    = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*]*)\*`)
+	mdLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdIndicatorRe = regexp.MustCompile("(?m)^(#{1,6} |\\s*[-*+] |\\s*\\d+[.)] |```|> )")
+)
+
+// looksLikeMarkdown reports whether s is a multi-line string carrying
+// markdown structure (headings, lists, fences, quotes) or inline emphasis.
+// Single-line values are never treated as markdown so identifiers, names and
+// short messages keep their plain YAML rendering.
+func looksLikeMarkdown(s string) bool {
+	if !strings.Contains(strings.TrimSpace(s), "\n") {
+		return false
+	}
+	return mdIndicatorRe.MatchString(s) || strings.Contains(s, "**") || strings.Contains(s, "`")
+}
+
+// RenderMarkdown renders a markdown document for an ANSI terminal. It covers
+// the subset the dot-ai server emits in answers and analyses — headings,
+// lists, block quotes, fenced code, rules, and inline bold/italic/code/links —
+// and leaves anything else as plain text.
+func RenderMarkdown(src string) string {
+	var out []string
+	inFence := false
+	for _, line := range strings.Split(strings.TrimRight(src, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, "    "+ansiCyan+line+ansiReset)
+			continue
+		}
+
+		switch {
+		case mdHeadingRe.MatchString(line):
+			m := mdHeadingRe.FindStringSubmatch(line)
+			style := ansiBold + ansiMagenta
+			if len(m[1]) == 1 {
+				style += ansiUnderline
+			}
+			out = append(out, style+renderInline(m[2])+ansiReset)
+		case mdRuleRe.MatchString(line):
+			out = append(out, ansiGray+strings.Repeat("─", 40)+ansiReset)
+		case mdBulletRe.MatchString(line):
+			m := mdBulletRe.FindStringSubmatch(line)
+			out = append(out, m[1]+"  • "+renderInline(m[2]))
+		case mdOrderedRe.MatchString(line):
+			m := mdOrderedRe.FindStringSubmatch(line)
+			out = append(out, m[1]+"  "+m[2]+" "+renderInline(m[3]))
+		case mdQuoteRe.MatchString(line):
+			m := mdQuoteRe.FindStringSubmatch(line)
+			out = append(out, ansiGray+"│ "+ansiItalic+renderInline(m[1])+ansiReset)
+		default:
+			out = append(out, renderInline(line))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderInline applies inline markdown styles to a single line. Code spans are
+// rendered first and shielded from the emphasis rules so `a*b*c` stays intact.
+func renderInline(s string) string {
+	var spans []string
+	s = mdCodeSpanRe.ReplaceAllStringFunc(s, func(m string) string {
+		spans = append(spans, ansiCyan+m[1:len(m)-1]+ansiReset)
+		return "\x00" + strconv.Itoa(len(spans)-1) + "\x00"
+	})
+	s = mdLinkRe.ReplaceAllString(s, "$1 ("+ansiUnderline+ansiBlue+"$2"+ansiReset+")")
+	s = mdBoldRe.ReplaceAllStringFunc(s, func(m string) string {
+		return ansiBold + m[2:len(m)-2] + ansiReset
+	})
+	s = mdItalicRe.ReplaceAllString(s, "$1"+ansiItalic+"$2"+ansiReset)
+	for i, span := range spans {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", span, 1)
+	}
+	return s
+}
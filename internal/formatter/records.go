@@ -0,0 +1,221 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// recordSearchDepth bounds how deep FindRecords looks for the list payload:
+// the response root, the data envelope, and one level of nesting inside it
+// (e.g. {"data":{"result":{"items":[...]}}}).
+const recordSearchDepth = 3
+
+// preferredListKeys are the field names checked, in order, when an object
+// carries more than one array and none of them is unambiguous.
+var preferredListKeys = []string{"items", "results", "resources", "namespaces", "users", "sessions", "tools", "prompts"}
+
+// IsRecordFormat reports whether format emits one record per line and so
+// needs a list-shaped response.
+func IsRecordFormat(format string) bool {
+	return format == "ndjson" || format == "csv"
+}
+
+// FindRecords locates the primary array in a response: the body itself when it
+// is an array, otherwise the array inside the "data" envelope (or the root
+// object). When an object holds several arrays, the conventional list names in
+// preferredListKeys win; a lone nested object is searched one level deeper.
+func FindRecords(data []byte) ([]json.RawMessage, error) {
+	recs, ok := findRecords(json.RawMessage(data), recordSearchDepth)
+	if !ok {
+		return nil, fmt.Errorf("response does not contain a list to split into records; use --output json or yaml")
+	}
+	return recs, nil
+}
+
+func findRecords(raw json.RawMessage, depth int) ([]json.RawMessage, bool) {
+	var arr []json.RawMessage
+	if json.Unmarshal(raw, &arr) == nil {
+		return arr, true
+	}
+	var obj map[string]json.RawMessage
+	if depth == 0 || json.Unmarshal(raw, &obj) != nil {
+		return nil, false
+	}
+
+	if d, ok := obj["data"]; ok {
+		if recs, ok := findRecords(d, depth-1); ok {
+			return recs, true
+		}
+	}
+
+	var arrays, objects []string
+	for k, v := range obj {
+		switch firstByte(v) {
+		case '[':
+			arrays = append(arrays, k)
+		case '{':
+			objects = append(objects, k)
+		}
+	}
+	if len(arrays) == 1 {
+		return findRecords(obj[arrays[0]], 0)
+	}
+	for _, k := range preferredListKeys {
+		if firstByte(obj[k]) == '[' {
+			return findRecords(obj[k], 0)
+		}
+	}
+	if len(arrays) == 0 && len(objects) == 1 && objects[0] != "data" {
+		return findRecords(obj[objects[0]], depth-1)
+	}
+	return nil, false
+}
+
+// firstByte returns the first non-space byte of a JSON value, or 0.
+func firstByte(raw json.RawMessage) byte {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
+
+// FormatNDJSON writes each record of the response's primary array as compact
+// JSON on its own line; an empty list is written as "".
+func FormatNDJSON(data []byte) (string, error) {
+	recs, err := FindRecords(data)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, len(recs))
+	for _, r := range recs {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, r); err != nil {
+			return "", fmt.Errorf("invalid record in response: %w", err)
+		}
+		lines = append(lines, buf.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// FormatCSV writes the response's primary array as CSV. The header is columns
+// when given (dotted paths such as metadata.name select nested fields),
+// otherwise the union of the records' top-level keys in first-seen order.
+// Nested objects and arrays are written as compact JSON; nulls are empty. An
+// empty list without columns has no header and is written as "".
+func FormatCSV(data []byte, columns []string) (string, error) {
+	recs, err := FindRecords(data)
+	if err != nil {
+		return "", err
+	}
+
+	rows := make([]map[string]interface{}, len(recs))
+	var union []string
+	seen := map[string]bool{}
+	for i, r := range recs {
+		var obj map[string]interface{}
+		if json.Unmarshal(r, &obj) != nil {
+			// Scalar records (e.g. a list of names) become a single column.
+			var v interface{}
+			if err := json.Unmarshal(r, &v); err != nil {
+				return "", fmt.Errorf("invalid record in response: %w", err)
+			}
+			obj = map[string]interface{}{"value": v}
+		}
+		rows[i] = obj
+		for _, k := range objectKeys(r, obj) {
+			if !seen[k] {
+				seen[k] = true
+				union = append(union, k)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		columns = union
+	}
+	if len(columns) == 0 {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, col := range columns {
+			cells[i] = csvCell(lookupPath(row, col))
+		}
+		if err := w.Write(cells); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// objectKeys returns the top-level keys of a JSON object in document order.
+// decoded is the already-unmarshalled record; its keys are used (sorted) as a
+// fallback when raw is not an object.
+func objectKeys(raw json.RawMessage, decoded map[string]interface{}) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		keys := make([]string, 0, len(decoded))
+		for k := range decoded {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			break
+		}
+	}
+	return keys
+}
+
+// lookupPath resolves a dotted path (a.b.c) inside a decoded record. An exact
+// top-level key match wins so keys that themselves contain dots still work.
+func lookupPath(obj map[string]interface{}, path string) interface{} {
+	if v, ok := obj[path]; ok {
+		return v
+	}
+	var cur interface{} = obj
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// csvCell renders a decoded JSON value as a CSV cell.
+func csvCell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
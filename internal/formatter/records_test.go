@@ -0,0 +1,100 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindRecords(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"bare array", `[1,2,3]`, 3},
+		{"data array", `{"success":true,"data":[{"a":1},{"a":2}]}`, 2},
+		{"single array in data", `{"success":true,"data":{"namespaces":["a","b"],"count":2}}`, 2},
+		{"preferred name wins", `{"data":{"items":[1],"warnings":[1,2]}}`, 1},
+		{"nested object", `{"data":{"result":{"sessions":[{"id":"x"}]}}}`, 1},
+		{"top-level array without envelope", `{"users":[{"id":1},{"id":2}]}`, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recs, err := FindRecords([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("FindRecords: %v", err)
+			}
+			if len(recs) != tc.want {
+				t.Errorf("got %d records, want %d", len(recs), tc.want)
+			}
+		})
+	}
+}
+
+func TestFindRecordsNoList(t *testing.T) {
+	for _, in := range []string{`{"data":{"version":"1.0"}}`, `{"a":[1],"b":[2]}`, `"text"`} {
+		if _, err := FindRecords([]byte(in)); err == nil {
+			t.Errorf("FindRecords(%s): expected error", in)
+		}
+	}
+}
+
+func TestFormatNDJSON(t *testing.T) {
+	got, err := Format([]byte(`{"data":{"users":[{"id": 1, "name": "a"},{"id": 2}]}}`), "ndjson")
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	want := `{"id":1,"name":"a"}` + "\n" + `{"id":2}`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatCSVUnionOfKeys(t *testing.T) {
+	got, err := FormatCSV([]byte(`{"data":[{"name":"web","replicas":3},{"name":"db, primary","labels":{"app":"db"},"owner":null}]}`), nil)
+	if err != nil {
+		t.Fatalf("FormatCSV: %v", err)
+	}
+	want := strings.Join([]string{
+		"name,replicas,labels,owner",
+		"web,3,,",
+		`"db, primary",,"{""app"":""db""}",`,
+	}, "\n")
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatCSVColumns(t *testing.T) {
+	got, err := FormatCSV([]byte(`[{"metadata":{"name":"a","namespace":"x"},"kind":"Pod"}]`), []string{"kind", "metadata.name"})
+	if err != nil {
+		t.Fatalf("FormatCSV: %v", err)
+	}
+	want := "kind,metadata.name\nPod,a"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatCSVScalarRecords(t *testing.T) {
+	got, err := FormatCSV([]byte(`{"data":{"namespaces":["default","kube-system"]}}`), nil)
+	if err != nil {
+		t.Fatalf("FormatCSV: %v", err)
+	}
+	if want := "value\ndefault\nkube-system"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatRecordsEmptyList(t *testing.T) {
+	data := []byte(`{"success":true,"data":{"users":[]}}`)
+	if got, err := FormatNDJSON(data); err != nil || got != "" {
+		t.Errorf("FormatNDJSON = %q, %v; want nothing", got, err)
+	}
+	if got, err := FormatCSV(data, nil); err != nil || got != "" {
+		t.Errorf("FormatCSV = %q, %v; want nothing without columns", got, err)
+	}
+	if got, err := FormatCSV(data, []string{"id", "name"}); err != nil || got != "id,name" {
+		t.Errorf("FormatCSV with columns = %q, %v; want the header only", got, err)
+	}
+}
@@ -0,0 +1,233 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// markdownPlaceholder replaces a markdown field in the structured part of the
+// terminal rendering; the field itself is rendered after the document.
+const markdownPlaceholder = "(rendered below)"
+
+// Terminal controls the presentation layered on top of Format when output goes
+// to an interactive terminal. The zero value yields exactly Format's output,
+// which is what pipes, files and NO_COLOR users get.
+type Terminal struct {
+	// Color syntax-highlights YAML/JSON documents.
+	Color bool
+	// Markdown renders multi-line markdown string fields (answers, analyses)
+	// for the terminal instead of printing them as escaped YAML strings. It
+	// only applies to YAML output; JSON stays a faithful copy of the response.
+	Markdown bool
+}
+
+// FormatTerminal behaves like Format but applies the terminal presentation
+// described by term.
+func FormatTerminal(data []byte, format string, term Terminal) (string, error) {
+	if format != "yaml" || !term.Markdown {
+		out, err := Format(data, format)
+		if err != nil || !term.Color {
+			return out, err
+		}
+		switch format {
+		case "json":
+			return HighlightJSON(out), nil
+		case "yaml":
+			return HighlightYAML(out), nil
+		}
+		return out, nil
+	}
+
+	var obj interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		// Not JSON — a plain-text body may itself be markdown.
+		if looksLikeMarkdown(string(data)) {
+			return RenderMarkdown(string(data)), nil
+		}
+		return string(data), nil
+	}
+
+	var sections []markdownField
+	obj = extractMarkdown(obj, "", &sections)
+	if s, ok := obj.(string); ok && s == markdownPlaceholder && len(sections) == 1 {
+		return RenderMarkdown(sections[0].text), nil
+	}
+
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		return string(data), nil
+	}
+	doc := strings.TrimRight(string(out), "\n")
+	if term.Color {
+		doc = HighlightYAML(doc)
+	}
+
+	var b strings.Builder
+	b.WriteString(doc)
+	for _, sec := range sections {
+		fmt.Fprintf(&b, "\n\n%s── %s ──%s\n%s", ansiBold+ansiGray, sec.path, ansiReset, RenderMarkdown(sec.text))
+	}
+	return b.String(), nil
+}
+
+// markdownField is a markdown string lifted out of a response document.
+type markdownField struct {
+	path string
+	text string
+}
+
+// extractMarkdown walks a decoded JSON value, replacing every markdown string
+// with markdownPlaceholder and recording it (with its dotted path) in
+// sections. Map keys are visited in sorted order to match yaml.Marshal.
+func extractMarkdown(v interface{}, path string, sections *[]markdownField) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			val[k] = extractMarkdown(val[k], child, sections)
+		}
+		return val
+	case []interface{}:
+		for i := range val {
+			val[i] = extractMarkdown(val[i], fmt.Sprintf("%s[%d]", path, i), sections)
+		}
+		return val
+	case string:
+		if looksLikeMarkdown(val) {
+			*sections = append(*sections, markdownField{path: path, text: val})
+			return markdownPlaceholder
+		}
+	}
+	return v
+}
+
+var (
+	yamlKeyRe    = regexp.MustCompile(`^(\s*(?:-\s+)*)([^\s#][^:]*?):(\s+|$)(.*)$`)
+	yamlItemRe   = regexp.MustCompile(`^(\s*-\s+)(.*)$`)
+	yamlBlockRe  = regexp.MustCompile(`^[|>][-+]?\d*$`)
+	yamlNumberRe = regexp.MustCompile(`^-?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+)
+
+// HighlightYAML colourises a YAML document line by line: keys cyan, strings
+// green, numbers and booleans yellow, nulls grey. Block scalar bodies (| and >)
+// are coloured as strings.
+func HighlightYAML(doc string) string {
+	lines := strings.Split(doc, "\n")
+	blockIndent := -1
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indent > blockIndent {
+				lines[i] = ansiGreen + line + ansiReset
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if m := yamlKeyRe.FindStringSubmatch(line); m != nil {
+			value := m[4]
+			if yamlBlockRe.MatchString(value) {
+				blockIndent = len(m[1])
+			}
+			lines[i] = m[1] + ansiCyan + m[2] + ansiReset + ":" + m[3] + colorScalar(value)
+			continue
+		}
+		if m := yamlItemRe.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1] + colorScalar(m[2])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// colorScalar colours a single YAML scalar by its type.
+func colorScalar(v string) string {
+	switch {
+	case v == "", v == "[]", v == "{}", yamlBlockRe.MatchString(v):
+		return v
+	case v == "null", v == "~":
+		return ansiGray + v + ansiReset
+	case v == "true", v == "false", yamlNumberRe.MatchString(v):
+		return ansiYellow + v + ansiReset
+	default:
+		return ansiGreen + v + ansiReset
+	}
+}
+
+// HighlightJSON colourises a JSON document without changing its layout:
+// object keys cyan, string values green, numbers and booleans yellow, nulls
+// grey. Malformed input is coloured best-effort and never rejected.
+func HighlightJSON(doc string) string {
+	var b strings.Builder
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(doc) && doc[end] != '"' {
+				if doc[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(doc) {
+				end++
+			}
+			color := ansiGreen
+			if isJSONKey(doc, end) {
+				color = ansiCyan
+			}
+			b.WriteString(color + doc[i:end] + ansiReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(doc) && strings.IndexByte("0123456789.eE+-", doc[end]) >= 0 {
+				end++
+			}
+			b.WriteString(ansiYellow + doc[i:end] + ansiReset)
+			i = end
+		case strings.HasPrefix(doc[i:], "true"), strings.HasPrefix(doc[i:], "false"):
+			n := 4
+			if c == 'f' {
+				n = 5
+			}
+			b.WriteString(ansiYellow + doc[i:i+n] + ansiReset)
+			i += n
+		case strings.HasPrefix(doc[i:], "null"):
+			b.WriteString(ansiGray + "null" + ansiReset)
+			i += 4
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// isJSONKey reports whether the string ending at end is followed by a colon,
+// i.e. it is an object key rather than a value.
+func isJSONKey(doc string, end int) bool {
+	for j := end; j < len(doc); j++ {
+		switch doc[j] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case ':':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
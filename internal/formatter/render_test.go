@@ -0,0 +1,122 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatTerminalZeroValueMatchesFormat(t *testing.T) {
+	data := []byte(`{"answer":"# Title\n\n- one\n- two","count":2}`)
+	for _, format := range []string{"json", "yaml"} {
+		want, err := Format(data, format)
+		if err != nil {
+			t.Fatalf("Format(%s): %v", format, err)
+		}
+		got, err := FormatTerminal(data, format, Terminal{})
+		if err != nil {
+			t.Fatalf("FormatTerminal(%s): %v", format, err)
+		}
+		if got != want {
+			t.Errorf("FormatTerminal(%s) with zero Terminal = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestFormatTerminalRendersMarkdownFields(t *testing.T) {
+	data := []byte(`{"success":true,"data":{"answer":"## Pods\n\n**2** pods are ` + "`Running`" + `","id":"abc"}}`)
+	got, err := FormatTerminal(data, "yaml", Terminal{Color: true, Markdown: true})
+	if err != nil {
+		t.Fatalf("FormatTerminal: %v", err)
+	}
+	if strings.Contains(got, `\n`) {
+		t.Errorf("markdown field should not be printed as an escaped string:\n%s", got)
+	}
+	if !strings.Contains(got, markdownPlaceholder) {
+		t.Errorf("structured part should keep a placeholder for the markdown field:\n%s", got)
+	}
+	if !strings.Contains(got, "── data.answer ──") {
+		t.Errorf("rendered section should be labelled with the field path:\n%s", got)
+	}
+	if !strings.Contains(got, ansiBold+"2"+ansiReset) || !strings.Contains(got, ansiCyan+"Running"+ansiReset) {
+		t.Errorf("inline markdown was not rendered:\n%q", got)
+	}
+}
+
+func TestFormatTerminalJSONKeepsMarkdownVerbatim(t *testing.T) {
+	data := []byte(`{"answer":"# Title\n- item"}`)
+	got, err := FormatTerminal(data, "json", Terminal{Color: true, Markdown: true})
+	if err != nil {
+		t.Fatalf("FormatTerminal: %v", err)
+	}
+	if !strings.Contains(got, `"# Title\n- item"`) {
+		t.Errorf("JSON output must keep markdown verbatim, got %q", got)
+	}
+}
+
+func TestLooksLikeMarkdown(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{"single line with **bold**", false},
+		{"plain\nmulti-line text", false},
+		{"# Heading\nbody", true},
+		{"intro\n- item", true},
+		{"see\n```\ncode\n```", true},
+		{"uses **bold**\nacross lines", true},
+	}
+	for _, tc := range cases {
+		if got := looksLikeMarkdown(tc.in); got != tc.want {
+			t.Errorf("looksLikeMarkdown(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	got := RenderMarkdown("# Title\n- item `x`\n```\nkubectl get pods\n```\n> note")
+	for _, want := range []string{
+		ansiBold + ansiMagenta + ansiUnderline + "Title" + ansiReset,
+		"  • item " + ansiCyan + "x" + ansiReset,
+		"    " + ansiCyan + "kubectl get pods" + ansiReset,
+		"│ ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderMarkdown output missing %q:\n%q", want, got)
+		}
+	}
+	if strings.Contains(got, "```") {
+		t.Errorf("code fences should be stripped:\n%q", got)
+	}
+}
+
+func TestHighlightYAML(t *testing.T) {
+	got := HighlightYAML("name: web\nreplicas: 3\nready: true\nowner: null\nnote: |-\n  line one\n  line two\nitems:\n  - a")
+	for _, want := range []string{
+		ansiCyan + "name" + ansiReset + ": " + ansiGreen + "web" + ansiReset,
+		ansiYellow + "3" + ansiReset,
+		ansiYellow + "true" + ansiReset,
+		ansiGray + "null" + ansiReset,
+		ansiGreen + "  line two" + ansiReset,
+		"  - " + ansiGreen + "a" + ansiReset,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HighlightYAML output missing %q:\n%q", want, got)
+		}
+	}
+}
+
+func TestHighlightJSON(t *testing.T) {
+	got := HighlightJSON(`{"name":"web","replicas":3,"ok":false,"x":null,"esc":"a\"b"}`)
+	for _, want := range []string{
+		ansiCyan + `"name"` + ansiReset,
+		ansiGreen + `"web"` + ansiReset,
+		ansiYellow + "3" + ansiReset,
+		ansiYellow + "false" + ansiReset,
+		ansiGray + "null" + ansiReset,
+		ansiGreen + `"a\"b"` + ansiReset,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HighlightJSON output missing %q:\n%q", want, got)
+		}
+	}
+}
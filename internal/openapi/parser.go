@@ -66,9 +66,22 @@ func Parse(specJSON []byte) ([]CommandDef, error) {
 	return p.buildCommands(), nil
 }
 
+// Version returns the spec's info.version, the version of the server API
+// the commands were generated from, or "" when the spec has none.
+func Version(specJSON []byte) string {
+	var spec openAPISpec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return ""
+	}
+	return spec.Info.Version
+}
+
 // --- internal OpenAPI 3.0 types (minimal, only what we need) ---
 
 type openAPISpec struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
 	Paths      map[string]*pathItem `json:"paths"`
 	Components *components          `json:"components"`
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -54,6 +55,21 @@ func FilterCommands(root *cobra.Command, cfg *config.Config) {
 	}
 }
 
+// AllowedTools returns the sorted names of the tools the server allows the
+// caller to use.
+func AllowedTools(cfg *config.Config) ([]string, error) {
+	allowed, err := fetchAllowedTools(cfg)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(allowed))
+	for name := range allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 // fetchAllowedTools calls GET /api/v1/tools and returns a set of allowed
 // tool names.
 func fetchAllowedTools(cfg *config.Config) (map[string]bool, error) {
@@ -307,6 +307,16 @@ func removeExistingHook(settings map[string]any) {
 	hooksMap[hookEventKey] = kept
 }
 
+// HookCommands returns the dot-ai skills generate SessionStart hook commands
+// installed in the settings file at path (none when it does not exist).
+func HookCommands(path string) ([]string, error) {
+	settings, err := readSettings(path)
+	if err != nil {
+		return nil, err
+	}
+	return findHookCommands(settings), nil
+}
+
 // findHookCommands returns all dot-ai skills generate commands found in the settings.
 func findHookCommands(settings map[string]any) []string {
 	hooks, ok := settings["hooks"]
@@ -32,10 +32,12 @@ const (
 	// filesystem path (a side-loading vector for arbitrary skill code), so it is
 	// default-off: the user must explicitly set this to "1".
 	repoDirAllowEnv = "DOT_AI_ALLOW_REPO_DIR"
-	// repoDirAllowlistEnv is an optional colon-separated list of base directories
-	// under which a --repo-dir path must live. When unset, no path restriction is
-	// applied beyond the /tmp + world-writable refusals below.
-	repoDirAllowlistEnv = "DOT_AI_REPO_DIR_ALLOW"
+	// RepoDirAllowlistEnv is an optional colon-separated list of base
+	// directories under which a --repo-dir path must live. When unset, no path
+	// restriction is applied beyond the /tmp + world-writable refusals below.
+	// The caller resolves it against the skills_repo_dir_allow setting and
+	// passes the result to AuthorizeRepoDir.
+	RepoDirAllowlistEnv = "DOT_AI_REPO_DIR_ALLOW"
 
 	// Ingestion limits mirrored from the frozen server contract. Pre-checking
 	// them yields a clear CLI error instead of relying solely on the server 413.
@@ -152,12 +154,13 @@ func sanitizeIdentityPart(s string) string {
 //   - refuses a path under /tmp or $TMPDIR (shared, world-writable temp space is
 //     a side-loading vector);
 //   - refuses a world-writable directory;
-//   - if DOT_AI_REPO_DIR_ALLOW is set, requires the path to live under one of
-//     its colon-separated base directories.
+//   - if allowlist (DOT_AI_REPO_DIR_ALLOW or the skills_repo_dir_allow
+//     setting, named by allowlistSource in errors) is set, requires the path
+//     to live under one of its colon-separated base directories.
 //
 // Every refusal returns a non-zero-exit *client.RequestError with an actionable
 // message and never reads or uploads anything.
-func AuthorizeRepoDir(dir string) (string, error) {
+func AuthorizeRepoDir(dir, allowlist, allowlistSource string) (string, error) {
 	if os.Getenv(repoDirAllowEnv) != "1" {
 		return "", &client.RequestError{
 			Message: fmt.Sprintf("Error: --repo-dir is opt-in: set %s=1 to allow reading skills from a local "+
@@ -217,7 +220,7 @@ func AuthorizeRepoDir(dir string) (string, error) {
 	// upward stop boundary for the world-writable ancestor walk: above an
 	// allowlist base is the operator-declared trust root, out of our scope.
 	var allowBases []string
-	allowRaw := strings.TrimSpace(os.Getenv(repoDirAllowlistEnv))
+	allowRaw := strings.TrimSpace(allowlist)
 	if allowRaw != "" {
 		allowBases = normalizeBases(strings.Split(allowRaw, ":"))
 	}
@@ -246,7 +249,7 @@ func AuthorizeRepoDir(dir string) (string, error) {
 		if !containedIn(resolved, allowBases) {
 			return "", &client.RequestError{
 				Message: fmt.Sprintf("Error: --repo-dir %q is not under any base directory in %s (%s)",
-					dir, repoDirAllowlistEnv, allowRaw),
+					dir, allowlistSource, allowRaw),
 				ExitCode: client.ExitUsageError,
 			}
 		}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadPassword prints prompt and reads one line from stdin. Platforms
+// without /dev/tty and stty(1) cannot turn echo off, so the input is
+// visible; set the secret through the environment to avoid that.
+func ReadPassword(prompt string) (string, error) {
+	if !IsTerminal(os.Stdin) {
+		return "", fmt.Errorf("no terminal to prompt on")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
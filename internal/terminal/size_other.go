@@ -0,0 +1,9 @@
+//go:build !unix
+
+package terminal
+
+import "os"
+
+// windowRows is not implemented on platforms without TIOCGWINSZ; callers
+// fall back to $LINES (see Height) and otherwise skip paging.
+func windowRows(f *os.File) int { return 0 }
@@ -0,0 +1,117 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultPager is used when $PAGER is unset. -R passes ANSI colour escapes
+// through; -F/-X make less exit immediately when the output fits one screen
+// and leave it on the terminal afterwards.
+const defaultPager = "less -FRX"
+
+// IsTerminal reports whether w is an *os.File attached to a character device
+// (an interactive terminal). Writers that are not files — buffers in tests,
+// pipes, redirected files — are never terminals.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// IsDumb reports whether TERM declares a terminal without escape-sequence
+// support, in which case colours and the pager must stay off.
+func IsDumb() bool {
+	return os.Getenv("TERM") == "dumb"
+}
+
+// UseColor reports whether output to w gets the interactive presentation
+// (colours, rendered markdown, the pager): w is a terminal that supports
+// escape sequences, and noColor (--no-color or NO_COLOR) is unset.
+func UseColor(w io.Writer, noColor bool) bool {
+	return !noColor && !IsDumb() && IsTerminal(w)
+}
+
+// Height returns the number of rows of the terminal behind w, or 0 when it
+// cannot be determined. $LINES takes priority so users (and tests) can
+// override the detected size.
+func Height(w io.Writer) int {
+	if v := os.Getenv("LINES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	return windowRows(f)
+}
+
+// Print writes text (plus a trailing newline) to w. When w is a terminal and
+// text is taller than the screen, it is piped through $PAGER instead (default
+// "less -FRX"). An empty $PAGER, "cat", or a pager that cannot be started
+// falls back to writing directly, so output is never lost.
+func Print(w io.Writer, text string) error {
+	if shouldPage(w, text) {
+		if ok := runPager(w, text); ok {
+			return nil
+		}
+	}
+	_, err := fmt.Fprintln(w, text)
+	return err
+}
+
+// shouldPage reports whether text needs a pager on w.
+func shouldPage(w io.Writer, text string) bool {
+	if !IsTerminal(w) {
+		return false
+	}
+	rows := Height(w)
+	if rows <= 0 {
+		return false
+	}
+	return strings.Count(text, "\n")+1 >= rows
+}
+
+// runPager pipes text into the configured pager. It returns false when no
+// pager is configured or it could not be started, leaving the caller to write
+// the text itself.
+func runPager(w io.Writer, text string) bool {
+	pager, set := os.LookupEnv("PAGER")
+	if !set {
+		pager = defaultPager
+	}
+	fields := strings.Fields(pager)
+	if len(fields) == 0 || fields[0] == "cat" {
+		return false
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return false
+	}
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	// A pager the user quits early (q in less) exits non-zero on some
+	// platforms; the text was shown, so that is not a failure.
+	if err := cmd.Start(); err != nil {
+		return false
+	}
+	_ = cmd.Wait()
+	return true
+}
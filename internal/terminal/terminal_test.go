@@ -0,0 +1,61 @@
+package terminal
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTerminalRejectsNonTerminals(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	for name, out := range map[string]io.Writer{"buffer": &bytes.Buffer{}, "file": file, "pipe": w} {
+		if IsTerminal(out) {
+			t.Errorf("IsTerminal(%s) = true", name)
+		}
+	}
+}
+
+// Output that is not a terminal is written as is, however tall, and the
+// pager never runs.
+func TestPrintPassesThroughWhenNotATerminal(t *testing.T) {
+	t.Setenv("LINES", "1")
+	t.Setenv("PAGER", "no-such-pager-should-not-run")
+	var buf bytes.Buffer
+	if err := Print(&buf, "one\ntwo\nthree"); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if got := buf.String(); got != "one\ntwo\nthree\n" {
+		t.Errorf("Print wrote %q", got)
+	}
+}
+
+func TestUseColorOffForNonTerminals(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	if UseColor(&bytes.Buffer{}, false) {
+		t.Error("UseColor = true for a buffer")
+	}
+}
+
+func TestHeight(t *testing.T) {
+	t.Setenv("LINES", "42")
+	if got := Height(&bytes.Buffer{}); got != 42 {
+		t.Errorf("Height with LINES=42 = %d", got)
+	}
+	t.Setenv("LINES", "many")
+	if got := Height(&bytes.Buffer{}); got != 0 {
+		t.Errorf("Height of a buffer with an invalid LINES = %d, want 0", got)
+	}
+}
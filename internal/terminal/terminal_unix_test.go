@@ -0,0 +1,84 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openCharDevice opens /dev/null, a character device: IsTerminal counts it
+// as a terminal, which lets the interactive paths run without a pty.
+func openCharDevice(t *testing.T) *os.File {
+	t.Helper()
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if !IsTerminal(f) {
+		t.Fatalf("%s is not a character device", os.DevNull)
+	}
+	return f
+}
+
+func TestUseColor(t *testing.T) {
+	tty := openCharDevice(t)
+	t.Setenv("TERM", "xterm-256color")
+	if !UseColor(tty, false) {
+		t.Error("UseColor = false for a terminal")
+	}
+	if UseColor(tty, true) {
+		t.Error("UseColor = true with --no-color / NO_COLOR")
+	}
+	t.Setenv("TERM", "dumb")
+	if UseColor(tty, false) {
+		t.Error("UseColor = true with TERM=dumb")
+	}
+}
+
+// pagerScript writes a pager that copies its input to a file, and returns
+// the file's path.
+func pagerScript(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	paged := filepath.Join(dir, "paged")
+	script := filepath.Join(dir, "pager.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat > '"+paged+"'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PAGER", script)
+	return paged
+}
+
+func TestPrintPagesTallOutputOnATerminal(t *testing.T) {
+	tty := openCharDevice(t)
+	paged := pagerScript(t)
+	t.Setenv("LINES", "3")
+
+	if err := Print(tty, "one"); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if _, err := os.Stat(paged); !os.IsNotExist(err) {
+		t.Error("output shorter than the screen went through the pager")
+	}
+
+	if err := Print(tty, "one\ntwo\nthree"); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if data, err := os.ReadFile(paged); err != nil || string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("pager got %q (%v), want the text", data, err)
+	}
+}
+
+func TestRunPagerFallsBack(t *testing.T) {
+	tty := openCharDevice(t)
+	t.Setenv("LINES", "1")
+	for _, pager := range []string{"", "cat", "no-such-pager"} {
+		t.Setenv("PAGER", pager)
+		if runPager(tty, "one\ntwo") {
+			t.Errorf("runPager with PAGER=%q = true, want the caller to write", pager)
+		}
+	}
+}